@@ -0,0 +1,403 @@
+package colly
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type CassetteMode int
+
+const (
+	CassetteRecord CassetteMode = iota
+	CassetteReplay
+)
+
+type Cassette struct {
+	Path          string
+	Mode          CassetteMode
+	MatchHeaders  []string
+	RedactHeaders []string
+	Interactions  []*Interaction
+	used          []bool
+	lock          *sync.Mutex
+}
+
+type Interaction struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestHeaders http.Header `json:"request_headers,omitempty"`
+	RequestBody    []byte      `json:"request_body,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	Headers        http.Header `json:"headers,omitempty"`
+	Body           []byte      `json:"body,omitempty"`
+	Recorded       time.Time   `json:"recorded"`
+}
+
+func NewCassette(path string, mode CassetteMode) (*Cassette, error) {
+	k := &Cassette{
+		Path:          path,
+		Mode:          mode,
+		RedactHeaders: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+	}
+	if mode == CassetteReplay {
+		if err := k.Load(); err != nil {
+			return nil, err
+		}
+	}
+	k.Init()
+	return k, nil
+}
+
+func (k *Cassette) Init() {
+	if k.lock == nil {
+		k.lock = &sync.Mutex{}
+	}
+}
+
+func (k *Cassette) Load() error {
+	data, err := os.ReadFile(k.Path)
+	if err != nil {
+		return err
+	}
+	var interactions []*Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return err
+	}
+	k.Interactions = interactions
+	k.used = nil
+	return nil
+}
+
+func (k *Cassette) Save() error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	return k.save()
+}
+
+func (k *Cassette) Close() error {
+	if k.Mode != CassetteRecord {
+		return nil
+	}
+	return k.Save()
+}
+
+func (k *Cassette) save() error {
+	data, err := json.MarshalIndent(k.Interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(k.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(k.Path+"~", data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(k.Path+"~", k.Path)
+}
+
+func (k *Cassette) replaying() bool {
+	return k != nil && k.Mode == CassetteReplay
+}
+
+func (k *Cassette) matches(i *Interaction, req *http.Request, body []byte) bool {
+	if i.Method != req.Method || i.URL != req.URL.String() || !bytes.Equal(i.RequestBody, body) {
+		return false
+	}
+	for _, name := range k.MatchHeaders {
+		if i.RequestHeaders.Get(name) != req.Header.Get(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (k *Cassette) replay(req *http.Request, body []byte) (*http.Response, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if len(k.used) != len(k.Interactions) {
+		k.used = make([]bool, len(k.Interactions))
+	}
+	var match *Interaction
+	for n, i := range k.Interactions {
+		if !k.matches(i, req, body) {
+			continue
+		}
+		match = i
+		if !k.used[n] {
+			k.used[n] = true
+			break
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrUnexpectedRequest, req.Method, req.URL)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", match.StatusCode, http.StatusText(match.StatusCode)),
+		StatusCode:    match.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        match.Headers.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(match.Body)),
+		ContentLength: int64(len(match.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (k *Cassette) record(req *http.Request, body []byte, res *http.Response, limit int) error {
+	reader := io.Reader(res.Body)
+	if limit > 0 {
+		reader = io.LimitReader(res.Body, int64(limit))
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		res.Body.Close()
+		return err
+	}
+	res.Body = &readCloser{Reader: io.MultiReader(bytes.NewReader(data), res.Body), Closer: res.Body}
+	requestHeaders := req.Header.Clone()
+	headers := res.Header.Clone()
+	for _, name := range k.RedactHeaders {
+		requestHeaders.Del(name)
+		headers.Del(name)
+	}
+	if limit > 0 && len(data) == limit {
+		headers.Del("Content-Length")
+	}
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.Interactions = append(k.Interactions, &Interaction{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeaders: requestHeaders,
+		RequestBody:    body,
+		StatusCode:     res.StatusCode,
+		Headers:        headers,
+		Body:           data,
+		Recorded:       time.Now().UTC(),
+	})
+	return nil
+}
+
+func (c *Collector) SetCassette(cassette *Cassette) {
+	if cassette != nil {
+		cassette.Init()
+	}
+	c.cassette = cassette
+}
+
+func (c *Collector) cassetteRoundTrip(next http.RoundTripper, req *http.Request) (*http.Response, string, error) {
+	k := c.cassette
+	if k == nil {
+		return c.hedgedRoundTrip(next, req)
+	}
+	var body []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	if k.replaying() {
+		res, err := k.replay(req, body)
+		return res, "", err
+	}
+	res, proxyURL, err := c.hedgedRoundTrip(next, req)
+	if err != nil {
+		return res, proxyURL, err
+	}
+	return res, proxyURL, k.record(req, body, res, c.bodySizeFor(res.Header.Get("Content-Type")))
+}
+
+type WARCArchive struct {
+	file    *os.File
+	records map[string]warcRecord
+	urls    []string
+}
+
+type warcRecord struct {
+	offset int64
+	gzip   bool
+}
+
+func OpenWARC(path string) (*WARCArchive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	a := &WARCArchive{file: f, records: make(map[string]warcRecord)}
+	if err := a.index(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *WARCArchive) index() error {
+	br := bufio.NewReader(a.file)
+	magic, _ := br.Peek(2)
+	compressed := len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+	var zr *gzip.Reader
+	for {
+		pos, err := a.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		offset := pos - int64(br.Buffered())
+		r := br
+		if compressed {
+			if _, err := br.Peek(1); err == io.EOF {
+				return nil
+			}
+			if zr == nil {
+				zr, err = gzip.NewReader(br)
+			} else {
+				err = zr.Reset(br)
+			}
+			if err != nil {
+				return err
+			}
+			zr.Multistream(false)
+			r = bufio.NewReader(zr)
+		}
+		header, block, err := readWARCRecord(r)
+		if err == io.EOF {
+			if compressed {
+				continue
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, block); err != nil {
+			return err
+		}
+		if compressed {
+			if _, err := io.Copy(io.Discard, zr); err != nil {
+				return err
+			}
+		}
+		target := warcURI(header.Get("WARC-Target-URI"))
+		switch header.Get("WARC-Type") {
+		case "response":
+			if _, ok := a.records[target]; !ok {
+				a.urls = append(a.urls, target)
+			}
+			a.records[target] = warcRecord{offset: offset, gzip: compressed}
+		case "revisit":
+			if rec, ok := a.records[warcURI(header.Get("WARC-Refers-To-Target-URI"))]; ok {
+				if _, ok := a.records[target]; !ok {
+					a.urls = append(a.urls, target)
+				}
+				a.records[target] = rec
+			}
+		}
+	}
+}
+
+func readWARCRecord(r *bufio.Reader) (textproto.MIMEHeader, io.Reader, error) {
+	tp := textproto.NewReader(r)
+	var version string
+	for version == "" {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return nil, nil, err
+		}
+		version = strings.TrimSpace(line)
+	}
+	if !strings.HasPrefix(version, "WARC/") {
+		return nil, nil, fmt.Errorf("%w: unexpected version line %q", ErrInvalidWARC, version)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, nil, fmt.Errorf("%w: bad Content-Length %q", ErrInvalidWARC, header.Get("Content-Length"))
+	}
+	return header, io.LimitReader(r, length), nil
+}
+
+func warcURI(s string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "<"), ">")
+}
+
+func (a *WARCArchive) URLs() []string {
+	return append([]string(nil), a.urls...)
+}
+
+func (a *WARCArchive) Has(u string) bool {
+	_, ok := a.records[u]
+	return ok
+}
+
+func (a *WARCArchive) Close() error {
+	return a.file.Close()
+}
+
+func (a *WARCArchive) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec, ok := a.records[req.URL.String()]
+	if !ok || (req.Method != "GET" && req.Method != "HEAD") {
+		return nil, fmt.Errorf("%w: %s %s", ErrNotInArchive, req.Method, req.URL)
+	}
+	var r *bufio.Reader
+	section := io.NewSectionReader(a.file, rec.offset, math.MaxInt64-rec.offset)
+	if rec.gzip {
+		zr, err := gzip.NewReader(section)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		zr.Multistream(false)
+		r = bufio.NewReader(zr)
+	} else {
+		r = bufio.NewReader(section)
+	}
+	_, block, err := readWARCRecord(r)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.ReadResponse(bufio.NewReader(block), req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.TransferEncoding = nil
+	res.Header.Del("Transfer-Encoding")
+	return res, nil
+}
+
+func (c *Collector) VisitArchive(archive *WARCArchive) error {
+	var errs []error
+	for _, u := range archive.URLs() {
+		var visited *AlreadyVisitedError
+		if err := c.Visit(u); err != nil && !errors.As(err, &visited) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,295 @@
+package arrowexport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+type Format int
+
+const (
+	File Format = iota
+	Stream
+)
+
+var (
+	ErrClosed          = errors.New("Exporter is closed")
+	ErrUnsupportedItem = errors.New("Unsupported item type")
+)
+
+type Exporter struct {
+	w         io.Writer
+	format    Format
+	batchSize int
+	columns   []column
+	itemType  reflect.Type
+	schema    *arrow.Schema
+	builder   *array.RecordBuilder
+	writer    recordWriter
+	pending   int
+	closed    bool
+	lock      *sync.Mutex
+}
+
+type recordWriter interface {
+	Write(arrow.RecordBatch) error
+	Close() error
+}
+
+type column struct {
+	name   string
+	field  []int
+	key    string
+	kind   reflect.Kind
+	isTime bool
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func New(w io.Writer, format Format, batchSize int) *Exporter {
+	if batchSize < 1 {
+		batchSize = 1024
+	}
+	return &Exporter{
+		w:         w,
+		format:    format,
+		batchSize: batchSize,
+		lock:      &sync.Mutex{},
+	}
+}
+
+func (e *Exporter) Schema() *arrow.Schema {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.schema
+}
+
+func (e *Exporter) Export(item interface{}) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.closed {
+		return ErrClosed
+	}
+	v := reflect.ValueOf(item)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ErrUnsupportedItem
+		}
+		v = v.Elem()
+	}
+	if e.schema == nil {
+		if err := e.init(v); err != nil {
+			return err
+		}
+	} else if v.Type() != e.itemType {
+		return fmt.Errorf("%w: expected %s, got %s", ErrUnsupportedItem, e.itemType, v.Type())
+	}
+	for i, col := range e.columns {
+		appendValue(e.builder.Field(i), columnValue(v, col))
+	}
+	e.pending++
+	if e.pending >= e.batchSize {
+		return e.flush()
+	}
+	return nil
+}
+
+func (e *Exporter) Flush() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.flush()
+}
+
+func (e *Exporter) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.writer == nil {
+		return nil
+	}
+	if err := e.flush(); err != nil {
+		return err
+	}
+	e.builder.Release()
+	return e.writer.Close()
+}
+
+func (e *Exporter) init(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		e.columns = structColumns(v.Type(), nil)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("%w: map keys must be strings", ErrUnsupportedItem)
+		}
+		keys := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			mv := v.MapIndex(reflect.ValueOf(k))
+			for mv.Kind() == reflect.Interface && !mv.IsNil() {
+				mv = mv.Elem()
+			}
+			e.columns = append(e.columns, column{name: k, key: k, kind: mv.Kind(), isTime: mv.IsValid() && mv.Type() == timeType})
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedItem, v.Type())
+	}
+	if len(e.columns) == 0 {
+		return fmt.Errorf("%w: %s has no exportable fields", ErrUnsupportedItem, v.Type())
+	}
+	fields := make([]arrow.Field, len(e.columns))
+	for i, col := range e.columns {
+		fields[i] = arrow.Field{Name: col.name, Type: arrowType(col), Nullable: true}
+	}
+	e.itemType = v.Type()
+	e.schema = arrow.NewSchema(fields, nil)
+	e.builder = array.NewRecordBuilder(memory.DefaultAllocator, e.schema)
+	if e.format == Stream {
+		e.writer = ipc.NewWriter(e.w, ipc.WithSchema(e.schema))
+		return nil
+	}
+	w, err := ipc.NewFileWriter(e.w, ipc.WithSchema(e.schema))
+	if err != nil {
+		return err
+	}
+	e.writer = w
+	return nil
+}
+
+func (e *Exporter) flush() error {
+	if e.pending == 0 || e.writer == nil {
+		return nil
+	}
+	rec := e.builder.NewRecordBatch()
+	defer rec.Release()
+	e.pending = 0
+	return e.writer.Write(rec)
+}
+
+func structColumns(t reflect.Type, index []int) []column {
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Tag.Get("arrow")
+		if name == "-" {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && name == "" {
+			columns = append(columns, structColumns(f.Type, fieldIndex)...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		columns = append(columns, column{name: name, field: fieldIndex, kind: ft.Kind(), isTime: ft == timeType})
+	}
+	return columns
+}
+
+func arrowType(col column) arrow.DataType {
+	if col.isTime {
+		return arrow.FixedWidthTypes.Timestamp_us
+	}
+	switch col.kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return arrow.PrimitiveTypes.Int64
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return arrow.PrimitiveTypes.Uint64
+	case reflect.Float32, reflect.Float64:
+		return arrow.PrimitiveTypes.Float64
+	case reflect.Bool:
+		return arrow.FixedWidthTypes.Boolean
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+func columnValue(v reflect.Value, col column) reflect.Value {
+	if col.field != nil {
+		return v.FieldByIndex(col.field)
+	}
+	mv := v.MapIndex(reflect.ValueOf(col.key))
+	for mv.Kind() == reflect.Interface && !mv.IsNil() {
+		mv = mv.Elem()
+	}
+	return mv
+}
+
+func appendValue(b array.Builder, v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			b.AppendNull()
+			return
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Interface && v.IsNil()) {
+		b.AppendNull()
+		return
+	}
+	switch b := b.(type) {
+	case *array.TimestampBuilder:
+		t, ok := v.Interface().(time.Time)
+		if !ok || t.IsZero() {
+			b.AppendNull()
+			return
+		}
+		b.Append(arrow.Timestamp(t.UnixMicro()))
+	case *array.Int64Builder:
+		if !v.CanInt() {
+			b.AppendNull()
+			return
+		}
+		b.Append(v.Int())
+	case *array.Uint64Builder:
+		if !v.CanUint() {
+			b.AppendNull()
+			return
+		}
+		b.Append(v.Uint())
+	case *array.Float64Builder:
+		if !v.CanFloat() {
+			b.AppendNull()
+			return
+		}
+		b.Append(v.Float())
+	case *array.BooleanBuilder:
+		if v.Kind() != reflect.Bool {
+			b.AppendNull()
+			return
+		}
+		b.Append(v.Bool())
+	case *array.StringBuilder:
+		if v.Kind() == reflect.String {
+			b.Append(v.String())
+			return
+		}
+		b.Append(fmt.Sprint(v.Interface()))
+	default:
+		b.AppendNull()
+	}
+}
@@ -0,0 +1,193 @@
+package colly
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+type Article struct {
+	Title         string
+	Byline        string
+	PublishedTime time.Time
+	Text          string
+}
+
+var (
+	articleBoilerplate = regexp.MustCompile(`(?i)comment|sidebar|footer|masthead|menu|nav|share|social|sponsor|promo|related|banner|cookie|popup|newsletter|subscribe|breadcrumb|advert|\bads?\b`)
+	articlePositive    = regexp.MustCompile(`(?i)article|body|content|entry|main|post|story|text`)
+	articleTimeLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05Z0700",
+		"2006-01-02T15:04:05",
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		time.RFC1123,
+		time.RFC1123Z,
+		"January 2, 2006",
+		"2 January 2006",
+		"Jan 2, 2006",
+	}
+)
+
+func (r *Response) Article() (*Article, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body))
+	if err != nil {
+		return nil, err
+	}
+	a := &Article{
+		Title:  articleTitle(doc),
+		Byline: articleByline(doc),
+	}
+	a.PublishedTime = articlePublishedTime(doc)
+	doc.Find("script, style, noscript, template, svg, nav, header, footer, aside, form, iframe, button, select").Remove()
+	doc.Find("[class], [id]").Each(func(_ int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "body" || goquery.NodeName(s) == "article" {
+			return
+		}
+		hint := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if articleBoilerplate.MatchString(hint) && !articlePositive.MatchString(hint) {
+			s.Remove()
+		}
+	})
+	a.Text = articleText(articleContent(doc))
+	return a, nil
+}
+
+func articleTitle(doc *goquery.Document) string {
+	if t := strings.TrimSpace(doc.Find(`meta[property="og:title"]`).AttrOr("content", "")); t != "" {
+		return t
+	}
+	if h1 := doc.Find("article h1, h1"); h1.Length() == 1 {
+		return strings.Join(strings.Fields(h1.Text()), " ")
+	}
+	title := strings.Join(strings.Fields(doc.Find("title").First().Text()), " ")
+	for _, sep := range []string{" | ", " - ", " — ", " :: "} {
+		if i := strings.LastIndex(title, sep); i > 0 {
+			return title[:i]
+		}
+	}
+	return title
+}
+
+func articleByline(doc *goquery.Document) string {
+	for _, sel := range []string{`meta[name="author"]`, `meta[property="article:author"]`} {
+		if v := strings.TrimSpace(doc.Find(sel).AttrOr("content", "")); v != "" && !strings.HasPrefix(v, "http") {
+			return v
+		}
+	}
+	for _, sel := range []string{`[itemprop="author"]`, `[rel="author"]`, ".byline", ".author"} {
+		if v := strings.Join(strings.Fields(doc.Find(sel).First().Text()), " "); v != "" && len(v) < 100 {
+			return v
+		}
+	}
+	return ""
+}
+
+func articlePublishedTime(doc *goquery.Document) time.Time {
+	var candidates []string
+	for _, sel := range []string{`meta[property="article:published_time"]`, `meta[itemprop="datePublished"]`, `meta[name="date"]`, `meta[name="pubdate"]`, `meta[name="publish-date"]`} {
+		if v, ok := doc.Find(sel).Attr("content"); ok {
+			candidates = append(candidates, v)
+		}
+	}
+	if v, ok := doc.Find(`[itemprop="datePublished"]`).Attr("datetime"); ok {
+		candidates = append(candidates, v)
+	}
+	if v, ok := doc.Find("article time[datetime], time[datetime]").Attr("datetime"); ok {
+		candidates = append(candidates, v)
+	}
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var ld struct {
+			DatePublished string `json:"datePublished"`
+		}
+		if json.Unmarshal([]byte(s.Text()), &ld) == nil && ld.DatePublished != "" {
+			candidates = append(candidates, ld.DatePublished)
+		}
+	})
+	for _, v := range candidates {
+		v = strings.TrimSpace(v)
+		for _, layout := range articleTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func articleContent(doc *goquery.Document) *goquery.Selection {
+	for _, sel := range []string{`[itemprop="articleBody"]`, "article", "main", `[role="main"]`} {
+		if s := doc.Find(sel); s.Length() == 1 && len(strings.TrimSpace(s.Text())) > 200 {
+			return s
+		}
+	}
+	scores := make(map[*html.Node]float64)
+	nodes := make(map[*html.Node]*goquery.Selection)
+	doc.Find("p, pre, td, blockquote").Each(func(_ int, p *goquery.Selection) {
+		text := strings.TrimSpace(p.Text())
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		parent := p.Parent()
+		for level := 0; level < 2 && parent.Length() > 0; level++ {
+			n := parent.Get(0)
+			if _, ok := nodes[n]; !ok {
+				nodes[n] = parent
+				hint := parent.AttrOr("class", "") + " " + parent.AttrOr("id", "")
+				if articlePositive.MatchString(hint) {
+					scores[n] += 25
+				}
+			}
+			scores[n] += score / float64(level+1)
+			parent = parent.Parent()
+		}
+	})
+	var best *goquery.Selection
+	bestScore := 0.0
+	for n, s := range nodes {
+		score := scores[n] * (1 - articleLinkDensity(s))
+		if score > bestScore {
+			best, bestScore = s, score
+		}
+	}
+	if best == nil {
+		return doc.Find("body")
+	}
+	return best
+}
+
+func articleLinkDensity(s *goquery.Selection) float64 {
+	textLength := len(strings.TrimSpace(s.Text()))
+	if textLength == 0 {
+		return 0
+	}
+	linkLength := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkLength += len(strings.TrimSpace(a.Text()))
+	})
+	return float64(linkLength) / float64(textLength)
+}
+
+func articleText(content *goquery.Selection) string {
+	var blocks []string
+	content.Find("p, h2, h3, h4, h5, h6, li, blockquote, pre").Each(func(_ int, s *goquery.Selection) {
+		if s.ParentsFiltered("li, blockquote").Length() > 0 {
+			return
+		}
+		if text := strings.Join(strings.Fields(s.Text()), " "); text != "" {
+			blocks = append(blocks, text)
+		}
+	})
+	if len(blocks) == 0 {
+		return strings.Join(strings.Fields(content.Text()), " ")
+	}
+	return strings.Join(blocks, "\n\n")
+}
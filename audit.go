@@ -0,0 +1,223 @@
+package colly
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+type PageAudit struct {
+	URL             string        `json:"url"`
+	FinalURL        string        `json:"final_url"`
+	StatusCode      int           `json:"status_code"`
+	Redirects       []string      `json:"redirects,omitempty"`
+	ResponseTime    time.Duration `json:"response_time"`
+	Size            int           `json:"size"`
+	ContentType     string        `json:"content_type,omitempty"`
+	Title           string        `json:"title,omitempty"`
+	MetaDescription string        `json:"meta_description,omitempty"`
+	Canonical       string        `json:"canonical,omitempty"`
+	Depth           int           `json:"depth"`
+	Error           string        `json:"error,omitempty"`
+	Issues          []string      `json:"issues,omitempty"`
+}
+
+type SiteAudit struct {
+	SlowThreshold time.Duration
+	pages         []*PageAudit
+	lock          *sync.Mutex
+}
+
+func NewSiteAudit() *SiteAudit {
+	return &SiteAudit{lock: &sync.Mutex{}}
+}
+
+func (c *Collector) SetSiteAudit(audit *SiteAudit) {
+	if audit != nil && audit.lock == nil {
+		audit.lock = &sync.Mutex{}
+	}
+	c.audit = audit
+}
+
+func (a *SiteAudit) record(u string, request *Request, response *Response, err error, elapsed time.Duration, state *requestState) {
+	p := &PageAudit{
+		URL:          u,
+		FinalURL:     request.URL.String(),
+		ResponseTime: elapsed,
+		Depth:        request.Depth,
+	}
+	state.lock.Lock()
+	for _, hop := range state.redirects {
+		p.Redirects = append(p.Redirects, hop.URL.String())
+	}
+	state.lock.Unlock()
+	if err != nil {
+		p.Error = err.Error()
+	}
+	if response != nil {
+		p.StatusCode = response.StatusCode
+		p.Size = len(response.Body)
+		if response.Headers != nil {
+			p.ContentType = response.Headers.Get("Content-Type")
+		}
+		if strings.Contains(strings.ToLower(p.ContentType), "html") {
+			p.Title, p.MetaDescription, p.Canonical = auditHead(response.Body)
+			if p.Canonical != "" {
+				if ref, err := request.URL.Parse(p.Canonical); err == nil {
+					p.Canonical = ref.String()
+				}
+			}
+		}
+	}
+	a.lock.Lock()
+	a.pages = append(a.pages, p)
+	a.lock.Unlock()
+}
+
+func auditHead(body []byte) (title, description, canonical string) {
+	z := html.NewTokenizer(bytes.NewReader(body))
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			switch string(name) {
+			case "title":
+				inTitle = title == ""
+			case "meta":
+				if strings.EqualFold(attrs["name"], "description") && description == "" {
+					description = strings.TrimSpace(attrs["content"])
+				}
+			case "link":
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					if rel == "canonical" && canonical == "" {
+						canonical = strings.TrimSpace(attrs["href"])
+					}
+				}
+			case "body":
+				return
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+				title = strings.Join(strings.Fields(title), " ")
+			case "head":
+				return
+			}
+		}
+	}
+}
+
+func (a *SiteAudit) Pages() []PageAudit {
+	a.lock.Lock()
+	pages := make([]PageAudit, len(a.pages))
+	for i, p := range a.pages {
+		pages[i] = *p
+	}
+	a.lock.Unlock()
+	titles := map[string]int{}
+	descriptions := map[string]int{}
+	for _, p := range pages {
+		if p.Title != "" {
+			titles[p.Title]++
+		}
+		if p.MetaDescription != "" {
+			descriptions[p.MetaDescription]++
+		}
+	}
+	for i := range pages {
+		pages[i].Issues = a.issues(&pages[i], titles, descriptions)
+	}
+	return pages
+}
+
+func (a *SiteAudit) issues(p *PageAudit, titles, descriptions map[string]int) []string {
+	var issues []string
+	switch {
+	case p.Error != "" && p.StatusCode == 0:
+		issues = append(issues, "fetch error")
+	case p.StatusCode >= 500:
+		issues = append(issues, "server error")
+	case p.StatusCode >= 400:
+		issues = append(issues, "client error")
+	}
+	if len(p.Redirects) > 1 {
+		issues = append(issues, "redirect chain")
+	} else if len(p.Redirects) == 1 {
+		issues = append(issues, "redirect")
+	}
+	if a.SlowThreshold > 0 && p.ResponseTime > a.SlowThreshold {
+		issues = append(issues, "slow response")
+	}
+	if p.StatusCode != http.StatusOK || !strings.Contains(strings.ToLower(p.ContentType), "html") {
+		return issues
+	}
+	if p.Title == "" {
+		issues = append(issues, "missing title")
+	} else if titles[p.Title] > 1 {
+		issues = append(issues, "duplicate title")
+	}
+	if p.MetaDescription == "" {
+		issues = append(issues, "missing meta description")
+	} else if descriptions[p.MetaDescription] > 1 {
+		issues = append(issues, "duplicate meta description")
+	}
+	if p.Canonical == "" {
+		issues = append(issues, "missing canonical")
+	} else if p.Canonical != p.FinalURL {
+		issues = append(issues, "canonicalized elsewhere")
+	}
+	return issues
+}
+
+func (a *SiteAudit) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a.Pages())
+}
+
+func (a *SiteAudit) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"url", "final_url", "status_code", "redirects", "response_time_ms", "size", "content_type", "title", "meta_description", "canonical", "depth", "error", "issues"})
+	for _, p := range a.Pages() {
+		cw.Write([]string{
+			p.URL,
+			p.FinalURL,
+			strconv.Itoa(p.StatusCode),
+			strings.Join(p.Redirects, " -> "),
+			strconv.FormatInt(p.ResponseTime.Milliseconds(), 10),
+			strconv.Itoa(p.Size),
+			p.ContentType,
+			p.Title,
+			p.MetaDescription,
+			p.Canonical,
+			strconv.Itoa(p.Depth),
+			p.Error,
+			strings.Join(p.Issues, "; "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,689 @@
+package colly
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/go-ntlmssp"
+	"golang.org/x/oauth2"
+)
+
+func (c *Collector) SignRequests(signer RequestSigner, domains ...string) {
+	c.lock.Lock()
+	c.signers = append(c.signers, &domainSigner{signer: signer, domains: domains})
+	c.lock.Unlock()
+}
+
+func (c *Collector) SetAuthenticator(auth Authenticator, domains ...string) {
+	c.lock.Lock()
+	c.authenticators = append(c.authenticators, &domainAuthenticator{auth: auth, domains: domains, seed: c.seedHost})
+	c.lock.Unlock()
+}
+
+func (c *Collector) bindSeedHost(host string) {
+	c.lock.RLock()
+	bound := c.seedHost != ""
+	c.lock.RUnlock()
+	if bound {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.seedHost != "" {
+		return
+	}
+	c.seedHost = strings.ToLower(host)
+	for _, a := range c.authenticators {
+		if a.seed == "" {
+			a.seed = c.seedHost
+		}
+	}
+}
+
+func (c *Collector) SetDigestCredentials(domain, username, password string) {
+	if c.digest == nil {
+		c.digest = NewDigestAuthenticator()
+	}
+	c.digest.SetCredentials(domain, username, password)
+	c.SetAuthenticator(c.digest, domain)
+}
+
+func (c *Collector) SetNTLMCredentials(domain, username, password string) {
+	c.httpTransport()
+	var t *ntlmTransport
+	base := c.baseTransport()
+	for t == nil {
+		w, ok := base.(chainedTransport)
+		if !ok {
+			break
+		}
+		t, _ = w.(*ntlmTransport)
+		base = w.inner()
+	}
+	if t == nil {
+		t = &ntlmTransport{
+			next:        c.baseTransport(),
+			credentials: make(map[string]hostCredentials),
+			lock:        &sync.RWMutex{},
+		}
+		c.setBaseTransport(t)
+	}
+	t.lock.Lock()
+	t.credentials[strings.ToLower(domain)] = hostCredentials{username: username, password: password}
+	t.lock.Unlock()
+}
+
+func (c *Collector) authenticatorFor(host string) Authenticator {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	host = strings.ToLower(host)
+	var best *domainAuthenticator
+	score := -1
+	for _, a := range c.authenticators {
+		if n := a.specificity(host); n > score {
+			best, score = a, n
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.auth
+}
+
+func (c *Collector) authenticate(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	auth := c.authenticatorFor(req.URL.Hostname())
+	if auth == nil {
+		return send(req)
+	}
+	req = req.Clone(req.Context())
+	if _, err := requestBody(req); err != nil {
+		return nil, err
+	}
+	if err := auth.Authorize(req); err != nil {
+		return nil, err
+	}
+	res, err := send(req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	retry, err := auth.Retry(req, res)
+	if err != nil || !retry {
+		return res, err
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	req = req.Clone(req.Context())
+	if req.GetBody != nil {
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if err := auth.Authorize(req); err != nil {
+		return nil, err
+	}
+	return send(req)
+}
+
+func (c *Collector) signRequest(req *http.Request) (*http.Request, error) {
+	c.lock.RLock()
+	signers := c.signers
+	c.lock.RUnlock()
+	if len(signers) == 0 {
+		return req, nil
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	signed := false
+	for _, s := range signers {
+		if !s.matches(host) {
+			continue
+		}
+		if !signed {
+			req = req.Clone(req.Context())
+			signed = true
+		}
+		body, err := requestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.signer.Sign(req, body); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func (t *ntlmTransport) inner() http.RoundTripper {
+	return t.next
+}
+
+func (t *ntlmTransport) setInner(next http.RoundTripper) {
+	t.next = next
+}
+
+func (t *ntlmTransport) withInner(next http.RoundTripper) http.RoundTripper {
+	copied := *t
+	copied.next = next
+	return &copied
+}
+
+type RequestSigner interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+type SignerFunc func(req *http.Request, body []byte) error
+
+func (f SignerFunc) Sign(req *http.Request, body []byte) error {
+	return f(req, body)
+}
+
+type domainSigner struct {
+	signer  RequestSigner
+	domains []string
+}
+
+func (s *domainSigner) matches(host string) bool {
+	return hostMatches(s.domains, host)
+}
+
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return data, nil
+}
+
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+	Now             func() time.Time
+}
+
+func (s *SigV4Signer) Sign(req *http.Request, body []byte) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	payloadHash := sha256.Sum256(body)
+	payload := hex.EncodeToString(payloadHash[:])
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" || lk == "content-md5" {
+			headers[lk] = strings.Join(strings.Fields(strings.Join(v, ",")), " ")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	uri := req.URL.EscapedPath()
+	if uri == "" {
+		uri = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uri,
+		sigV4Query(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+	scope := date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func sigV4Query(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), values[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, sigV4Escape(k)+"="+sigV4Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(url.QueryEscape(s), "+", "%20"), "%7E", "~")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+type HMACSigner struct {
+	KeyID   string
+	Key     []byte
+	Headers []string
+	Header  string
+	Now     func() time.Time
+}
+
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", now().UTC().Format(http.TimeFormat))
+	}
+	digest := sha256.Sum256(body)
+	req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest[:]))
+	names := s.Headers
+	if len(names) == 0 {
+		names = []string{"(request-target)", "host", "date", "digest"}
+	}
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	names = append([]string(nil), names...)
+	lines := make([]string, 0, len(names))
+	for i, name := range names {
+		name = strings.ToLower(name)
+		names[i] = name
+		switch name {
+		case "(request-target)":
+			lines = append(lines, name+": "+strings.ToLower(req.Method)+" "+req.URL.RequestURI())
+		case "host":
+			lines = append(lines, name+": "+host)
+		default:
+			lines = append(lines, name+": "+strings.Join(req.Header.Values(name), ", "))
+		}
+	}
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte(strings.Join(lines, "\n")))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	header := s.Header
+	if header == "" {
+		header = "Signature"
+	}
+	req.Header.Set(header, fmt.Sprintf(`keyId="%s",algorithm="hmac-sha256",headers="%s",signature="%s"`, s.KeyID, strings.Join(names, " "), signature))
+	return nil
+}
+
+type Authenticator interface {
+	Authorize(req *http.Request) error
+	Retry(req *http.Request, res *http.Response) (bool, error)
+}
+
+type domainAuthenticator struct {
+	auth    Authenticator
+	domains []string
+	seed    string
+}
+
+func (a *domainAuthenticator) specificity(host string) int {
+	if len(a.domains) == 0 {
+		if a.seed == host {
+			return len(host)
+		}
+		return -1
+	}
+	best := -1
+	for _, d := range a.domains {
+		if hostMatches([]string{d}, host) && len(d) > best {
+			best = len(d)
+		}
+	}
+	return best
+}
+
+type OAuth2Authenticator struct {
+	source oauth2.TokenSource
+	token  *oauth2.Token
+	lock   *sync.Mutex
+}
+
+func NewOAuth2Authenticator(source oauth2.TokenSource) *OAuth2Authenticator {
+	return &OAuth2Authenticator{
+		source: source,
+		lock:   &sync.Mutex{},
+	}
+}
+
+func (a *OAuth2Authenticator) Token() (*oauth2.Token, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.token.Valid() {
+		return a.token, nil
+	}
+	token, err := a.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	a.token = token
+	return token, nil
+}
+
+func (a *OAuth2Authenticator) Authorize(req *http.Request) error {
+	token, err := a.Token()
+	if err != nil {
+		return err
+	}
+	token.SetAuthHeader(req)
+	return nil
+}
+
+func (a *OAuth2Authenticator) Retry(req *http.Request, res *http.Response) (bool, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	sent := req.Header.Get("Authorization")
+	if a.token != nil && sent != a.token.Type()+" "+a.token.AccessToken {
+		return true, nil
+	}
+	token, err := a.source.Token()
+	if err != nil {
+		return false, err
+	}
+	if a.token != nil && token.AccessToken == a.token.AccessToken {
+		return false, nil
+	}
+	a.token = token
+	return true, nil
+}
+
+type DigestAuthenticator struct {
+	credentials map[string]hostCredentials
+	challenges  map[string]*digestChallenge
+	lock        *sync.Mutex
+}
+
+type hostCredentials struct {
+	username string
+	password string
+}
+
+func credentialsFor(credentials map[string]hostCredentials, host string) (hostCredentials, bool) {
+	host = strings.ToLower(host)
+	best, found := "", false
+	for domain := range credentials {
+		if !hostMatches([]string{domain}, host) {
+			continue
+		}
+		if !found || len(domain) > len(best) || len(domain) == len(best) && domain < best {
+			best, found = domain, true
+		}
+	}
+	return credentials[best], found
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	count     int
+}
+
+func NewDigestAuthenticator() *DigestAuthenticator {
+	return &DigestAuthenticator{
+		credentials: make(map[string]hostCredentials),
+		challenges:  make(map[string]*digestChallenge),
+		lock:        &sync.Mutex{},
+	}
+}
+
+func (a *DigestAuthenticator) SetCredentials(domain, username, password string) {
+	a.lock.Lock()
+	a.credentials[strings.ToLower(domain)] = hostCredentials{username: username, password: password}
+	a.lock.Unlock()
+}
+
+func (a *DigestAuthenticator) Authorize(req *http.Request) error {
+	host := strings.ToLower(req.URL.Host)
+	a.lock.Lock()
+	creds, ok := credentialsFor(a.credentials, req.URL.Hostname())
+	ch := a.challenges[host]
+	if !ok || ch == nil {
+		a.lock.Unlock()
+		return nil
+	}
+	ch.count++
+	nc := fmt.Sprintf("%08x", ch.count)
+	challenge := *ch
+	a.lock.Unlock()
+
+	var buf [8]byte
+	rand.Read(buf[:])
+	cnonce := hex.EncodeToString(buf[:])
+	h := digestHash(challenge.algorithm)
+	uri := req.URL.RequestURI()
+	ha1 := h(creds.username + ":" + challenge.realm + ":" + creds.password)
+	if strings.HasSuffix(strings.ToLower(challenge.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + challenge.nonce + ":" + cnonce)
+	}
+	ha2 := h(req.Method + ":" + uri)
+	if challenge.qop == "auth-int" {
+		body, err := requestBody(req)
+		if err != nil {
+			return err
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + h(string(body)))
+	}
+	var response string
+	if challenge.qop == "" {
+		response = h(ha1 + ":" + challenge.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + challenge.nonce + ":" + nc + ":" + cnonce + ":" + challenge.qop + ":" + ha2)
+	}
+	parts := []string{
+		fmt.Sprintf(`username="%s"`, creds.username),
+		fmt.Sprintf(`realm="%s"`, challenge.realm),
+		fmt.Sprintf(`nonce="%s"`, challenge.nonce),
+		fmt.Sprintf(`uri="%s"`, uri),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if challenge.algorithm != "" {
+		parts = append(parts, "algorithm="+challenge.algorithm)
+	}
+	if challenge.opaque != "" {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, challenge.opaque))
+	}
+	if challenge.qop != "" {
+		parts = append(parts, "qop="+challenge.qop, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(parts, ", "))
+	return nil
+}
+
+func (a *DigestAuthenticator) Retry(req *http.Request, res *http.Response) (bool, error) {
+	var params map[string]string
+	for _, v := range res.Header.Values("WWW-Authenticate") {
+		if len(v) > 7 && strings.EqualFold(v[:7], "digest ") {
+			params = parseAuthParams(v[7:])
+			break
+		}
+	}
+	if params == nil {
+		return false, nil
+	}
+	host := strings.ToLower(req.URL.Host)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if _, ok := credentialsFor(a.credentials, req.URL.Hostname()); !ok {
+		return false, nil
+	}
+	prev := a.challenges[host]
+	if prev != nil && prev.nonce == params["nonce"] && !strings.EqualFold(params["stale"], "true") && req.Header.Get("Authorization") != "" {
+		return false, nil
+	}
+	ch := &digestChallenge{
+		realm:     params["realm"],
+		nonce:     params["nonce"],
+		opaque:    params["opaque"],
+		algorithm: params["algorithm"],
+	}
+	for _, q := range strings.Split(params["qop"], ",") {
+		q = strings.TrimSpace(q)
+		if q == "auth" || (q == "auth-int" && ch.qop == "") {
+			ch.qop = q
+		}
+	}
+	a.challenges[host] = ch
+	return true, nil
+}
+
+func digestHash(algorithm string) func(string) string {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "SHA-256":
+		return func(s string) string {
+			sum := sha256.Sum256([]byte(s))
+			return hex.EncodeToString(sum[:])
+		}
+	}
+	return func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+}
+
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " ")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[name] = value
+	}
+	return params
+}
+
+type ntlmTransport struct {
+	next        http.RoundTripper
+	credentials map[string]hostCredentials
+	lock        *sync.RWMutex
+}
+
+func (t *ntlmTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t.lock.RLock()
+	creds, ok := credentialsFor(t.credentials, req.URL.Hostname())
+	t.lock.RUnlock()
+	if !ok || req.Header.Get("Authorization") != "" {
+		return next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(creds.username, creds.password)
+	return ntlmssp.Negotiator{RoundTripper: next}.RoundTrip(req)
+}
+
+func (t *ntlmTransport) httpTransport() *http.Transport {
+	switch next := t.next.(type) {
+	case *http.Transport:
+		return next
+	case transportWrapper:
+		return next.httpTransport()
+	case nil:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		t.next = transport
+		return transport
+	}
+	return nil
+}
+
+func (t *ntlmTransport) CloseIdleConnections() {
+	if ci, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,104 @@
+package colly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCredentialsForPrefersMostSpecificDomain(t *testing.T) {
+	credentials := map[string]hostCredentials{
+		"example.com":        {username: "root"},
+		"*.example.com":      {username: "wildcard"},
+		"api.example.com":    {username: "api"},
+		"v2.api.example.com": {username: "v2"},
+		"other.org":          {username: "other"},
+	}
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "root"},
+		{"www.example.com", "wildcard"},
+		{"api.example.com", "api"},
+		{"API.example.com", "api"},
+		{"v1.api.example.com", "api"},
+		{"v2.api.example.com", "v2"},
+		{"other.org", "other"},
+		{"unrelated.net", ""},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			creds, ok := credentialsFor(credentials, tt.host)
+			if ok != (tt.want != "") || creds.username != tt.want {
+				t.Fatalf("credentialsFor(%q) = %q, %v, want %q", tt.host, creds.username, ok, tt.want)
+			}
+		}
+	}
+}
+
+func TestDigestAuthenticatorUsesMostSpecificCredentials(t *testing.T) {
+	a := NewDigestAuthenticator()
+	a.SetCredentials("example.com", "root", "secret")
+	a.SetCredentials("*.example.com", "wildcard", "secret")
+	a.SetCredentials("api.example.com", "api", "secret")
+	a.challenges["api.example.com"] = &digestChallenge{realm: "r", nonce: "n", qop: "auth"}
+	for i := 0; i < 20; i++ {
+		req, _ := http.NewRequest("GET", "http://api.example.com/x", nil)
+		if err := a.Authorize(req); err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Header.Get("Authorization"); !strings.Contains(got, `username="api"`) {
+			t.Fatalf("expected the api.example.com credentials, got %s", got)
+		}
+	}
+}
+
+type staticAuthenticator string
+
+func (a staticAuthenticator) Authorize(req *http.Request) error {
+	req.Header.Set("Authorization", string(a))
+	return nil
+}
+
+func (a staticAuthenticator) Retry(req *http.Request, res *http.Response) (bool, error) {
+	return false, nil
+}
+
+func TestDomainlessAuthenticatorAppliesToSeedHost(t *testing.T) {
+	var lock sync.Mutex
+	authorized := make(map[string]string)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		authorized[r.Host+r.URL.Path] = r.Header.Get("Authorization")
+		lock.Unlock()
+	})
+	seed := httptest.NewServer(handler)
+	defer seed.Close()
+	other := httptest.NewServer(handler)
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	c := NewCollector()
+	c.OnResponse(func(r *Response) {
+		if r.Request.URL.Path != "/login" {
+			return
+		}
+		c.SetAuthenticator(staticAuthenticator("Bearer token"))
+		r.Request.Visit(otherURL + "/page")
+		r.Request.Visit("/api")
+	})
+	if err := c.Visit(seed.URL + "/login"); err != nil {
+		t.Fatal(err)
+	}
+	seedHost := strings.TrimPrefix(seed.URL, "http://")
+	otherHost := strings.TrimPrefix(otherURL, "http://")
+	if got := authorized[otherHost+"/page"]; got != "" {
+		t.Fatalf("expected no credentials for %s, got %q", otherHost, got)
+	}
+	if got := authorized[seedHost+"/api"]; got != "Bearer token" {
+		t.Fatalf("expected credentials for the seed host, got %q", got)
+	}
+}
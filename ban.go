@@ -0,0 +1,246 @@
+package colly
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+func (c *Collector) DetectBans(detector *BanDetector) {
+	if detector == nil {
+		detector = &BanDetector{}
+	}
+	detector.Init()
+	c.banDetector = detector
+}
+
+func (c *Collector) SetBlockPolicy(policy *BlockPolicy) {
+	if policy == nil {
+		policy = &BlockPolicy{}
+	}
+	policy.Init()
+	if c.banDetector == nil {
+		c.DetectBans(nil)
+	}
+	if c.coolDowns == nil {
+		c.coolDowns = &sync.Map{}
+	}
+	c.blockPolicy = policy
+}
+
+func (c *Collector) CoolDown(host string, d time.Duration) {
+	if c.coolDowns == nil {
+		c.coolDowns = &sync.Map{}
+	}
+	c.coolDowns.Store(strings.ToLower(host), time.Now().Add(d))
+}
+
+func (c *Collector) waitCoolDown(ctx context.Context, host string) error {
+	if c.coolDowns == nil {
+		return nil
+	}
+	v, ok := c.coolDowns.Load(strings.ToLower(host))
+	if !ok {
+		return nil
+	}
+	return sleepContext(ctx, time.Until(v.(time.Time)))
+}
+
+type BanReason string
+
+const (
+	BanStatus          BanReason = "status"
+	BanChallenge       BanReason = "challenge"
+	BanCaptchaRedirect BanReason = "captcha-redirect"
+	BanCustom          BanReason = "custom"
+)
+
+const banDetectorScanSize = 64 * 1024
+
+type Ban struct {
+	URL        *url.URL
+	StatusCode int
+	Reason     BanReason
+	Marker     string
+	ProxyURL   string
+	Response   *Response
+}
+
+type BlockedError struct {
+	Ban *Ban
+	Err error
+}
+
+func (e *BlockedError) Error() string {
+	msg := fmt.Sprintf("%s: %s (%s %d)", ErrBlocked, e.Ban.URL, e.Ban.Reason, e.Ban.StatusCode)
+	if e.Ban.Marker != "" {
+		msg = fmt.Sprintf("%s: %s (%s %q)", ErrBlocked, e.Ban.URL, e.Ban.Reason, e.Ban.Marker)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+func (e *BlockedError) Unwrap() error {
+	return e.Err
+}
+
+type BanDetector struct {
+	StatusCodes          []int
+	ChallengeStatusCodes []int
+	BodyMarkers          []string
+	HeaderMarkers        map[string]string
+	RedirectPatterns     []string
+	Detect               func(*Response) (BanReason, bool)
+	bodyMarkers          [][]byte
+}
+
+var (
+	defaultBanStatusCodes          = []int{http.StatusForbidden, http.StatusTooManyRequests}
+	defaultBanChallengeStatusCodes = []int{http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable}
+	defaultBanBodyMarkers          = []string{
+		"cf-chl-",
+		"cf_chl_opt",
+		"/cdn-cgi/challenge-platform/",
+		"attention required! | cloudflare",
+		"_pxcaptcha",
+		"px-captcha",
+		"captcha.px-cdn.net",
+		"geo.captcha-delivery.com",
+	}
+	defaultBanHeaderMarkers = map[string]string{
+		"cf-mitigated": "challenge",
+		"x-datadome":   "protected",
+	}
+	defaultBanRedirectPatterns = []string{"captcha", "/challenge", "/sorry/", "/blocked"}
+)
+
+func (d *BanDetector) Init() {
+	if d.StatusCodes == nil {
+		d.StatusCodes = defaultBanStatusCodes
+	}
+	if d.ChallengeStatusCodes == nil {
+		d.ChallengeStatusCodes = defaultBanChallengeStatusCodes
+	}
+	if d.BodyMarkers == nil {
+		d.BodyMarkers = defaultBanBodyMarkers
+	}
+	if d.HeaderMarkers == nil {
+		d.HeaderMarkers = defaultBanHeaderMarkers
+	}
+	if d.RedirectPatterns == nil {
+		d.RedirectPatterns = defaultBanRedirectPatterns
+	}
+	d.bodyMarkers = make([][]byte, len(d.BodyMarkers))
+	for i, m := range d.BodyMarkers {
+		d.bodyMarkers[i] = []byte(strings.ToLower(m))
+	}
+}
+
+func (d *BanDetector) Check(r *Response, sentURL *url.URL) *Ban {
+	ban := &Ban{
+		URL:        r.Request.URL,
+		StatusCode: r.StatusCode,
+		ProxyURL:   r.Request.ProxyURL,
+		Response:   r,
+	}
+	if d.Detect != nil {
+		if reason, ok := d.Detect(r); ok {
+			if reason == "" {
+				reason = BanCustom
+			}
+			ban.Reason = reason
+			return ban
+		}
+	}
+	if sentURL != nil && r.Request.URL.String() != sentURL.String() {
+		target := strings.ToLower(r.Request.URL.Host + r.Request.URL.RequestURI())
+		for _, p := range d.RedirectPatterns {
+			if strings.Contains(target, strings.ToLower(p)) {
+				ban.Reason = BanCaptchaRedirect
+				ban.Marker = p
+				return ban
+			}
+		}
+	}
+	if r.Headers != nil {
+		for name, value := range d.HeaderMarkers {
+			if v := r.Headers.Get(name); v != "" && (value == "" || strings.Contains(strings.ToLower(v), strings.ToLower(value))) {
+				ban.Reason = BanChallenge
+				ban.Marker = name
+				return ban
+			}
+		}
+	}
+	if d.challengeStatus(r.StatusCode) {
+		body := r.Body
+		if len(body) > banDetectorScanSize {
+			body = body[:banDetectorScanSize]
+		}
+		body = bytes.ToLower(body)
+		for i, m := range d.bodyMarkers {
+			if len(m) > 0 && bytes.Contains(body, m) {
+				ban.Reason = BanChallenge
+				ban.Marker = d.BodyMarkers[i]
+				return ban
+			}
+		}
+	}
+	for _, code := range d.StatusCodes {
+		if r.StatusCode == code {
+			ban.Reason = BanStatus
+			return ban
+		}
+	}
+	return nil
+}
+
+func (d *BanDetector) challengeStatus(statusCode int) bool {
+	for _, code := range d.ChallengeStatusCodes {
+		if statusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+type BlockPolicy struct {
+	MaxRetries      int
+	CoolDown        time.Duration
+	ProxyRetirement time.Duration
+}
+
+func (p *BlockPolicy) Init() {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = 2
+	}
+	if p.CoolDown == 0 {
+		p.CoolDown = 30 * time.Second
+	}
+	if p.ProxyRetirement == 0 {
+		p.ProxyRetirement = 10 * time.Minute
+	}
+}
+
+func (p *BlockPolicy) retry(attempt int) bool {
+	return attempt < p.MaxRetries
+}
+
+func (p *BlockPolicy) apply(c *Collector, ban *Ban, host string) {
+	if p.ProxyRetirement > 0 && ban.ProxyURL != "" {
+		c.retireProxy(ban.ProxyURL, host, p.ProxyRetirement)
+	}
+	if p.CoolDown > 0 {
+		c.CoolDown(host, p.CoolDown)
+	}
+}
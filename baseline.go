@@ -0,0 +1,119 @@
+package colly
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+func (c *Collector) SetBaseline(fingerprints map[string]string) {
+	baseline := make(map[string]string, len(fingerprints))
+	for u, fp := range fingerprints {
+		baseline[normalizeURL(u)] = fp
+	}
+	c.differ = &crawlDiffer{
+		baseline: baseline,
+		seen:     make(map[string]string),
+		failed:   make(map[string]bool),
+		lock:     &sync.Mutex{},
+	}
+}
+
+func (c *Collector) Fingerprints() map[string]string {
+	if c.differ == nil {
+		return nil
+	}
+	c.differ.lock.Lock()
+	defer c.differ.lock.Unlock()
+	fingerprints := make(map[string]string, len(c.differ.seen))
+	for u, fp := range c.differ.seen {
+		fingerprints[u] = fp
+	}
+	for u := range c.differ.failed {
+		if fp, ok := c.differ.baseline[u]; ok {
+			fingerprints[u] = fp
+		}
+	}
+	return fingerprints
+}
+
+func (c *Collector) Diff() *CrawlDiff {
+	if c.differ == nil {
+		return nil
+	}
+	return c.differ.diff()
+}
+
+type CrawlDiff struct {
+	New         []string
+	Disappeared []string
+	Changed     []string
+	Unchanged   []string
+	Failed      []string
+}
+
+type crawlDiffer struct {
+	baseline map[string]string
+	seen     map[string]string
+	failed   map[string]bool
+	lock     *sync.Mutex
+}
+
+func (d *crawlDiffer) record(u string, resp *Response, err error) {
+	u = normalizeURL(u)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err != nil || resp == nil || resp.StatusCode >= 500 {
+		if _, ok := d.seen[u]; !ok {
+			d.failed[u] = true
+		}
+		return
+	}
+	delete(d.failed, u)
+	fp := ""
+	if resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusGone {
+		fp = bodyFingerprint(resp.Body)
+	}
+	d.seen[u] = fp
+}
+
+func (d *crawlDiffer) diff() *CrawlDiff {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	diff := &CrawlDiff{}
+	for u, fp := range d.seen {
+		old, known := d.baseline[u]
+		switch {
+		case fp == "" && known:
+			diff.Disappeared = append(diff.Disappeared, u)
+		case fp == "":
+		case !known:
+			diff.New = append(diff.New, u)
+		case old != "" && old != fp:
+			diff.Changed = append(diff.Changed, u)
+		default:
+			diff.Unchanged = append(diff.Unchanged, u)
+		}
+	}
+	for u := range d.baseline {
+		if _, ok := d.seen[u]; !ok && !d.failed[u] {
+			diff.Disappeared = append(diff.Disappeared, u)
+		}
+	}
+	for u := range d.failed {
+		diff.Failed = append(diff.Failed, u)
+	}
+	sort.Strings(diff.New)
+	sort.Strings(diff.Disappeared)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Unchanged)
+	sort.Strings(diff.Failed)
+	return diff
+}
+
+func bodyFingerprint(body []byte) string {
+	sum := sha1.Sum(body)
+	return hex.EncodeToString(sum[:])
+}
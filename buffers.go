@@ -0,0 +1,91 @@
+package colly
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+type BufferPoolStats struct {
+	Gets      uint64
+	Puts      uint64
+	Allocs    uint64
+	Discarded uint64
+}
+
+const (
+	maxPooledBufferSize = 4 << 20
+	copyBufferSize      = 32 << 10
+)
+
+var (
+	bufferPoolStats BufferPoolStats
+	bufferPool      = sync.Pool{
+		New: func() interface{} {
+			atomic.AddUint64(&bufferPoolStats.Allocs, 1)
+			return &bytes.Buffer{}
+		},
+	}
+	copyBufferPool = sync.Pool{
+		New: func() interface{} {
+			atomic.AddUint64(&bufferPoolStats.Allocs, 1)
+			b := make([]byte, copyBufferSize)
+			return &b
+		},
+	}
+)
+
+func BufferPoolStatistics() BufferPoolStats {
+	return BufferPoolStats{
+		Gets:      atomic.LoadUint64(&bufferPoolStats.Gets),
+		Puts:      atomic.LoadUint64(&bufferPoolStats.Puts),
+		Allocs:    atomic.LoadUint64(&bufferPoolStats.Allocs),
+		Discarded: atomic.LoadUint64(&bufferPoolStats.Discarded),
+	}
+}
+
+func getBuffer() *bytes.Buffer {
+	atomic.AddUint64(&bufferPoolStats.Gets, 1)
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBufferSize {
+		atomic.AddUint64(&bufferPoolStats.Discarded, 1)
+		return
+	}
+	atomic.AddUint64(&bufferPoolStats.Puts, 1)
+	b.Reset()
+	bufferPool.Put(b)
+}
+
+func getCopyBuffer() *[]byte {
+	atomic.AddUint64(&bufferPoolStats.Gets, 1)
+	return copyBufferPool.Get().(*[]byte)
+}
+
+func putCopyBuffer(b *[]byte) {
+	atomic.AddUint64(&bufferPoolStats.Puts, 1)
+	copyBufferPool.Put(b)
+}
+
+func copyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	buf := getCopyBuffer()
+	defer putCopyBuffer(buf)
+	return io.CopyBuffer(dst, src, *buf)
+}
+
+func readBody(r io.Reader, sizeHint int64) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if sizeHint > 0 && sizeHint <= maxPooledBufferSize {
+		buf.Grow(int(sizeHint))
+	}
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	body := make([]byte, buf.Len())
+	copy(body, buf.Bytes())
+	return body, nil
+}
@@ -0,0 +1,76 @@
+package colly
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadBodyDoesNotAliasPooledBuffer(t *testing.T) {
+	first, err := readBody(strings.NewReader("first body"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		if _, err := readBody(strings.NewReader("XXXXXXXXXXXXXXXXXXXX"), 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(first) != "first body" {
+		t.Fatalf("body changed after the buffer was reused: %q", first)
+	}
+	empty, err := readBody(strings.NewReader(""), 0)
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Fatalf("expected an empty non-nil body, got %#v, %v", empty, err)
+	}
+}
+
+func TestRepairHTMLDoesNotAliasPooledBuffer(t *testing.T) {
+	a := RepairHTML([]byte("<div><p>a"))
+	RepairHTML([]byte("<section><p>bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"))
+	if string(a) != "<div><p>a</p></div>" {
+		t.Fatalf("unexpected repaired markup: %q", a)
+	}
+}
+
+func TestPutBufferDiscardsOversizedBuffers(t *testing.T) {
+	before := BufferPoolStatistics()
+	b := getBuffer()
+	b.Grow(maxPooledBufferSize + 1)
+	putBuffer(b)
+	after := BufferPoolStatistics()
+	if after.Discarded != before.Discarded+1 {
+		t.Fatalf("expected the oversized buffer to be discarded, stats %+v -> %+v", before, after)
+	}
+}
+
+func TestResponseBodiesUseBufferPool(t *testing.T) {
+	payload := bytes.Repeat([]byte("a"), 64<<10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer ts.Close()
+
+	c := NewCollector(AllowURLRevisit())
+	var bodies [][]byte
+	c.OnResponse(func(r *Response) {
+		bodies = append(bodies, r.Body)
+	})
+	before := BufferPoolStatistics()
+	for i := 0; i < 3; i++ {
+		if err := c.Visit(ts.URL); err != nil {
+			t.Fatal(err)
+		}
+	}
+	after := BufferPoolStatistics()
+	if after.Gets-before.Gets < 3 || after.Puts-before.Puts < 3 {
+		t.Fatalf("expected pooled reads for every response, stats %+v -> %+v", before, after)
+	}
+	for _, body := range bodies {
+		if !bytes.Equal(body, payload) {
+			t.Fatal("response body was corrupted by buffer reuse")
+		}
+	}
+}
@@ -0,0 +1,879 @@
+package colly
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type cacheEntry struct {
+	StatusCode int
+	Body       []byte
+	Headers    *http.Header
+	Checksum   []byte
+	Signature  []byte
+	Stored     time.Time
+	Codec      string
+	Redirects  []cacheRedirect
+	legacy     bool
+}
+
+type cacheRedirect struct {
+	URL        string
+	StatusCode int
+	Headers    http.Header
+}
+
+func (c *Collector) cache(request *http.Request, bodySize int, checkHeadersFunc checkHeadersFunc) (*Response, error) {
+	mode := requestCacheMode(request)
+	if c.offline {
+		mode = CacheOnlyIfCached
+	}
+	if state := c.requestState(request); state != nil {
+		if state.refetch && mode == CacheDefault {
+			mode = CacheRefresh
+		}
+		state.refetch = true
+		state.cacheWrite = nil
+		state.streamed = false
+		state.cached = false
+	}
+	if (c.CacheDir == "" && c.cacheStorage == nil) || request.Method != "GET" || request.Header.Get("Cache-Control") == "no-cache" || mode == CacheBypass {
+		if mode == CacheOnlyIfCached {
+			return nil, &NotCachedError{URL: request.URL.String(), Method: request.Method}
+		}
+		return c.backend.Do(request, bodySize, checkHeadersFunc)
+	}
+	filename := c.cacheKey(request.URL.String())
+	var entry *cacheEntry
+	err := os.ErrNotExist
+	if mode != CacheRefresh {
+		entry, err = c.readCacheEntry(filename, request.URL.String())
+	} else if len(c.changedCallbacks) > 0 {
+		if previous, err := c.readCacheEntry(filename, request.URL.String()); err == nil {
+			c.rememberPrevious(request, previous)
+		}
+	}
+	if err == nil {
+		if entry.Headers == nil {
+			entry.Headers = &http.Header{}
+		}
+		c.rememberPrevious(request, entry)
+		stale := !c.cacheEntryFresh(request.URL, entry)
+		if mode != CacheOnlyIfCached && c.cacheRevalidate && entry.StatusCode < 500 && hasCacheValidators(*entry.Headers) && (stale || !c.cacheExpires()) {
+			return c.revalidateCacheEntry(request, filename, entry, bodySize, checkHeadersFunc)
+		}
+		if !stale || mode == CacheOnlyIfCached {
+			checkHeadersFunc(request, entry.StatusCode, *entry.Headers)
+			if entry.StatusCode < 500 {
+				if state := c.requestState(request); state != nil {
+					state.restoreRedirects(entry.Redirects)
+					state.cached = true
+				}
+				c.cacheIndex.hit(c.cacheDir(), filename, !entry.legacy)
+				return &Response{StatusCode: entry.StatusCode, Body: entry.Body, Headers: entry.Headers}, nil
+			}
+		}
+	} else if storageErr := (*cacheStorageError)(nil); errors.As(err, &storageErr) {
+		if c.debugger != nil {
+			c.debugger.Event(createEvent("cacheUnavailable", 0, c.ID, map[string]string{
+				"url":   request.URL.String(),
+				"error": storageErr.err.Error(),
+			}))
+		}
+	} else if !os.IsNotExist(err) {
+		if c.debugger != nil {
+			c.debugger.Event(createEvent("cacheInvalid", 0, c.ID, map[string]string{
+				"url":   request.URL.String(),
+				"error": err.Error(),
+			}))
+		}
+		c.removeCacheEntry(filename, request.URL.String())
+	}
+	c.cacheIndex.miss(c.cacheDir())
+	if mode == CacheOnlyIfCached {
+		return nil, &NotCachedError{URL: request.URL.String(), Method: request.Method}
+	}
+	resp, err := c.backend.Do(request, bodySize, checkHeadersFunc)
+	if err != nil || resp.StatusCode >= 500 {
+		return resp, err
+	}
+	return resp, c.queueCacheEntry(request, filename, resp)
+}
+
+func (c *Collector) queueCacheEntry(request *http.Request, filename string, resp *Response) error {
+	state := c.requestState(request)
+	if state == nil {
+		c.cacheWriteFailed(request.URL.String(), c.writeCacheEntry(filename, request.URL.String(), resp, nil))
+		return nil
+	}
+	state.cacheWrite = func() error {
+		if spill := state.spill; spill != nil && spill.file != nil {
+			body, err := io.ReadAll(io.NewSectionReader(spill, 0, int64(len(spill.prefix))+spill.size))
+			if err != nil {
+				return err
+			}
+			full := *resp
+			full.Body = body
+			return c.writeCacheEntry(filename, request.URL.String(), &full, state.redirectHops())
+		}
+		return c.writeCacheEntry(filename, request.URL.String(), resp, state.redirectHops())
+	}
+	return nil
+}
+
+func (c *Collector) commitCacheEntry(state *requestState, r *Response) error {
+	write := state.cacheWrite
+	state.cacheWrite = nil
+	if write == nil || state.streamed || c.retryableResponse(r) {
+		return nil
+	}
+	if err := write(); errors.Is(err, ErrDiskQuotaExceeded) {
+		return err
+	} else if err != nil {
+		url := ""
+		if r.Request != nil {
+			url = r.Request.URL.String()
+		}
+		c.cacheWriteFailed(url, err)
+	}
+	return nil
+}
+
+func (c *Collector) cacheWriteFailed(url string, err error) {
+	if err == nil {
+		return
+	}
+	if c.debugger != nil {
+		c.debugger.Event(createEvent("cacheWriteFailed", 0, c.ID, map[string]string{
+			"url":   url,
+			"error": err.Error(),
+		}))
+	} else {
+		log.Println("Cache write failed:", url, err)
+	}
+}
+
+type cacheStorageError struct {
+	err error
+}
+
+func (e *cacheStorageError) Error() string {
+	return "cache storage: " + e.err.Error()
+}
+
+func (e *cacheStorageError) Unwrap() error {
+	return e.err
+}
+
+type domainMaxAge struct {
+	glob   string
+	maxAge time.Duration
+}
+
+func (c *Collector) SetCacheMaxAge(maxAge time.Duration) {
+	c.cacheMaxAge = maxAge
+}
+
+func (c *Collector) SetDomainCacheMaxAge(glob string, maxAge time.Duration) {
+	glob = strings.ToLower(glob)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ages := make([]domainMaxAge, 0, len(c.cacheDomainMaxAges)+1)
+	for _, a := range c.cacheDomainMaxAges {
+		if a.glob != glob {
+			ages = append(ages, a)
+		}
+	}
+	c.cacheDomainMaxAges = append(ages, domainMaxAge{glob: glob, maxAge: maxAge})
+}
+
+func (c *Collector) cacheExpires() bool {
+	return c.cacheMaxAge > 0 || len(c.cacheDomainMaxAges) > 0 || c.cacheHonorHeaders
+}
+
+func (c *Collector) cacheMaxAgeFor(host string) time.Duration {
+	c.lock.RLock()
+	ages := c.cacheDomainMaxAges
+	c.lock.RUnlock()
+	host = strings.ToLower(host)
+	for _, a := range ages {
+		if matchHostGlob(a.glob, host) {
+			return a.maxAge
+		}
+	}
+	return c.cacheMaxAge
+}
+
+func (c *Collector) cacheEntryFresh(u *url.URL, entry *cacheEntry) bool {
+	if !c.cacheExpires() {
+		return true
+	}
+	var expires time.Time
+	if maxAge := c.cacheMaxAgeFor(u.Hostname()); maxAge > 0 {
+		expires = entry.Stored.Add(maxAge)
+	}
+	if c.cacheHonorHeaders && entry.Headers != nil {
+		if headerExpires, ok := httpCacheExpiry(*entry.Headers, entry.Stored); ok && (expires.IsZero() || headerExpires.Before(expires)) {
+			expires = headerExpires
+		}
+	}
+	return expires.IsZero() || time.Now().Before(expires)
+}
+
+func httpCacheExpiry(h http.Header, stored time.Time) (time.Time, bool) {
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		switch name {
+		case "no-store", "no-cache":
+			return stored, true
+		case "max-age", "s-maxage":
+			if seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64); err == nil {
+				return stored.Add(time.Duration(seconds) * time.Second), true
+			}
+		}
+	}
+	if value := h.Get("Expires"); value != "" {
+		expires, err := http.ParseTime(value)
+		if err != nil {
+			return stored, true
+		}
+		return expires, true
+	}
+	return time.Time{}, false
+}
+
+func hasCacheValidators(h http.Header) bool {
+	return h.Get("ETag") != "" || h.Get("Last-Modified") != ""
+}
+
+func (c *Collector) revalidateCacheEntry(request *http.Request, filename string, entry *cacheEntry, bodySize int, checkHeadersFunc checkHeadersFunc) (*Response, error) {
+	if etag := entry.Headers.Get("ETag"); etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	if modified := entry.Headers.Get("Last-Modified"); modified != "" {
+		request.Header.Set("If-Modified-Since", modified)
+	}
+	defer request.Header.Del("If-None-Match")
+	defer request.Header.Del("If-Modified-Since")
+	resp, err := c.backend.Do(request, bodySize, func(req *http.Request, statusCode int, headers http.Header) bool {
+		if statusCode == http.StatusNotModified {
+			return checkHeadersFunc(req, entry.StatusCode, *entry.Headers)
+		}
+		return checkHeadersFunc(req, statusCode, headers)
+	})
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		c.cacheIndex.miss(c.cacheDir())
+		if resp.StatusCode >= 500 {
+			return resp, nil
+		}
+		return resp, c.queueCacheEntry(request, filename, resp)
+	}
+	c.cacheIndex.revalidated(c.cacheDir(), filename)
+	if resp.Headers != nil {
+		for _, name := range []string{"ETag", "Last-Modified", "Cache-Control", "Expires", "Date"} {
+			if value := resp.Headers.Get(name); value != "" {
+				entry.Headers.Set(name, value)
+			}
+		}
+	}
+	if state := c.requestState(request); state != nil {
+		state.restoreRedirects(entry.Redirects)
+	}
+	cached := &Response{StatusCode: entry.StatusCode, Body: entry.Body, Headers: entry.Headers}
+	return cached, c.queueCacheEntry(request, filename, cached)
+}
+
+func cacheFilename(cacheDir, u string) string {
+	sum := sha1.Sum([]byte(u))
+	hash := hex.EncodeToString(sum[:])
+	return filepath.Join(cacheDir, hash[:2], hash)
+}
+
+func (c *Collector) cacheDir() string {
+	if c.cacheStorage != nil {
+		return ""
+	}
+	return c.CacheDir
+}
+
+func (c *Collector) cacheKey(u string) string {
+	if c.cacheStorage == nil {
+		return cacheFilename(c.CacheDir, u)
+	}
+	sum := sha1.Sum([]byte(u))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Collector) removeCacheEntry(filename, u string) {
+	if c.cacheStorage != nil {
+		if storage, ok := c.cacheStorage.(URLCacheStorage); ok {
+			storage.DeleteURL(filename, u)
+			return
+		}
+		c.cacheStorage.Delete(filename)
+		return
+	}
+	if info, err := os.Stat(filename); err == nil && os.Remove(filename) == nil && c.diskQuota != nil {
+		c.diskQuota.release(info.Size())
+	}
+	c.cacheIndex.remove(c.CacheDir, filename)
+}
+
+func (c *Collector) readCacheEntry(filename, u string) (*cacheEntry, error) {
+	if c.cacheStorage != nil {
+		var data []byte
+		var err error
+		if storage, ok := c.cacheStorage.(URLCacheStorage); ok {
+			data, err = storage.GetURL(filename, u)
+		} else {
+			data, err = c.cacheStorage.Get(filename)
+		}
+		if errors.Is(err, ErrCacheMiss) {
+			return nil, os.ErrNotExist
+		}
+		if err != nil {
+			return nil, &cacheStorageError{err: err}
+		}
+		entry := &cacheEntry{}
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(entry); err != nil {
+			return nil, err
+		}
+		if err := entry.decompress(); err != nil {
+			return nil, err
+		}
+		if err := c.verifyCacheEntry(entry); err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &cacheStorageError{err: err}
+	}
+	defer file.Close()
+	entry := &cacheEntry{}
+	if err := gob.NewDecoder(file).Decode(entry); err != nil {
+		return nil, err
+	}
+	if entry.Stored.IsZero() {
+		if info, err := file.Stat(); err == nil {
+			entry.Stored = info.ModTime()
+			entry.legacy = true
+		}
+	}
+	if err := entry.decompress(); err != nil {
+		return nil, err
+	}
+	if err := c.verifyCacheEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (c *Collector) writeCacheEntry(filename, u string, resp *Response, redirects []RedirectHop) error {
+	entry := &cacheEntry{
+		StatusCode: resp.StatusCode,
+		Body:       resp.Body,
+		Headers:    resp.Headers,
+		Stored:     time.Now(),
+	}
+	for _, hop := range redirects {
+		entry.Redirects = append(entry.Redirects, cacheRedirect{URL: hop.URL.String(), StatusCode: hop.StatusCode, Headers: hop.Headers})
+	}
+	entry.Checksum = cacheEntryChecksum(entry)
+	if c.cacheHMACKey != nil {
+		entry.Signature = c.cacheEntrySignature(entry)
+	}
+	if c.cacheCompress {
+		entry.compress()
+	}
+	if c.cacheStorage != nil {
+		buf := &bytes.Buffer{}
+		if err := gob.NewEncoder(buf).Encode(entry); err != nil {
+			return err
+		}
+		var err error
+		if storage, ok := c.cacheStorage.(URLCacheStorage); ok {
+			err = storage.SetURL(filename, u, buf.Bytes())
+		} else {
+			err = c.cacheStorage.Set(filename, buf.Bytes())
+		}
+		if err != nil {
+			return err
+		}
+		c.cacheIndex.written()
+		return nil
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := gob.NewEncoder(buf).Encode(entry); err != nil {
+		return err
+	}
+	dir := filepath.Dir(filename)
+	if _, err := os.Stat(dir); err != nil {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return err
+		}
+	}
+	size := int64(buf.Len())
+	if info, err := os.Stat(filename); err == nil {
+		size -= info.Size()
+	}
+	if c.diskQuota != nil {
+		if err := c.diskQuota.reserve(c.CacheDir, size); err != nil {
+			return err
+		}
+	}
+	if err := c.stageCacheFile(filename, buf.Bytes()); err != nil {
+		if c.diskQuota != nil {
+			c.diskQuota.release(size)
+		}
+		return err
+	}
+	if freed := c.cacheIndex.store(c.CacheDir, filename, int64(buf.Len())); freed > 0 && c.diskQuota != nil {
+		c.diskQuota.release(freed)
+	}
+	return nil
+}
+
+func (c *Collector) stageCacheFile(filename string, data []byte) error {
+	if c.TempDir != "" {
+		file, err := os.CreateTemp(c.TempDir, "colly-cache-*")
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(data)
+		file.Close()
+		if err == nil {
+			err = os.Rename(file.Name(), filename)
+		}
+		if err == nil {
+			return nil
+		}
+		os.Remove(file.Name())
+		if !errors.Is(err, syscall.EXDEV) {
+			return err
+		}
+	}
+	if err := os.WriteFile(filename+"~", data, 0640); err != nil {
+		os.Remove(filename + "~")
+		return err
+	}
+	return os.Rename(filename+"~", filename)
+}
+
+func cacheEntryChecksum(entry *cacheEntry) []byte {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, int64(entry.StatusCode))
+	binary.Write(h, binary.BigEndian, int64(len(entry.Body)))
+	h.Write(entry.Body)
+	if entry.Headers != nil {
+		entry.Headers.Write(h)
+	}
+	return h.Sum(nil)
+}
+
+func (c *Collector) cacheEntrySignature(entry *cacheEntry) []byte {
+	mac := hmac.New(sha256.New, c.cacheHMACKey)
+	mac.Write(entry.Checksum)
+	if entry.Headers != nil {
+		entry.Headers.Write(mac)
+	}
+	return mac.Sum(nil)
+}
+
+func (c *Collector) verifyCacheEntry(entry *cacheEntry) error {
+	if entry.Checksum == nil {
+		if c.cacheHMACKey != nil {
+			return errors.New("unsigned cache entry")
+		}
+		return nil
+	}
+	if !bytes.Equal(entry.Checksum, cacheEntryChecksum(entry)) {
+		return errors.New("cache entry checksum mismatch")
+	}
+	if c.cacheHMACKey != nil && !hmac.Equal(entry.Signature, c.cacheEntrySignature(entry)) {
+		return errors.New("cache entry signature mismatch")
+	}
+	return nil
+}
+
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Revalidations uint64
+	Writes        uint64
+	Evictions     uint64
+	Entries       int
+	Bytes         int64
+	MaxBytes      int64
+}
+
+type cacheIndex struct {
+	maxBytes      int64
+	bytes         int64
+	entries       map[string]*list.Element
+	order         *list.List
+	scanned       bool
+	hits          uint64
+	misses        uint64
+	revalidations uint64
+	writes        uint64
+	evictions     uint64
+	lock          *sync.Mutex
+}
+
+type cacheIndexEntry struct {
+	path string
+	size int64
+}
+
+func newCacheIndex() *cacheIndex {
+	return &cacheIndex{
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		lock:    &sync.Mutex{},
+	}
+}
+
+func (c *Collector) SetCacheMaxSize(maxBytes int64) {
+	c.cacheIndex.lock.Lock()
+	defer c.cacheIndex.lock.Unlock()
+	c.cacheIndex.maxBytes = maxBytes
+	if c.cacheIndex.scanned {
+		c.cacheIndex.evict("")
+	}
+}
+
+func (c *Collector) CacheStats() CacheStats {
+	x := c.cacheIndex
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.scan(c.cacheDir())
+	return CacheStats{
+		Hits:          x.hits,
+		Misses:        x.misses,
+		Revalidations: x.revalidations,
+		Writes:        x.writes,
+		Evictions:     x.evictions,
+		Entries:       len(x.entries),
+		Bytes:         x.bytes,
+		MaxBytes:      x.maxBytes,
+	}
+}
+
+func (x *cacheIndex) scan(cacheDir string) {
+	if x.scanned || cacheDir == "" {
+		return
+	}
+	x.scanned = true
+	type found struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []found
+	filepath.Walk(cacheDir, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !strings.HasSuffix(p, "~") {
+			files = append(files, found{p, info.Size(), info.ModTime()})
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if _, ok := x.entries[f.path]; ok {
+			continue
+		}
+		x.entries[f.path] = x.order.PushFront(&cacheIndexEntry{path: f.path, size: f.size})
+		x.bytes += f.size
+	}
+	x.evict("")
+}
+
+func (x *cacheIndex) hit(cacheDir, filename string, touchFile bool) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.scan(cacheDir)
+	x.hits++
+	x.touch(filename)
+	if cacheDir != "" && touchFile {
+		now := time.Now()
+		os.Chtimes(filename, now, now)
+	}
+}
+
+func (x *cacheIndex) revalidated(cacheDir, filename string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.scan(cacheDir)
+	x.hits++
+	x.revalidations++
+	x.touch(filename)
+}
+
+func (x *cacheIndex) miss(cacheDir string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.scan(cacheDir)
+	x.misses++
+}
+
+func (x *cacheIndex) written() {
+	x.lock.Lock()
+	x.writes++
+	x.lock.Unlock()
+}
+
+func (x *cacheIndex) touch(filename string) {
+	if el, ok := x.entries[filename]; ok {
+		x.order.MoveToFront(el)
+	}
+}
+
+func (x *cacheIndex) remove(cacheDir, filename string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.scan(cacheDir)
+	if el, ok := x.entries[filename]; ok {
+		x.bytes -= el.Value.(*cacheIndexEntry).size
+		x.order.Remove(el)
+		delete(x.entries, filename)
+	}
+}
+
+func (x *cacheIndex) store(cacheDir, filename string, size int64) int64 {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.scan(cacheDir)
+	x.writes++
+	if el, ok := x.entries[filename]; ok {
+		e := el.Value.(*cacheIndexEntry)
+		x.bytes += size - e.size
+		e.size = size
+		x.order.MoveToFront(el)
+	} else {
+		x.entries[filename] = x.order.PushFront(&cacheIndexEntry{path: filename, size: size})
+		x.bytes += size
+	}
+	return x.evict(filename)
+}
+
+func (x *cacheIndex) evict(keep string) int64 {
+	var freed int64
+	for x.maxBytes > 0 && x.bytes > x.maxBytes {
+		el := x.order.Back()
+		if el == nil {
+			break
+		}
+		e := el.Value.(*cacheIndexEntry)
+		if e.path == keep {
+			break
+		}
+		os.Remove(e.path)
+		x.order.Remove(el)
+		delete(x.entries, e.path)
+		x.bytes -= e.size
+		freed += e.size
+		x.evictions++
+	}
+	return freed
+}
+
+type CacheStorage interface {
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+type URLCacheStorage interface {
+	CacheStorage
+	GetURL(key, u string) ([]byte, error)
+	SetURL(key, u string, value []byte) error
+	DeleteURL(key, u string) error
+}
+
+func (c *Collector) SetCacheStorage(storage CacheStorage) {
+	c.cacheStorage = storage
+}
+
+const cacheCodecZstd = "zstd"
+
+var (
+	cacheCodecOnce sync.Once
+	cacheEncoder   *zstd.Encoder
+	cacheDecoder   *zstd.Decoder
+)
+
+func cacheCodecs() (*zstd.Encoder, *zstd.Decoder) {
+	cacheCodecOnce.Do(func() {
+		cacheEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		cacheDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	})
+	return cacheEncoder, cacheDecoder
+}
+
+func (e *cacheEntry) compress() {
+	if e.Codec != "" || len(e.Body) == 0 {
+		return
+	}
+	enc, _ := cacheCodecs()
+	compressed := enc.EncodeAll(e.Body, make([]byte, 0, len(e.Body)/4))
+	if len(compressed) < len(e.Body) {
+		e.Body = compressed
+		e.Codec = cacheCodecZstd
+	}
+}
+
+func (e *cacheEntry) decompress() error {
+	switch e.Codec {
+	case "":
+		return nil
+	case cacheCodecZstd:
+		_, dec := cacheCodecs()
+		body, err := dec.DecodeAll(e.Body, nil)
+		if err != nil {
+			return err
+		}
+		e.Body = body
+		e.Codec = ""
+		return nil
+	}
+	return fmt.Errorf("Unknown cache codec %q", e.Codec)
+}
+
+type CacheMode int
+
+const (
+	CacheDefault CacheMode = iota
+	CacheBypass
+	CacheRefresh
+	CacheOnlyIfCached
+)
+
+type VisitOption func(opts *visitOptions)
+
+type visitOptions struct {
+	cacheMode CacheMode
+}
+
+func NoCache() VisitOption {
+	return WithCacheMode(CacheBypass)
+}
+
+func Refresh() VisitOption {
+	return WithCacheMode(CacheRefresh)
+}
+
+func OnlyIfCached() VisitOption {
+	return WithCacheMode(CacheOnlyIfCached)
+}
+
+func WithCacheMode(mode CacheMode) VisitOption {
+	return func(opts *visitOptions) {
+		opts.cacheMode = mode
+	}
+}
+
+func (c *Collector) VisitWith(URL string, opts ...VisitOption) error {
+	options := &visitOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	ctx := NewContext()
+	if c.CheckHead {
+		if check := c.scrapeWith(URL, "HEAD", 1, nil, ctx, nil, true, c.Async, options.cacheMode); check != nil {
+			return check
+		}
+	}
+	return c.scrapeWith(URL, "GET", 1, nil, ctx, nil, true, c.Async, options.cacheMode)
+}
+
+func (r *Request) SetCacheMode(mode CacheMode) {
+	if r.collector == nil {
+		return
+	}
+	if v, ok := r.collector.requestStates.Load(r); ok {
+		state := v.(*requestState)
+		state.lock.Lock()
+		state.cacheMode = mode
+		state.lock.Unlock()
+	}
+}
+
+func (r *Request) CacheMode() CacheMode {
+	if r.collector == nil {
+		return CacheDefault
+	}
+	v, ok := r.collector.requestStates.Load(r)
+	if !ok {
+		return CacheDefault
+	}
+	state := v.(*requestState)
+	state.lock.Lock()
+	defer state.lock.Unlock()
+	return state.cacheMode
+}
+
+func requestCacheMode(req *http.Request) CacheMode {
+	if r, ok := req.Context().Value(requestContextKey).(*Request); ok {
+		return r.CacheMode()
+	}
+	return CacheDefault
+}
+
+type NotCachedError struct {
+	URL    string
+	Method string
+}
+
+func (e *NotCachedError) Error() string {
+	return fmt.Sprintf("%s: %s %s", ErrNotCached, e.Method, e.URL)
+}
+
+func (e *NotCachedError) Is(target error) bool {
+	return target == ErrNotCached
+}
+
+func (c *Collector) SetOffline(offline bool) {
+	c.offline = offline
+	if c.proxySwitcher != nil {
+		c.proxySwitcher.setOffline(offline)
+	}
+	for _, dp := range c.domainProxies {
+		if dp.switcher != nil {
+			dp.switcher.setOffline(offline)
+		}
+	}
+}
+
+func (c *Collector) IsOffline() bool {
+	return c.offline
+}
@@ -0,0 +1,317 @@
+package colly
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVerifyCacheEntryDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(e *cacheEntry)
+		ok     bool
+	}{
+		{"untouched", func(e *cacheEntry) {}, true},
+		{"status", func(e *cacheEntry) { e.StatusCode = 404 }, false},
+		{"body", func(e *cacheEntry) { e.Body = []byte("changed") }, false},
+		{"header value", func(e *cacheEntry) { e.Headers.Set("Content-Type", "application/json") }, false},
+		{"added header", func(e *cacheEntry) { e.Headers.Set("Set-Cookie", "a=b") }, false},
+		{"removed headers", func(e *cacheEntry) { e.Headers = nil }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{"Content-Type": {"text/html"}}
+			entry := &cacheEntry{StatusCode: 200, Body: []byte("<p>hi</p>"), Headers: &headers}
+			entry.Checksum = cacheEntryChecksum(entry)
+			tt.tamper(entry)
+			if err := NewCollector().verifyCacheEntry(entry); (err == nil) != tt.ok {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestCacheHitSurvivesIndexRebuild(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+
+	c := NewCollector(CacheDir(dir))
+	for _, p := range []string{"/a", "/b"} {
+		if err := c.Visit(ts.URL + p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b := cacheFilename(dir, ts.URL+"/a"), cacheFilename(dir, ts.URL+"/b")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(a, old, old)
+	os.Chtimes(b, old.Add(time.Minute), old.Add(time.Minute))
+
+	c = NewCollector(CacheDir(dir), AllowURLRevisit())
+	if err := c.Visit(ts.URL + "/a"); err != nil {
+		t.Fatal(err)
+	}
+	if stats := c.CacheStats(); stats.Hits != 1 {
+		t.Fatalf("expected a cache hit, got %+v", stats)
+	}
+	info, err := os.Stat(a)
+	if err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Fatalf("expected the hit to refresh the modification time, got %v, %v", info.ModTime(), err)
+	}
+
+	size := info.Size()
+	c = NewCollector(CacheDir(dir))
+	c.SetCacheMaxSize(2*size + size/2)
+	if err := c.Visit(ts.URL + "/c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(a); err != nil {
+		t.Fatalf("expected the recently read entry to survive eviction: %v", err)
+	}
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Fatalf("expected the least recently used entry to be evicted, got %v", err)
+	}
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	lastModified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+	tests := []struct {
+		name          string
+		handler       func(w http.ResponseWriter, r *http.Request, n int32) bool
+		body          string
+		revalidations uint64
+	}{
+		{"etag unchanged", func(w http.ResponseWriter, r *http.Request, n int32) bool {
+			w.Header().Set("ETag", `"v1"`)
+			return r.Header.Get("If-None-Match") == `"v1"`
+		}, "v1", 1},
+		{"last-modified unchanged", func(w http.ResponseWriter, r *http.Request, n int32) bool {
+			w.Header().Set("Last-Modified", lastModified)
+			return r.Header.Get("If-Modified-Since") == lastModified
+		}, "v1", 1},
+		{"etag changed", func(w http.ResponseWriter, r *http.Request, n int32) bool {
+			w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, n))
+			return r.Header.Get("If-None-Match") == fmt.Sprintf(`"v%d"`, n)
+		}, "v2", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				if tt.handler(w, r, n) {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				fmt.Fprintf(w, "v%d", n)
+			}))
+			defer ts.Close()
+
+			c := NewCollector(CacheDir(t.TempDir()), RevalidateCache(), AllowURLRevisit())
+			var bodies []string
+			c.OnResponse(func(r *Response) {
+				bodies = append(bodies, fmt.Sprintf("%d %s", r.StatusCode, r.Body))
+			})
+			for i := 0; i < 2; i++ {
+				if err := c.Visit(ts.URL); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if requests != 2 || len(bodies) != 2 || bodies[0] != "200 v1" || bodies[1] != "200 "+tt.body {
+				t.Fatalf("expected a conditional request serving %q, got %d requests and %q", tt.body, requests, bodies)
+			}
+			if stats := c.CacheStats(); stats.Revalidations != tt.revalidations {
+				t.Fatalf("expected %d revalidations, got %+v", tt.revalidations, stats)
+			}
+		})
+	}
+}
+
+func TestHTTPCacheExpiry(t *testing.T) {
+	stored := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers http.Header
+		expires time.Time
+		ok      bool
+	}{
+		{"none", http.Header{}, time.Time{}, false},
+		{"max-age", http.Header{"Cache-Control": {"public, max-age=60"}}, stored.Add(time.Minute), true},
+		{"quoted s-maxage", http.Header{"Cache-Control": {`s-maxage="30"`}}, stored.Add(30 * time.Second), true},
+		{"no-store", http.Header{"Cache-Control": {"No-Store"}}, stored, true},
+		{"no-cache", http.Header{"Cache-Control": {"no-cache"}}, stored, true},
+		{"expires", http.Header{"Expires": {stored.Add(time.Hour).Format(http.TimeFormat)}}, stored.Add(time.Hour), true},
+		{"invalid expires", http.Header{"Expires": {"0"}}, stored, true},
+		{"max-age wins over expires", http.Header{"Cache-Control": {"max-age=10"}, "Expires": {stored.Add(time.Hour).Format(http.TimeFormat)}}, stored.Add(10 * time.Second), true},
+	}
+	for _, tt := range tests {
+		expires, ok := httpCacheExpiry(tt.headers, stored)
+		if !expires.Equal(tt.expires) || ok != tt.ok {
+			t.Errorf("%s: expected %v %v, got %v %v", tt.name, tt.expires, tt.ok, expires, ok)
+		}
+	}
+}
+
+func TestCacheEntryFresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []CollectorOption
+		host    string
+		age     time.Duration
+		headers http.Header
+		fresh   bool
+	}{
+		{"no expiry configured", nil, "example.com", 24 * time.Hour, http.Header{}, true},
+		{"within max age", []CollectorOption{CacheMaxAge(time.Hour)}, "example.com", 30 * time.Minute, http.Header{}, true},
+		{"past max age", []CollectorOption{CacheMaxAge(time.Hour)}, "example.com", 2 * time.Hour, http.Header{}, false},
+		{"domain rule overrides", []CollectorOption{CacheMaxAge(time.Hour), DomainCacheMaxAge("*.example.com", time.Minute)}, "www.example.com", 30 * time.Minute, http.Header{}, false},
+		{"domain rule for another host", []CollectorOption{CacheMaxAge(time.Hour), DomainCacheMaxAge("*.example.com", time.Minute)}, "example.org", 30 * time.Minute, http.Header{}, true},
+		{"headers ignored by default", []CollectorOption{CacheMaxAge(time.Hour)}, "example.com", 5 * time.Minute, http.Header{"Cache-Control": {"max-age=60"}}, true},
+		{"shorter header lifetime wins", []CollectorOption{CacheMaxAge(time.Hour), HonorCacheHeaders()}, "example.com", 5 * time.Minute, http.Header{"Cache-Control": {"max-age=60"}}, false},
+		{"longer header lifetime is capped", []CollectorOption{CacheMaxAge(time.Minute), HonorCacheHeaders()}, "example.com", 5 * time.Minute, http.Header{"Cache-Control": {"max-age=3600"}}, false},
+		{"headers alone", []CollectorOption{HonorCacheHeaders()}, "example.com", 5 * time.Minute, http.Header{"Cache-Control": {"max-age=3600"}}, true},
+		{"no headers with headers honored", []CollectorOption{HonorCacheHeaders()}, "example.com", 24 * time.Hour, http.Header{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector(tt.options...)
+			entry := &cacheEntry{Headers: &tt.headers, Stored: time.Now().Add(-tt.age)}
+			if got := c.cacheEntryFresh(&url.URL{Scheme: "http", Host: tt.host}, entry); got != tt.fresh {
+				t.Fatalf("expected fresh=%v", tt.fresh)
+			}
+		})
+	}
+}
+
+func TestExpiredCacheEntryIsRefetched(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "v%d", atomic.AddInt32(&requests, 1))
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+
+	c := NewCollector(CacheDir(dir), CacheMaxAge(time.Hour), AllowURLRevisit())
+	var body string
+	c.OnResponse(func(r *Response) {
+		body = string(r.Body)
+	})
+	for _, age := range []time.Duration{0, time.Minute, 2 * time.Hour} {
+		if age > 0 {
+			ageCacheEntry(t, cacheFilename(dir, ts.URL+"/"), age)
+		}
+		if err := c.Visit(ts.URL + "/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 2 || body != "v2" {
+		t.Fatalf("expected only the expired entry to be refetched, got %d requests and %q", requests, body)
+	}
+}
+
+func ageCacheEntry(t *testing.T, filename string, age time.Duration) {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &cacheEntry{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.Stored = time.Now().Add(-age)
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheEntryCompression(t *testing.T) {
+	random := make([]byte, 512)
+	rand.Read(random)
+	tests := []struct {
+		name  string
+		body  []byte
+		codec string
+	}{
+		{"compressible", []byte(strings.Repeat("<p>hello</p>", 200)), cacheCodecZstd},
+		{"incompressible", random, ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &cacheEntry{Body: tt.body}
+			entry.compress()
+			if entry.Codec != tt.codec {
+				t.Fatalf("expected codec %q, got %q", tt.codec, entry.Codec)
+			}
+			if tt.codec != "" && len(entry.Body) >= len(tt.body) {
+				t.Fatalf("expected a smaller body, got %d bytes from %d", len(entry.Body), len(tt.body))
+			}
+			if err := entry.decompress(); err != nil || !bytes.Equal(entry.Body, tt.body) || entry.Codec != "" {
+				t.Fatalf("expected the original body back, got %d bytes, codec %q, %v", len(entry.Body), entry.Codec, err)
+			}
+		})
+	}
+	if err := (&cacheEntry{Codec: "lz4", Body: []byte("x")}).decompress(); err == nil {
+		t.Fatal("expected an unknown codec to be rejected")
+	}
+}
+
+func TestCompressedCacheIsReadable(t *testing.T) {
+	payload := strings.Repeat("<p>hello</p>", 200)
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(payload))
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+
+	if err := NewCollector(CacheDir(dir), CompressCache()).Visit(ts.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(cacheFilename(dir, ts.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := &cacheEntry{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Codec != cacheCodecZstd || len(stored.Body) >= len(payload) {
+		t.Fatalf("expected a zstd body on disk, got codec %q with %d bytes", stored.Codec, len(stored.Body))
+	}
+
+	for _, options := range [][]CollectorOption{{CompressCache()}, nil} {
+		c := NewCollector(append(options, CacheDir(dir))...)
+		var body string
+		c.OnResponse(func(r *Response) {
+			body = string(r.Body)
+		})
+		if err := c.Visit(ts.URL + "/"); err != nil {
+			t.Fatal(err)
+		}
+		if body != payload {
+			t.Fatalf("expected the decompressed body, got %d bytes", len(body))
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected both collectors to hit the compressed entry, got %d requests", requests)
+	}
+}
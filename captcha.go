@@ -0,0 +1,182 @@
+package colly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+func (c *Collector) SetCaptchaSolver(solver CaptchaSolver) {
+	if c.banDetector == nil {
+		c.DetectBans(nil)
+	}
+	c.captchaSolver = solver
+}
+
+type CaptchaSolver interface {
+	Solve(ctx context.Context, r *Response) (*CaptchaSolution, error)
+}
+
+type CaptchaSolverFunc func(ctx context.Context, r *Response) (*CaptchaSolution, error)
+
+func (f CaptchaSolverFunc) Solve(ctx context.Context, r *Response) (*CaptchaSolution, error) {
+	return f(ctx, r)
+}
+
+type CaptchaSolution struct {
+	Cookies []*http.Cookie
+	Headers http.Header
+	Params  url.Values
+}
+
+func (c *Collector) applyCaptchaSolution(req *http.Request, origURL *url.URL, solution *CaptchaSolution) {
+	if len(solution.Cookies) > 0 && c.backend.Client.Jar != nil {
+		c.backend.Client.Jar.SetCookies(origURL, solution.Cookies)
+	}
+	for k, v := range solution.Headers {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	if len(solution.Params) > 0 {
+		u := *origURL
+		q := u.Query()
+		for k, v := range solution.Params {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+		req.URL = &u
+	} else {
+		req.URL = origURL
+	}
+}
+
+type NoopCaptchaSolver struct{}
+
+func (NoopCaptchaSolver) Solve(ctx context.Context, r *Response) (*CaptchaSolution, error) {
+	return nil, nil
+}
+
+type CaptchaKind string
+
+const (
+	CaptchaUnknown   CaptchaKind = "unknown"
+	CaptchaReCAPTCHA CaptchaKind = "recaptcha"
+	CaptchaHCaptcha  CaptchaKind = "hcaptcha"
+	CaptchaTurnstile CaptchaKind = "turnstile"
+)
+
+var captchaSiteKey = regexp.MustCompile(`data-sitekey=["']([^"']+)["']`)
+
+func DetectCaptcha(r *Response) (CaptchaKind, string) {
+	body := bytes.ToLower(r.Body)
+	kind := CaptchaUnknown
+	switch {
+	case bytes.Contains(body, []byte("cf-turnstile")) || bytes.Contains(body, []byte("challenges.cloudflare.com/turnstile")):
+		kind = CaptchaTurnstile
+	case bytes.Contains(body, []byte("h-captcha")) || bytes.Contains(body, []byte("hcaptcha.com")):
+		kind = CaptchaHCaptcha
+	case bytes.Contains(body, []byte("g-recaptcha")) || bytes.Contains(body, []byte("google.com/recaptcha")):
+		kind = CaptchaReCAPTCHA
+	}
+	siteKey := ""
+	if m := captchaSiteKey.FindSubmatch(r.Body); m != nil {
+		siteKey = string(m[1])
+	}
+	return kind, siteKey
+}
+
+func (k CaptchaKind) responseParam() string {
+	switch k {
+	case CaptchaReCAPTCHA:
+		return "g-recaptcha-response"
+	case CaptchaHCaptcha:
+		return "h-captcha-response"
+	case CaptchaTurnstile:
+		return "cf-turnstile-response"
+	}
+	return ""
+}
+
+type HTTPCaptchaSolver struct {
+	Endpoint string
+	APIKey   string
+	Client   *http.Client
+}
+
+type captchaTask struct {
+	URL       string      `json:"url"`
+	Kind      CaptchaKind `json:"kind"`
+	SiteKey   string      `json:"site_key,omitempty"`
+	Proxy     string      `json:"proxy,omitempty"`
+	UserAgent string      `json:"user_agent,omitempty"`
+}
+
+type captchaResult struct {
+	Token      string            `json:"token"`
+	TokenParam string            `json:"token_param"`
+	Cookies    map[string]string `json:"cookies"`
+	Headers    map[string]string `json:"headers"`
+	Error      string            `json:"error"`
+}
+
+func (s *HTTPCaptchaSolver) Solve(ctx context.Context, r *Response) (*CaptchaSolution, error) {
+	kind, siteKey := DetectCaptcha(r)
+	task := captchaTask{
+		URL:     r.Request.URL.String(),
+		Kind:    kind,
+		SiteKey: siteKey,
+		Proxy:   r.Request.ProxyURL,
+	}
+	if r.Request.Headers != nil {
+		task.UserAgent = r.Request.Headers.Get("User-Agent")
+	}
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("captcha solver: %s", res.Status)
+	}
+	var result captchaResult
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("captcha solver: %s", result.Error)
+	}
+	solution := &CaptchaSolution{Headers: http.Header{}}
+	for name, value := range result.Cookies {
+		solution.Cookies = append(solution.Cookies, &http.Cookie{Name: name, Value: value})
+	}
+	for name, value := range result.Headers {
+		solution.Headers.Set(name, value)
+	}
+	param := result.TokenParam
+	if param == "" {
+		param = kind.responseParam()
+	}
+	if result.Token != "" && param != "" {
+		solution.Params = url.Values{param: {result.Token}}
+	}
+	return solution, nil
+}
@@ -0,0 +1,162 @@
+package colly
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type Diff struct {
+	Selector string
+	Old      string
+	New      string
+	Added    []string
+	Removed  []string
+}
+
+type changedCallbackContainer struct {
+	Selector string
+	Function ChangedCallback
+}
+
+func (c *Collector) OnChanged(f ChangedCallback) {
+	c.OnChangedIn("", f)
+}
+
+func (c *Collector) OnChangedIn(selector string, f ChangedCallback) {
+	c.lock.Lock()
+	if c.changedCallbacks == nil {
+		c.changedCallbacks = make([]changedCallbackContainer, 0, 4)
+	}
+	c.changedCallbacks = append(c.changedCallbacks, changedCallbackContainer{
+		Selector: selector,
+		Function: f,
+	})
+	c.lock.Unlock()
+}
+
+func (c *Collector) rememberPrevious(request *http.Request, entry *cacheEntry) {
+	if len(c.changedCallbacks) == 0 || entry.StatusCode >= 500 {
+		return
+	}
+	if state := c.requestState(request); state != nil {
+		state.previous = entry
+	}
+}
+
+func (c *Collector) handleOnChanged(entry *cacheEntry, r *Response) {
+	old := &Response{
+		StatusCode: entry.StatusCode,
+		Body:       entry.Body,
+		Headers:    entry.Headers,
+		Ctx:        r.Ctx,
+		Request:    r.Request,
+	}
+	if err := old.decodeCharset(c.DetectCharset, r.Request.ResponseCharacterEncoding); err != nil {
+		return
+	}
+	if bytes.Equal(old.Body, r.Body) {
+		return
+	}
+	var oldDoc, newDoc *goquery.Document
+	for _, cc := range c.changedCallbacks {
+		var diff Diff
+		if cc.Selector == "" {
+			diff = lineDiff(string(old.Body), string(r.Body))
+		} else {
+			if oldDoc == nil {
+				var err error
+				if oldDoc, err = goquery.NewDocumentFromReader(bytes.NewReader(old.Body)); err != nil {
+					return
+				}
+				if newDoc, err = goquery.NewDocumentFromReader(bytes.NewReader(r.Body)); err != nil {
+					return
+				}
+			}
+			diff = fragmentDiff(oldDoc.Find(cc.Selector), newDoc.Find(cc.Selector))
+			if diff.Old == diff.New {
+				continue
+			}
+			diff.Selector = cc.Selector
+		}
+		if c.debugger != nil {
+			c.debugger.Event(createEvent("changed", r.Request.ID, c.ID, map[string]string{
+				"url":      r.Request.URL.String(),
+				"selector": cc.Selector,
+				"added":    strconv.Itoa(len(diff.Added)),
+				"removed":  strconv.Itoa(len(diff.Removed)),
+			}))
+		}
+		cc.Function(old, r, diff)
+	}
+}
+
+func fragmentDiff(old, new *goquery.Selection) Diff {
+	oldLines := fragmentLines(old)
+	newLines := fragmentLines(new)
+	diff := Diff{
+		Old: strings.Join(oldLines, "\n"),
+		New: strings.Join(newLines, "\n"),
+	}
+	diff.Added, diff.Removed = diffLines(oldLines, newLines)
+	return diff
+}
+
+func fragmentLines(s *goquery.Selection) []string {
+	lines := make([]string, 0, s.Length())
+	s.Each(func(_ int, e *goquery.Selection) {
+		lines = append(lines, strings.Join(strings.Fields(e.Text()), " "))
+	})
+	return lines
+}
+
+func lineDiff(old, new string) Diff {
+	diff := Diff{Old: old, New: new}
+	diff.Added, diff.Removed = diffLines(strings.Split(old, "\n"), strings.Split(new, "\n"))
+	return diff
+}
+
+func diffLines(old, new []string) (added, removed []string) {
+	for len(old) > 0 && len(new) > 0 && old[0] == new[0] {
+		old, new = old[1:], new[1:]
+	}
+	for len(old) > 0 && len(new) > 0 && old[len(old)-1] == new[len(new)-1] {
+		old, new = old[:len(old)-1], new[:len(new)-1]
+	}
+	if len(old)*len(new) > 4<<20 {
+		return append([]string(nil), new...), append([]string(nil), old...)
+	}
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			removed = append(removed, old[i])
+			i++
+		default:
+			added = append(added, new[j])
+			j++
+		}
+	}
+	removed = append(removed, old[i:]...)
+	added = append(added, new[j:]...)
+	return added, removed
+}
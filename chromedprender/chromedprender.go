@@ -0,0 +1,373 @@
+package chromedprender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/gocolly/colly/v2"
+)
+
+var (
+	ErrUnsupportedMethod = errors.New("Only GET requests can be rendered")
+	ErrClosed            = errors.New("Renderer is closed")
+)
+
+type Renderer struct {
+	WaitVisible string
+	WaitIdle    time.Duration
+	Timeout     time.Duration
+	browserCtx  context.Context
+	cancel      []context.CancelFunc
+	closed      bool
+	lock        *sync.RWMutex
+}
+
+func New(opts ...chromedp.ExecAllocatorOption) (*Renderer, error) {
+	if len(opts) == 0 {
+		opts = chromedp.DefaultExecAllocatorOptions[:]
+	}
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	return newRenderer(allocCtx, cancelAlloc)
+}
+
+func NewRemote(url string) (*Renderer, error) {
+	allocCtx, cancelAlloc := chromedp.NewRemoteAllocator(context.Background(), url)
+	return newRenderer(allocCtx, cancelAlloc)
+}
+
+func newRenderer(allocCtx context.Context, cancelAlloc context.CancelFunc) (*Renderer, error) {
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancelBrowser()
+		cancelAlloc()
+		return nil, err
+	}
+	return &Renderer{
+		Timeout:    30 * time.Second,
+		browserCtx: browserCtx,
+		cancel:     []context.CancelFunc{cancelBrowser, cancelAlloc},
+		lock:       &sync.RWMutex{},
+	}, nil
+}
+
+func (r *Renderer) Render(ctx context.Context, req *colly.Request) (*colly.Response, error) {
+	return r.render(ctx, req)
+}
+
+func (r *Renderer) RenderScreenshot(ctx context.Context, req *colly.Request, options *colly.ScreenshotOptions) (*colly.Response, *colly.Screenshot, error) {
+	shot := &colly.Screenshot{Format: options.Format}
+	res, err := r.render(ctx, req, screenshotAction(options, &shot.Data))
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, shot, nil
+}
+
+func (r *Renderer) Interact(ctx context.Context, req *colly.Request, steps []colly.InteractionStep) ([]*http.Cookie, error) {
+	tabCtx, cancel, err := r.tab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	actions := r.actions(ctx, req)
+	for _, step := range steps {
+		actions = append(actions, stepActions(step)...)
+	}
+	var cookies []*network.Cookie
+	actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithURLs([]string{req.URL.String()}).Do(ctx)
+		return err
+	}))
+	if err := chromedp.Run(tabCtx, actions...); err != nil {
+		return nil, err
+	}
+	result := make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		result = append(result, httpCookie(c))
+	}
+	return result, nil
+}
+
+func httpCookie(c *network.Cookie) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HTTPOnly,
+	}
+	if !c.Session && c.Expires > 0 {
+		cookie.Expires = time.Unix(int64(c.Expires), 0)
+	}
+	return cookie
+}
+
+func stepActions(step colly.InteractionStep) []chromedp.Action {
+	var actions []chromedp.Action
+	if step.WaitVisible != "" {
+		actions = append(actions, chromedp.WaitVisible(step.WaitVisible, chromedp.ByQuery))
+	}
+	if step.Click != "" {
+		selector, _ := json.Marshal(step.Click)
+		actions = append(actions, chromedp.Evaluate(fmt.Sprintf("document.querySelector(%s)?.click()", selector), nil))
+	}
+	if step.Script != "" {
+		actions = append(actions, chromedp.Evaluate(step.Script, nil))
+	}
+	if step.Wait > 0 {
+		actions = append(actions, chromedp.Sleep(step.Wait))
+	}
+	return actions
+}
+
+func (r *Renderer) render(ctx context.Context, req *colly.Request, extra ...chromedp.Action) (*colly.Response, error) {
+	if req.Method != "" && req.Method != "GET" {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMethod, req.Method)
+	}
+	tabCtx, cancel, err := r.tab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	document := &documentResponse{}
+	chromedp.ListenTarget(tabCtx, document.listen)
+
+	var html, location string
+	var cookies []*network.Cookie
+	actions := append(r.actions(ctx, req), chromedp.OuterHTML("html", &html, chromedp.ByQuery), chromedp.Location(&location))
+	actions = append(actions, extra...)
+	actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithURLs([]string{location}).Do(ctx)
+		return err
+	}))
+	err = chromedp.Run(tabCtx, actions...)
+	if err != nil {
+		return nil, err
+	}
+	headers := document.header()
+	for _, c := range cookies {
+		headers.Add("Set-Cookie", httpCookie(c).String())
+	}
+	final := req
+	if u, err := url.Parse(location); err == nil && location != "" && location != req.URL.String() {
+		copied := *req
+		copied.URL = u
+		final = &copied
+	}
+	return &colly.Response{
+		StatusCode: document.statusCode(),
+		Body:       []byte(html),
+		Ctx:        req.Ctx,
+		Request:    final,
+		Headers:    headers,
+	}, nil
+}
+
+func (r *Renderer) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	for _, cancel := range r.cancel {
+		cancel()
+	}
+	return nil
+}
+
+func (r *Renderer) tab(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.closed {
+		return nil, nil, ErrClosed
+	}
+	var opts []chromedp.ContextOption
+	proxyURL := proxyFor(ctx)
+	if proxyURL != nil {
+		server := &url.URL{Scheme: proxyURL.Scheme, Host: proxyURL.Host}
+		if server.Scheme == "socks5h" {
+			server.Scheme = "socks5"
+		}
+		opts = append(opts, chromedp.WithNewBrowserContext(func(p *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+			return p.WithProxyServer(server.String())
+		}))
+	}
+	tabCtx, cancelTab := chromedp.NewContext(r.browserCtx, opts...)
+	if proxyURL != nil && proxyURL.User != nil {
+		authenticateProxy(tabCtx, proxyURL.User)
+	}
+	cancelTimeout := func() {}
+	if r.Timeout > 0 {
+		tabCtx, cancelTimeout = context.WithTimeout(tabCtx, r.Timeout)
+	}
+	stop := context.AfterFunc(ctx, cancelTab)
+	return tabCtx, func() {
+		stop()
+		cancelTimeout()
+		cancelTab()
+	}, nil
+}
+
+func proxyFor(ctx context.Context) *url.URL {
+	proxy, _ := ctx.Value(colly.ProxyURLKey).(string)
+	if proxy == "" {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return u
+}
+
+func authenticateProxy(tabCtx context.Context, user *url.Userinfo) {
+	password, _ := user.Password()
+	chromedp.ListenTarget(tabCtx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *fetch.EventRequestPaused:
+			go func() {
+				executor := cdp.WithExecutor(tabCtx, chromedp.FromContext(tabCtx).Target)
+				fetch.ContinueRequest(e.RequestID).Do(executor)
+			}()
+		case *fetch.EventAuthRequired:
+			go func() {
+				executor := cdp.WithExecutor(tabCtx, chromedp.FromContext(tabCtx).Target)
+				response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+				if e.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+					response = &fetch.AuthChallengeResponse{
+						Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+						Username: user.Username(),
+						Password: password,
+					}
+				}
+				fetch.ContinueWithAuth(e.RequestID, response).Do(executor)
+			}()
+		}
+	})
+}
+
+func (r *Renderer) actions(ctx context.Context, req *colly.Request) []chromedp.Action {
+	var actions []chromedp.Action
+	if u := proxyFor(ctx); u != nil && u.User != nil {
+		actions = append(actions, fetch.Enable().WithHandleAuthRequests(true))
+	}
+	if cookies, _ := ctx.Value(colly.RenderCookiesKey).([]*http.Cookie); len(cookies) > 0 {
+		params := make([]*network.CookieParam, 0, len(cookies))
+		for _, c := range cookies {
+			params = append(params, &network.CookieParam{
+				Name:     c.Name,
+				Value:    c.Value,
+				URL:      req.URL.String(),
+				Secure:   c.Secure,
+				HTTPOnly: c.HttpOnly,
+			})
+		}
+		actions = append(actions, network.SetCookies(params))
+	}
+	if req.Headers != nil && len(*req.Headers) > 0 {
+		headers := network.Headers{}
+		for name := range *req.Headers {
+			headers[name] = req.Headers.Get(name)
+		}
+		actions = append(actions, network.Enable(), network.SetExtraHTTPHeaders(headers))
+	} else {
+		actions = append(actions, network.Enable())
+	}
+	actions = append(actions, chromedp.Navigate(req.URL.String()))
+	if r.WaitVisible != "" {
+		actions = append(actions, chromedp.WaitVisible(r.WaitVisible, chromedp.ByQuery))
+	} else {
+		actions = append(actions, chromedp.WaitReady("body", chromedp.ByQuery))
+	}
+	if r.WaitIdle > 0 {
+		actions = append(actions, chromedp.Sleep(r.WaitIdle))
+	}
+	return actions
+}
+
+type documentResponse struct {
+	status  int64
+	headers network.Headers
+	lock    sync.Mutex
+}
+
+func (d *documentResponse) listen(ev interface{}) {
+	e, ok := ev.(*network.EventResponseReceived)
+	if !ok || e.Type != network.ResourceTypeDocument {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.status == 0 {
+		d.status = e.Response.Status
+		d.headers = e.Response.Headers
+	}
+}
+
+func (d *documentResponse) statusCode() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.status == 0 {
+		return http.StatusOK
+	}
+	return int(d.status)
+}
+
+func (d *documentResponse) header() *http.Header {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	h := http.Header{}
+	for name, value := range d.headers {
+		if strings.EqualFold(name, "Content-Encoding") || strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+		h.Set(name, fmt.Sprint(value))
+	}
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	return &h
+}
+
+func screenshotAction(options *colly.ScreenshotOptions, buf *[]byte) chromedp.Action {
+	format := page.CaptureScreenshotFormatPng
+	if options.Format == colly.ScreenshotJPEG {
+		format = page.CaptureScreenshotFormatJpeg
+	}
+	if options.FullPage {
+		quality := 100
+		if format == page.CaptureScreenshotFormatJpeg {
+			quality = min(options.Quality, 99)
+		}
+		return chromedp.FullScreenshot(buf, quality)
+	}
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		capture := page.CaptureScreenshot().WithFormat(format)
+		if format == page.CaptureScreenshotFormatJpeg {
+			capture = capture.WithQuality(int64(options.Quality))
+		}
+		data, err := capture.Do(ctx)
+		if err != nil {
+			return err
+		}
+		*buf = data
+		return nil
+	})
+}
@@ -0,0 +1,123 @@
+package colly
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+func (c *Collector) ClusterURLs(clusterer *URLClusterer) {
+	clusterer.Init()
+	c.urlClusterer = clusterer
+}
+
+func (c *Collector) URLClusters() []URLCluster {
+	if c.urlClusterer == nil {
+		return nil
+	}
+	return c.urlClusterer.Clusters()
+}
+
+type URLClusterer struct {
+	MaxExamples      int
+	VariantThreshold int
+	patterns         map[string]*URLCluster
+	lock             *sync.Mutex
+}
+
+type URLCluster struct {
+	Template string
+	Count    int
+	Examples []string
+}
+
+func (u *URLClusterer) Init() {
+	if u.MaxExamples == 0 {
+		u.MaxExamples = 3
+	}
+	if u.VariantThreshold == 0 {
+		u.VariantThreshold = 20
+	}
+	u.patterns = make(map[string]*URLCluster)
+	u.lock = &sync.Mutex{}
+}
+
+func (u *URLClusterer) Add(parsedURL *url.URL) {
+	segments := strings.FieldsFunc(parsedURL.Path, func(c rune) bool { return c == '/' })
+	pattern := urlPattern(parsedURL, segments)
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	p, ok := u.patterns[pattern]
+	if !ok {
+		p = &URLCluster{Template: pattern}
+		u.patterns[pattern] = p
+	}
+	p.Count++
+	if len(p.Examples) < u.MaxExamples {
+		p.Examples = append(p.Examples, parsedURL.String())
+	}
+}
+
+func (u *URLClusterer) Clusters() []URLCluster {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	variants := make(map[string]int)
+	for pattern := range u.patterns {
+		for _, key := range patternGeneralizations(pattern) {
+			variants[key]++
+		}
+	}
+	merged := make(map[string]*URLCluster)
+	for pattern, p := range u.patterns {
+		template := pattern
+		for _, key := range patternGeneralizations(pattern) {
+			if variants[key] >= u.VariantThreshold {
+				template = key
+				break
+			}
+		}
+		m, ok := merged[template]
+		if !ok {
+			m = &URLCluster{Template: template}
+			merged[template] = m
+		}
+		m.Count += p.Count
+		for _, e := range p.Examples {
+			if len(m.Examples) < u.MaxExamples {
+				m.Examples = append(m.Examples, e)
+			}
+		}
+	}
+	clusters := make([]URLCluster, 0, len(merged))
+	for _, m := range merged {
+		clusters = append(clusters, *m)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Count != clusters[j].Count {
+			return clusters[i].Count > clusters[j].Count
+		}
+		return clusters[i].Template < clusters[j].Template
+	})
+	return clusters
+}
+
+func patternGeneralizations(pattern string) []string {
+	path, query, hasQuery := strings.Cut(pattern, "?")
+	segments := strings.Split(path, "/")
+	var keys []string
+	for i := 1; i < len(segments); i++ {
+		if strings.HasPrefix(segments[i], "{") {
+			continue
+		}
+		generalized := make([]string, len(segments))
+		copy(generalized, segments)
+		generalized[i] = "{*}"
+		key := strings.Join(generalized, "/")
+		if hasQuery {
+			key += "?" + query
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,360 @@
+package collytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+var ErrNoFixture = errors.New("no fixture for request")
+
+type Fixture struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+	Latency time.Duration
+	Err     error
+	Handler func(*http.Request) (*http.Response, error)
+}
+
+type Transport struct {
+	Strict   bool
+	exact    map[string]*Fixture
+	patterns []pattern
+	requests []*http.Request
+	lock     *sync.Mutex
+}
+
+type pattern struct {
+	glob    string
+	fixture *Fixture
+}
+
+func NewTransport() *Transport {
+	return &Transport{
+		exact: make(map[string]*Fixture),
+		lock:  &sync.Mutex{},
+	}
+}
+
+func (t *Transport) Install(c *colly.Collector) *Transport {
+	c.WithTransport(t)
+	return t
+}
+
+func (t *Transport) Handle(url string, f *Fixture) *Transport {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.exact[url] = f
+	return t
+}
+
+// HandleGlob serves f for every URL matching glob under path.Match rules,
+// applied to the whole URL: * does not cross a '/', and ? matches any single
+// character, including the '?' that starts a query string.
+func (t *Transport) HandleGlob(glob string, f *Fixture) *Transport {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for i, p := range t.patterns {
+		if p.glob == glob {
+			t.patterns[i].fixture = f
+			return t
+		}
+	}
+	t.patterns = append(t.patterns, pattern{glob: glob, fixture: f})
+	return t
+}
+
+func (t *Transport) HandleFunc(url string, f func(*http.Request) (*http.Response, error)) *Transport {
+	return t.Handle(url, &Fixture{Handler: f})
+}
+
+func (t *Transport) HTML(url, body string) *Transport {
+	return t.Handle(url, &Fixture{Headers: http.Header{"Content-Type": {"text/html; charset=utf-8"}}, Body: []byte(body)})
+}
+
+func (t *Transport) JSON(url string, v interface{}) *Transport {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return t.Error(url, err)
+	}
+	return t.Handle(url, &Fixture{Headers: http.Header{"Content-Type": {"application/json"}}, Body: body})
+}
+
+func (t *Transport) File(url, filename string) *Transport {
+	body, err := os.ReadFile(filename)
+	if err != nil {
+		return t.Error(url, err)
+	}
+	return t.Handle(url, &Fixture{Body: body})
+}
+
+func (t *Transport) Status(url string, status int) *Transport {
+	return t.Handle(url, &Fixture{Status: status})
+}
+
+func (t *Transport) Redirect(url, location string, status int) *Transport {
+	return t.Handle(url, &Fixture{Status: status, Headers: http.Header{"Location": {location}}})
+}
+
+func (t *Transport) Error(url string, err error) *Transport {
+	return t.Handle(url, &Fixture{Err: err})
+}
+
+func (t *Transport) Delay(url string, latency time.Duration) *Transport {
+	t.lock.Lock()
+	f, glob := t.globFixture(url)
+	if !glob {
+		f = t.lookup(url)
+	}
+	t.lock.Unlock()
+	if f == nil {
+		f = &Fixture{}
+	}
+	clone := *f
+	clone.Latency = latency
+	if glob {
+		return t.HandleGlob(url, &clone)
+	}
+	return t.Handle(url, &clone)
+}
+
+func (t *Transport) globFixture(glob string) (*Fixture, bool) {
+	if _, ok := t.exact[glob]; ok {
+		return nil, false
+	}
+	for _, p := range t.patterns {
+		if p.glob == glob {
+			return p.fixture, true
+		}
+	}
+	return nil, false
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	t.requests = append(t.requests, req)
+	f := t.lookup(req.URL.String())
+	t.lock.Unlock()
+	if f == nil {
+		if t.Strict {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoFixture, req.Method, req.URL)
+		}
+		f = &Fixture{Status: http.StatusNotFound}
+	}
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	if f.Handler != nil {
+		return f.Handler(req)
+	}
+	return f.response(req), nil
+}
+
+func (t *Transport) lookup(url string) *Fixture {
+	if f, ok := t.exact[url]; ok {
+		return f
+	}
+	for _, p := range t.patterns {
+		if ok, _ := path.Match(p.glob, url); ok {
+			return p.fixture
+		}
+	}
+	return nil
+}
+
+func (f *Fixture) response(req *http.Request) *http.Response {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	headers := f.Headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if headers.Get("Content-Type") == "" && len(f.Body) > 0 {
+		headers.Set("Content-Type", http.DetectContentType(f.Body))
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          io.NopCloser(bytes.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}
+}
+
+func (t *Transport) Requests() []*http.Request {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]*http.Request(nil), t.requests...)
+}
+
+func (t *Transport) Count(url string) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	n := 0
+	for _, req := range t.requests {
+		if req.URL.String() == url {
+			n++
+		}
+	}
+	return n
+}
+
+type Recorder struct {
+	requests  []string
+	responses []string
+	errors    []error
+	scraped   []string
+	calls     map[string]int
+	lock      *sync.Mutex
+}
+
+func Record(c *colly.Collector) *Recorder {
+	r := &Recorder{
+		calls: make(map[string]int),
+		lock:  &sync.Mutex{},
+	}
+	c.OnRequest(func(req *colly.Request) {
+		r.add(&r.requests, "request", req.URL.String())
+	})
+	c.OnResponse(func(res *colly.Response) {
+		r.add(&r.responses, "response", res.Request.URL.String())
+	})
+	c.OnError(func(res *colly.Response, err error) {
+		r.lock.Lock()
+		r.errors = append(r.errors, err)
+		r.calls["error"]++
+		r.lock.Unlock()
+	})
+	c.OnScraped(func(res *colly.Response) {
+		r.add(&r.scraped, "scraped", res.Request.URL.String())
+	})
+	return r
+}
+
+func (r *Recorder) add(list *[]string, event, url string) {
+	r.lock.Lock()
+	*list = append(*list, url)
+	r.calls[event]++
+	r.lock.Unlock()
+}
+
+func (r *Recorder) Mark(event string) {
+	r.lock.Lock()
+	r.calls[event]++
+	r.lock.Unlock()
+}
+
+func (r *Recorder) Visited() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.requests...)
+}
+
+func (r *Recorder) Responses() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.responses...)
+}
+
+func (r *Recorder) Scraped() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.scraped...)
+}
+
+func (r *Recorder) Errors() []error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]error(nil), r.errors...)
+}
+
+func (r *Recorder) Calls(event string) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.calls[event]
+}
+
+func (r *Recorder) AssertVisited(t testing.TB, urls ...string) {
+	t.Helper()
+	visited := urlSet(r.Visited())
+	for _, u := range urls {
+		if !visited[u] {
+			t.Errorf("expected %s to be visited, visited: %s", u, r.list())
+		}
+	}
+}
+
+func (r *Recorder) AssertNotVisited(t testing.TB, urls ...string) {
+	t.Helper()
+	visited := urlSet(r.Visited())
+	for _, u := range urls {
+		if visited[u] {
+			t.Errorf("expected %s not to be visited", u)
+		}
+	}
+}
+
+func (r *Recorder) AssertVisitedExactly(t testing.TB, urls ...string) {
+	t.Helper()
+	got := r.Visited()
+	sort.Strings(got)
+	want := append([]string(nil), urls...)
+	sort.Strings(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected visited %v, got %v", want, got)
+	}
+}
+
+func (r *Recorder) AssertCalls(t testing.TB, event string, n int) {
+	t.Helper()
+	if got := r.Calls(event); got != n {
+		t.Errorf("expected %d %s calls, got %d", n, event, got)
+	}
+}
+
+func (r *Recorder) AssertNoErrors(t testing.TB) {
+	t.Helper()
+	for _, err := range r.Errors() {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func urlSet(urls []string) map[string]bool {
+	m := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		m[u] = true
+	}
+	return m
+}
+
+func (r *Recorder) list() string {
+	return strings.Join(r.Visited(), ", ")
+}
@@ -0,0 +1,144 @@
+package collytest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func TestHandleMatchesQueryURLsExactly(t *testing.T) {
+	c := colly.NewCollector()
+	tr := NewTransport().Install(c)
+	tr.Strict = true
+	tr.HTML("http://example.com/search?q=go", "<p>go</p>")
+	rec := Record(c)
+
+	if err := c.Visit("http://example.com/search?q=go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Visit("http://example.com/searchXq=go"); !errors.Is(err, ErrNoFixture) {
+		t.Fatalf("expected ErrNoFixture for a URL the query fixture should not match, got %v", err)
+	}
+	rec.AssertCalls(t, "response", 1)
+}
+
+func TestHandleGlob(t *testing.T) {
+	c := colly.NewCollector()
+	tr := NewTransport().Install(c)
+	tr.HandleGlob("http://example.com/items/*", &Fixture{Body: []byte("item")})
+	tr.HandleGlob("http://example.com/items/*", &Fixture{Status: http.StatusGone})
+	rec := Record(c)
+
+	if err := c.Visit("http://example.com/items/1"); err == nil || err.Error() != http.StatusText(http.StatusGone) {
+		t.Fatalf("expected the replacement glob fixture to answer 410, got %v", err)
+	}
+	if err := c.Visit("http://example.com/other"); err == nil || err.Error() != http.StatusText(http.StatusNotFound) {
+		t.Fatalf("expected an unmatched URL to answer 404, got %v", err)
+	}
+
+	if got := len(rec.Errors()); got != 2 {
+		t.Fatalf("expected 2 errors, got %d", got)
+	}
+	if got := tr.Count("http://example.com/items/1"); got != 1 {
+		t.Fatalf("expected 1 request, got %d", got)
+	}
+	if len(tr.patterns) != 1 {
+		t.Fatalf("expected re-registering a glob to replace it, got %d patterns", len(tr.patterns))
+	}
+}
+
+func TestExactFixtureWinsOverGlob(t *testing.T) {
+	c := colly.NewCollector()
+	tr := NewTransport().Install(c)
+	tr.HandleGlob("http://example.com/*", &Fixture{Status: http.StatusNotFound})
+	tr.HTML("http://example.com/page", "<p>page</p>")
+	rec := Record(c)
+
+	if err := c.Visit("http://example.com/page"); err != nil {
+		t.Fatal(err)
+	}
+	rec.AssertNoErrors(t)
+	rec.AssertVisitedExactly(t, "http://example.com/page")
+}
+
+func TestStrictTransport(t *testing.T) {
+	c := colly.NewCollector()
+	tr := NewTransport().Install(c)
+	if err := c.Visit("http://example.com/missing"); err == nil || errors.Is(err, ErrNoFixture) {
+		t.Fatalf("expected a 404 without Strict, got %v", err)
+	}
+	tr.Strict = true
+	if err := c.Visit("http://example.com/missing2"); !errors.Is(err, ErrNoFixture) {
+		t.Fatalf("expected ErrNoFixture, got %v", err)
+	}
+}
+
+func TestDelayKeepsGlobFixture(t *testing.T) {
+	c := colly.NewCollector()
+	tr := NewTransport().Install(c)
+	tr.HandleGlob("http://example.com/slow/*", &Fixture{Body: []byte("slow")})
+	tr.Delay("http://example.com/slow/*", 20*time.Millisecond)
+
+	if _, ok := tr.exact["http://example.com/slow/*"]; ok {
+		t.Fatal("expected Delay to update the glob instead of adding an exact fixture")
+	}
+	start := time.Now()
+	var body string
+	c.OnResponse(func(r *colly.Response) {
+		body = string(r.Body)
+	})
+	if err := c.Visit("http://example.com/slow/1"); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatal("expected the delay to apply")
+	}
+	if body != "slow" {
+		t.Fatalf("expected the glob fixture body, got %q", body)
+	}
+}
+
+func TestFixtureErrorAndRedirect(t *testing.T) {
+	c := colly.NewCollector()
+	tr := NewTransport().Install(c)
+	boom := errors.New("boom")
+	tr.Error("http://example.com/fail", boom)
+	tr.Redirect("http://example.com/old", "http://example.com/new", http.StatusMovedPermanently)
+	tr.HTML("http://example.com/new", "<p>new</p>")
+	rec := Record(c)
+
+	if err := c.Visit("http://example.com/fail"); !errors.Is(err, boom) {
+		t.Fatalf("expected fixture error, got %v", err)
+	}
+	if err := c.Visit("http://example.com/old"); err != nil {
+		t.Fatal(err)
+	}
+	if got := tr.Count("http://example.com/new"); got != 1 {
+		t.Fatalf("expected redirect to be followed once, got %d", got)
+	}
+	rec.AssertVisited(t, "http://example.com/old")
+	rec.AssertNotVisited(t, "http://example.com/other")
+}
+
+func TestHandleGlobUsesPathMatch(t *testing.T) {
+	tests := []struct {
+		glob  string
+		url   string
+		match bool
+	}{
+		{"http://example.com/items/*", "http://example.com/items/1", true},
+		{"http://example.com/items/*", "http://example.com/items/1/reviews", false},
+		{"http://example.com/items/*/*", "http://example.com/items/1/reviews", true},
+		{"http://example.com/search?q=go", "http://example.com/searchXq=go", true},
+		{"http://example.com/search\\?q=go", "http://example.com/searchXq=go", false},
+	}
+	for _, tt := range tests {
+		tr := NewTransport().HandleGlob(tt.glob, &Fixture{})
+		if got := tr.lookup(tt.url) != nil; got != tt.match {
+			t.Errorf("%s against %s: expected match=%v", tt.glob, tt.url, tt.match)
+		}
+	}
+}
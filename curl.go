@@ -0,0 +1,386 @@
+package colly
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+func (r *Request) CurlCommand() string {
+	args := []string{"curl"}
+	switch r.Method {
+	case "", "GET":
+	case "HEAD":
+		args = append(args, "-I")
+	default:
+		args = append(args, "-X", r.Method)
+	}
+	args = append(args, shellQuote(r.URL.String()))
+	var headers http.Header
+	if r.Headers != nil {
+		headers = r.Headers.Clone()
+	} else {
+		headers = http.Header{}
+	}
+	if r.Host != "" && r.Host != r.URL.Host {
+		headers.Set("Host", r.Host)
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			args = append(args, "-H", shellQuote(name+": "+value))
+		}
+	}
+	if headers.Get("Cookie") == "" && r.collector != nil && r.collector.backend.Client.Jar != nil {
+		if cookies := r.collector.backend.Client.Jar.Cookies(r.URL); len(cookies) > 0 {
+			pairs := make([]string, len(cookies))
+			for i, cookie := range cookies {
+				pairs[i] = cookie.Name + "=" + cookie.Value
+			}
+			args = append(args, "-b", shellQuote(strings.Join(pairs, "; ")))
+		}
+	}
+	if ae := strings.ToLower(headers.Get("Accept-Encoding")); strings.Contains(ae, "gzip") || strings.Contains(ae, "br") || strings.Contains(ae, "zstd") {
+		args = append(args, "--compressed")
+	}
+	if body := r.peekBody(); len(body) > 0 {
+		args = append(args, "--data-binary", shellQuote(string(body)))
+	}
+	if r.ProxyURL != "" {
+		args = append(args, "--proxy", shellQuote(r.ProxyURL))
+	}
+	return strings.Join(args, " ")
+}
+
+func (r *Request) peekBody() []byte {
+	if r.Body == nil {
+		return nil
+	}
+	if s, ok := r.Body.(io.ReadSeeker); ok {
+		pos, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil
+		}
+		body, _ := io.ReadAll(s)
+		s.Seek(pos, io.SeekStart)
+		return body
+	}
+	body, _ := io.ReadAll(r.Body)
+	r.Body = bytes.NewReader(body)
+	return body
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@%+,", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+var curlArgFlags = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"--retry": true, "-w": true, "--write-out": true, "--resolve": true, "--cacert": true,
+	"-E": true, "--cert": true, "--key": true, "-x": true, "--proxy": true, "-c": true,
+	"--cookie-jar": true, "-r": true, "--range": true, "-T": true, "--upload-file": true,
+	"--limit-rate": true, "--max-redirs": true, "--interface": true, "-U": true, "--proxy-user": true,
+}
+
+func (c *Collector) ParseCurl(command string) (*Request, error) {
+	return c.parseCurl(command, false)
+}
+
+func (c *Collector) ParseCurlWithFiles(command string) (*Request, error) {
+	return c.parseCurl(command, true)
+}
+
+func (c *Collector) parseCurl(command string, readFiles bool) (*Request, error) {
+	args, err := splitShellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return nil, fmt.Errorf("%w: command must start with curl", ErrInvalidCurl)
+	}
+	headers := http.Header{}
+	method := ""
+	rawURL := ""
+	var data []string
+	form := map[string][]byte{}
+	get := false
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		flag, value, hasValue := arg, "", false
+		if strings.HasPrefix(arg, "--") {
+			if name, v, ok := strings.Cut(arg, "="); ok && curlTakesValue(name) {
+				flag, value, hasValue = name, v, true
+			}
+		} else if len(arg) > 2 && arg[0] == '-' {
+			if curlTakesValue(arg[:2]) {
+				flag, value, hasValue = arg[:2], arg[2:], true
+			} else {
+				var split []string
+				for j, ch := range arg[2:] {
+					short := "-" + string(ch)
+					if rest := arg[2+j+len(string(ch)):]; rest != "" && curlTakesValue(short) {
+						split = append(split, short+rest)
+						break
+					}
+					split = append(split, short)
+				}
+				args = append(args[:i+1], append(split, args[i+1:]...)...)
+				flag = arg[:2]
+			}
+		}
+		if !hasValue && curlTakesValue(flag) {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%w: missing value for %s", ErrInvalidCurl, flag)
+			}
+			i++
+			value = args[i]
+		}
+		switch flag {
+		case "-X", "--request":
+			method = strings.ToUpper(value)
+		case "-H", "--header":
+			name, v, ok := strings.Cut(value, ":")
+			if ok {
+				headers.Add(strings.TrimSpace(name), strings.TrimSpace(v))
+			}
+		case "-d", "--data", "--data-ascii", "--data-binary":
+			if strings.HasPrefix(value, "@") {
+				if !readFiles {
+					return nil, fmt.Errorf("%w: %s reads local file %q", ErrInvalidCurl, flag, value[1:])
+				}
+				content, err := os.ReadFile(value[1:])
+				if err != nil {
+					return nil, err
+				}
+				value = string(content)
+				if flag != "--data-binary" {
+					value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+				}
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--data-urlencode":
+			if name, content, ok := strings.Cut(value, "="); ok {
+				data = append(data, name+"="+url.QueryEscape(content))
+			} else {
+				data = append(data, url.QueryEscape(value))
+			}
+		case "-F", "--form":
+			name, content, _ := strings.Cut(value, "=")
+			if strings.HasPrefix(content, "@") {
+				filename := strings.SplitN(content[1:], ";", 2)[0]
+				if !readFiles {
+					return nil, fmt.Errorf("%w: %s reads local file %q", ErrInvalidCurl, flag, filename)
+				}
+				file, err := os.ReadFile(filename)
+				if err != nil {
+					return nil, err
+				}
+				form[name] = file
+			} else {
+				form[name] = []byte(content)
+			}
+		case "-b", "--cookie":
+			if strings.Contains(value, "=") {
+				headers.Set("Cookie", value)
+			}
+		case "-A", "--user-agent":
+			headers.Set("User-Agent", value)
+		case "-e", "--referer":
+			headers.Set("Referer", value)
+		case "-u", "--user":
+			headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+		case "-I", "--head":
+			method = "HEAD"
+		case "-G", "--get":
+			get = true
+		case "--url":
+			rawURL = value
+		default:
+			if !strings.HasPrefix(arg, "-") && rawURL == "" {
+				rawURL = arg
+			}
+		}
+	}
+	if rawURL == "" {
+		return nil, fmt.Errorf("%w: no URL", ErrInvalidCurl)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		if u, err = url.Parse("http://" + rawURL); err != nil {
+			return nil, err
+		}
+	}
+	var body []byte
+	switch {
+	case get && len(data) > 0:
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += strings.Join(data, "&")
+	case len(form) > 0:
+		boundary := randomBoundary()
+		body, _ = io.ReadAll(createMultipartReader(boundary, form))
+		headers.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+	case len(data) > 0:
+		body = []byte(strings.Join(data, "&"))
+		if headers.Get("Content-Type") == "" {
+			headers.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if method == "" {
+		method = "GET"
+		if body != nil {
+			method = "POST"
+		}
+	}
+	return &Request{
+		Method:    method,
+		URL:       u,
+		Depth:     1,
+		Body:      bytes.NewReader(body),
+		Ctx:       NewContext(),
+		ID:        atomic.AddUint32(&c.requestCount, 1),
+		Headers:   &headers,
+		collector: c,
+	}, nil
+}
+
+func curlTakesValue(flag string) bool {
+	switch flag {
+	case "-X", "--request", "-H", "--header", "-d", "--data", "--data-ascii", "--data-binary",
+		"--data-raw", "--data-urlencode", "-F", "--form", "-b", "--cookie", "-A", "--user-agent",
+		"-e", "--referer", "-u", "--user", "--url":
+		return true
+	}
+	return curlArgFlags[flag]
+}
+
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\\' && i+1 < len(s) && (s[i+1] == '\n' || s[i+1] == '\r'):
+			i++
+			if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case ch == '\\' && i+1 < len(s):
+			i++
+			word.WriteByte(s[i])
+			inWord = true
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case ch == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidCurl)
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case ch == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := readANSIQuoted(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 2
+			inWord = true
+		case ch == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidCurl)
+			}
+			inWord = true
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func readANSIQuoted(s string, word *strings.Builder) (int, error) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			return i, nil
+		case '\\':
+			if i+1 >= len(s) {
+				break
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				word.WriteByte('\n')
+			case 'r':
+				word.WriteByte('\r')
+			case 't':
+				word.WriteByte('\t')
+			case 'x':
+				if i+2 < len(s) {
+					if b, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+						word.WriteByte(byte(b))
+						i += 2
+						continue
+					}
+				}
+				word.WriteByte('x')
+			case 'u':
+				if i+4 < len(s) {
+					if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+						word.WriteRune(rune(r))
+						i += 4
+						continue
+					}
+				}
+				word.WriteByte('u')
+			default:
+				word.WriteByte(s[i])
+			}
+		default:
+			word.WriteByte(s[i])
+		}
+	}
+	return 0, fmt.Errorf("%w: unterminated quote", ErrInvalidCurl)
+}
@@ -0,0 +1,88 @@
+package colly
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const DuplicateOfCtxKey = "duplicateOf"
+
+type contentHashes struct {
+	normalize bool
+	seen      map[string]string
+	skipped   int
+	lock      *sync.Mutex
+}
+
+func (c *Collector) SetSkipDuplicateContent(normalize bool) {
+	c.contentHashes = &contentHashes{
+		normalize: normalize,
+		seen:      make(map[string]string),
+		lock:      &sync.Mutex{},
+	}
+}
+
+func (c *Collector) DuplicatesSkipped() int {
+	if c.contentHashes == nil {
+		return 0
+	}
+	c.contentHashes.lock.Lock()
+	defer c.contentHashes.lock.Unlock()
+	return c.contentHashes.skipped
+}
+
+func (c *Collector) isDuplicateContent(r *Response) bool {
+	h := c.contentHashes
+	if h == nil || len(r.Body) == 0 {
+		return false
+	}
+	body := r.Body
+	if h.normalize {
+		body = normalizeDocument(r)
+	}
+	hash := bodyFingerprint(body)
+	u := r.Request.URL.String()
+	h.lock.Lock()
+	original, ok := h.seen[hash]
+	if !ok {
+		h.seen[hash] = u
+	} else if original != u {
+		h.skipped++
+	}
+	h.lock.Unlock()
+	if !ok || original == u {
+		return false
+	}
+	r.Ctx.Put(DuplicateOfCtxKey, original)
+	if c.debugger != nil {
+		c.debugger.Event(createEvent("duplicateContent", r.Request.ID, c.ID, map[string]string{
+			"url":         u,
+			"duplicateOf": original,
+		}))
+	}
+	return true
+}
+
+func normalizeDocument(r *Response) []byte {
+	contentType := strings.ToLower(r.Headers.Get("Content-Type"))
+	if !strings.Contains(contentType, "html") && !strings.Contains(contentType, "xml") {
+		return r.Body
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body))
+	if err != nil {
+		return r.Body
+	}
+	doc.Find("script, style, noscript").Remove()
+	doc.Find("*").Contents().FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return goquery.NodeName(s) == "#comment"
+	}).Remove()
+	doc.Find("input[type=hidden]").RemoveAttr("value")
+	html, err := doc.Html()
+	if err != nil {
+		return r.Body
+	}
+	return []byte(strings.Join(strings.Fields(html), " "))
+}
@@ -0,0 +1,349 @@
+package colly
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var ssrfBlockedNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"100::/64",
+	"2001:db8::/32",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+type ssrfGuard struct {
+	allow []*net.IPNet
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func (c *Collector) BlockPrivateNetworks(allowCIDRs ...string) error {
+	guard := &ssrfGuard{}
+	for _, cidr := range allowCIDRs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		guard.allow = append(guard.allow, n)
+	}
+	c.ssrf = guard
+	c.installDialer()
+	return nil
+}
+
+func (g *ssrfGuard) blocked(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range g.allow {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	for _, n := range ssrfBlockedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	for _, embedded := range embeddedIPv4(ip) {
+		if g.blocked(embedded) {
+			return true
+		}
+	}
+	return false
+}
+
+func embeddedIPv4(ip net.IP) []net.IP {
+	if len(ip) != net.IPv6len {
+		return nil
+	}
+	switch {
+	case ip[0] == 0x20 && ip[1] == 0x02:
+		return []net.IP{net.IPv4(ip[2], ip[3], ip[4], ip[5])}
+	case ip[0] == 0x20 && ip[1] == 0x01 && ip[2] == 0 && ip[3] == 0:
+		return []net.IP{
+			net.IPv4(ip[4], ip[5], ip[6], ip[7]),
+			net.IPv4(ip[12]^0xff, ip[13]^0xff, ip[14]^0xff, ip[15]^0xff),
+		}
+	case net.IP(ip[:12]).Equal(make(net.IP, 12)):
+		return []net.IP{net.IPv4(ip[12], ip[13], ip[14], ip[15])}
+	}
+	return nil
+}
+
+func (g *ssrfGuard) check(host string, ips []net.IP) error {
+	for _, ip := range ips {
+		if !g.blocked(ip) {
+			continue
+		}
+		if net.ParseIP(host) != nil {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+		}
+		return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, host, ip)
+	}
+	return nil
+}
+
+func (c *Collector) installDialer() {
+	if t := c.httpTransport(); t != nil && c.dialTransport != t {
+		t.DialContext = c.dialContext(t.DialContext)
+		c.dialTransport = t
+	}
+	c.installTransport()
+}
+
+func (c *Collector) customDial() bool {
+	return c.ssrf != nil || c.dnsCache != nil || c.resolver != nil || c.ipFamily != IPAny || c.fallbackDelay != 0 || c.dialer != nil || len(c.unixSockets) > 0
+}
+
+func (c *Collector) guardedLookup(ctx context.Context, host string) ([]net.IP, error) {
+	ips, err := c.lookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	if c.ssrf != nil {
+		if err := c.ssrf.check(host, ips); err != nil {
+			return nil, err
+		}
+	}
+	return ips, nil
+}
+
+func (c *Collector) dialContext(forward dialContextFunc) dialContextFunc {
+	if forward == nil {
+		forward = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	base := forward
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		forward := base
+		if c.dialer != nil {
+			forward = c.dialer
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return forward(ctx, network, addr)
+		}
+		if socket := c.unixSocketFor(host); socket != "" {
+			return forward(ctx, "unix", socket)
+		}
+		target, _ := ctx.Value(ssrfTargetContextKey).(string)
+		guarded := c.ssrf != nil && strings.EqualFold(host, target)
+		custom := c.dnsCache != nil || c.resolver != nil || c.ipFamily != IPAny || c.fallbackDelay != 0
+		if !guarded && !custom {
+			return forward(ctx, network, addr)
+		}
+		ips, err := c.lookupIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if guarded {
+			if err := c.ssrf.check(host, ips); err != nil {
+				return nil, err
+			}
+		}
+		return c.dialIPs(ctx, forward, network, host, port, ips)
+	}
+}
+
+func dialSerial(ctx context.Context, forward dialContextFunc, network, port string, ips []net.IP) (net.Conn, error) {
+	var lastErr error
+	for _, ip := range ips {
+		conn, err := forward(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+type IPFamily int
+
+const (
+	IPAny IPFamily = iota
+	IPv4Only
+	IPv6Only
+	PreferIPv4
+	PreferIPv6
+)
+
+func (c *Collector) SetIPFamily(family IPFamily) {
+	c.ipFamily = family
+	if c.dnsCache != nil {
+		c.dnsCache.Flush()
+	}
+	c.installDialer()
+}
+
+func (c *Collector) SetFallbackDelay(d time.Duration) {
+	c.fallbackDelay = d
+	c.installDialer()
+}
+
+func partitionIPs(ips []net.IP, family IPFamily) ([]net.IP, []net.IP) {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	switch family {
+	case IPv4Only:
+		return v4, nil
+	case IPv6Only:
+		return v6, nil
+	case PreferIPv4:
+		return v4, v6
+	case PreferIPv6:
+		return v6, v4
+	}
+	if len(ips) > 0 && ips[0].To4() == nil {
+		return v6, v4
+	}
+	return v4, v6
+}
+
+func (c *Collector) dialIPs(ctx context.Context, forward dialContextFunc, network, host, port string, ips []net.IP) (net.Conn, error) {
+	primary, fallback := partitionIPs(ips, c.ipFamily)
+	if len(primary) == 0 {
+		primary, fallback = fallback, nil
+	}
+	if len(primary) == 0 {
+		return nil, &net.AddrError{Err: "no suitable address found", Addr: host}
+	}
+	if len(fallback) == 0 || c.fallbackDelay < 0 {
+		return dialSerial(ctx, forward, network, port, append(primary, fallback...))
+	}
+	delay := c.fallbackDelay
+	if delay == 0 {
+		delay = 300 * time.Millisecond
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan dialResult, 2)
+	start := func(ips []net.IP) {
+		go func() {
+			conn, err := dialSerial(ctx, forward, network, port, ips)
+			results <- dialResult{conn, err}
+		}()
+	}
+	start(primary)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending, started := 1, false
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !started {
+				start(fallback)
+				pending, started = pending+1, true
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				if pending > 0 {
+					go func() {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}()
+				}
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if !started {
+				start(fallback)
+				pending, started = pending+1, true
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+type unixSocketRoute struct {
+	glob string
+	path string
+}
+
+func (c *Collector) SetDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+	c.dialer = dial
+	c.installDialer()
+}
+
+func (c *Collector) SetUnixSocket(glob, socketPath string) {
+	glob = strings.ToLower(glob)
+	c.lock.Lock()
+	routes := make([]unixSocketRoute, 0, len(c.unixSockets)+1)
+	for _, r := range c.unixSockets {
+		if r.glob != glob {
+			routes = append(routes, r)
+		}
+	}
+	if socketPath != "" {
+		routes = append(routes, unixSocketRoute{glob: glob, path: socketPath})
+	}
+	c.unixSockets = routes
+	c.lock.Unlock()
+	c.installDialer()
+}
+
+func (c *Collector) unixSocketFor(host string) string {
+	c.lock.RLock()
+	routes := c.unixSockets
+	c.lock.RUnlock()
+	host = strings.ToLower(host)
+	for _, r := range routes {
+		if matchHostGlob(r.glob, host) {
+			return r.path
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package colly
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestSSRFGuardBlocksPrivateAddresses(t *testing.T) {
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"8.8.8.8", false},
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"169.254.169.254", true},
+		{"192.168.1.1", true},
+		{"100.64.0.1", true},
+		{"2606:4700:4700::1111", false},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:8.8.8.8", false},
+		{"::7f00:1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"2002:7f00:0001::", true},
+		{"2002:a9fe:a9fe::1", true},
+		{"2002:c0a8:0101:1::1", true},
+		{"2002:0808:0808::1", false},
+		{"2001:0:4136:e378:8000:63bf:80ff:fffe", true},
+		{"2001:0:0a00:0001::f7f7:f7f7", true},
+		{"2001:0:4136:e378:8000:63bf:f7f7:f7f7", false},
+	}
+	g := &ssrfGuard{}
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if ip == nil {
+			t.Fatalf("invalid test address %s", tt.ip)
+		}
+		if got := g.blocked(ip); got != tt.blocked {
+			t.Errorf("blocked(%s) = %v, want %v", tt.ip, got, tt.blocked)
+		}
+	}
+}
+
+func TestSSRFGuardAllowList(t *testing.T) {
+	c := NewCollector()
+	if err := c.BlockPrivateNetworks("10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"10.1.2.3", false},
+		{"2002:0a01:0203::1", false},
+		{"192.168.1.1", true},
+	}
+	for _, tt := range tests {
+		err := c.ssrf.check("internal.example", []net.IP{net.ParseIP(tt.ip)})
+		if blocked := errors.Is(err, ErrForbiddenAddress); blocked != tt.blocked {
+			t.Errorf("check(%s) = %v, want blocked %v", tt.ip, err, tt.blocked)
+		}
+	}
+	if err := c.BlockPrivateNetworks("not a cidr"); err == nil {
+		t.Fatal("expected an invalid CIDR to be rejected")
+	}
+}
+
+func TestSSRFGuardRejectsVisits(t *testing.T) {
+	c := NewCollector()
+	if err := c.BlockPrivateNetworks(); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []string{"http://127.0.0.1:1/", "http://[2002:7f00:1::]:1/", "http://localhost:1/"} {
+		if err := c.Visit(u); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("Visit(%s) = %v, want ErrForbiddenAddress", u, err)
+		}
+	}
+}
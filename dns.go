@@ -0,0 +1,427 @@
+package colly
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func (c *Collector) lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if c.dnsCache != nil {
+		return c.dnsCache.lookup(ctx, host, c.resolveIP)
+	}
+	ips, _, err := c.resolveIP(ctx, host)
+	return ips, err
+}
+
+func (c *Collector) resolveIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	if c.resolver != nil {
+		return c.resolver.LookupIP(ctx, host)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips, 0, nil
+}
+
+type DNSCache struct {
+	TTL         time.Duration
+	MinTTL      time.Duration
+	MaxTTL      time.Duration
+	NegativeTTL time.Duration
+	MaxEntries  int
+	entries     map[string]*list.Element
+	order       *list.List
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	lock        *sync.Mutex
+}
+
+type DNSCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+type dnsEntry struct {
+	host    string
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+func (d *DNSCache) Init() {
+	if d.TTL == 0 {
+		d.TTL = time.Minute
+	}
+	if d.MaxTTL == 0 {
+		d.MaxTTL = time.Hour
+	}
+	if d.NegativeTTL == 0 {
+		d.NegativeTTL = 5 * time.Second
+	}
+	if d.MaxEntries == 0 {
+		d.MaxEntries = 10000
+	}
+	d.entries = make(map[string]*list.Element)
+	d.order = list.New()
+	d.lock = &sync.Mutex{}
+}
+
+func (c *Collector) SetDNSCache(cache *DNSCache) {
+	if cache == nil {
+		cache = &DNSCache{}
+	}
+	cache.Init()
+	c.dnsCache = cache
+	c.installDialer()
+}
+
+func (c *Collector) DNSCacheStats() DNSCacheStats {
+	if c.dnsCache == nil {
+		return DNSCacheStats{}
+	}
+	return c.dnsCache.Stats()
+}
+
+func (d *DNSCache) Stats() DNSCacheStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return DNSCacheStats{
+		Hits:      d.hits,
+		Misses:    d.misses,
+		Evictions: d.evictions,
+		Entries:   d.order.Len(),
+	}
+}
+
+func (d *DNSCache) Flush() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.entries = make(map[string]*list.Element)
+	d.order.Init()
+}
+
+func (d *DNSCache) lookup(ctx context.Context, host string, resolve func(context.Context, string) ([]net.IP, time.Duration, error)) ([]net.IP, error) {
+	host = strings.ToLower(host)
+	now := time.Now()
+	d.lock.Lock()
+	if el, ok := d.entries[host]; ok {
+		entry := el.Value.(*dnsEntry)
+		if now.Before(entry.expires) {
+			d.hits++
+			d.order.MoveToFront(el)
+			d.lock.Unlock()
+			return entry.ips, entry.err
+		}
+		d.order.Remove(el)
+		delete(d.entries, host)
+	}
+	d.misses++
+	d.lock.Unlock()
+
+	ips, ttl, err := resolve(ctx, host)
+	if err != nil && ctx.Err() != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		ttl = d.TTL
+	}
+	if err != nil {
+		ttl = d.NegativeTTL
+	} else {
+		ttl = min(max(ttl, d.MinTTL), d.MaxTTL)
+	}
+	if ttl < 0 {
+		return ips, err
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if el, ok := d.entries[host]; ok {
+		d.order.Remove(el)
+	}
+	d.entries[host] = d.order.PushFront(&dnsEntry{host: host, ips: ips, err: err, expires: now.Add(ttl)})
+	for d.order.Len() > d.MaxEntries {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*dnsEntry).host)
+		d.evictions++
+	}
+	return ips, err
+}
+
+type Resolver interface {
+	LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+}
+
+type ResolverFunc func(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+
+func (f ResolverFunc) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	return f(ctx, host)
+}
+
+func (c *Collector) SetResolver(resolver Resolver) {
+	c.resolver = resolver
+	if c.dnsCache != nil {
+		c.dnsCache.Flush()
+	}
+	c.installDialer()
+}
+
+func NetResolver(r *net.Resolver) Resolver {
+	return ResolverFunc(func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		addrs, err := r.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, 0, err
+		}
+		ips := make([]net.IP, 0, len(addrs))
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+		return ips, 0, nil
+	})
+}
+
+func RotatingResolver(resolvers ...Resolver) Resolver {
+	var next uint32
+	return ResolverFunc(func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		if len(resolvers) == 0 {
+			return nil, 0, &net.DNSError{Err: "no resolvers configured", Name: host}
+		}
+		start := int(atomic.AddUint32(&next, 1) - 1)
+		var lastErr error
+		for i := range resolvers {
+			ips, ttl, err := resolvers[(start+i)%len(resolvers)].LookupIP(ctx, host)
+			if err == nil {
+				return ips, ttl, nil
+			}
+			if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+				return nil, 0, err
+			}
+			lastErr = err
+		}
+		return nil, 0, lastErr
+	})
+}
+
+type DoHResolver struct {
+	URL    string
+	Client *http.Client
+}
+
+func (r *DoHResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return dnsLookup(host, 0, func(query []byte) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", r.URL, bytes.NewReader(query))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/dns-message")
+		req.Header.Set("Accept", "application/dns-message")
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("DNS-over-HTTPS query failed: %s", res.Status)
+		}
+		return io.ReadAll(io.LimitReader(res.Body, 65535))
+	})
+}
+
+type DoTResolver struct {
+	Addr       string
+	ServerName string
+	TLSConfig  *tls.Config
+}
+
+func (r *DoTResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	addr := r.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "853")
+	}
+	config := &tls.Config{}
+	if r.TLSConfig != nil {
+		config = r.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = r.ServerName
+	}
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	var id [2]byte
+	rand.Read(id[:])
+	return dnsLookup(host, binary.BigEndian.Uint16(id[:]), func(query []byte) ([]byte, error) {
+		conn, err := (&tls.Dialer{Config: config}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		} else {
+			conn.SetDeadline(time.Now().Add(10 * time.Second))
+		}
+		return dnsStreamExchange(conn, query)
+	})
+}
+
+func dnsStreamExchange(conn net.Conn, query []byte) ([]byte, error) {
+	msg := make([]byte, 2, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, msg[:2]); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(msg[:2]))
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+type UDPResolver struct {
+	Addr    string
+	Timeout time.Duration
+}
+
+func (r *UDPResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	addr := r.Addr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	var id [2]byte
+	rand.Read(id[:])
+	return dnsLookup(host, binary.BigEndian.Uint16(id[:]), func(query []byte) ([]byte, error) {
+		answer, err := r.exchange(ctx, "udp", addr, timeout, query)
+		if err != nil || len(answer) < 3 || answer[2]&0x02 == 0 {
+			return answer, err
+		}
+		return r.exchange(ctx, "tcp", addr, timeout, query)
+	})
+}
+
+func (r *UDPResolver) exchange(ctx context.Context, network, addr string, timeout time.Duration, query []byte) ([]byte, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if network == "tcp" {
+		return dnsStreamExchange(conn, query)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, 65535)
+	n, err := conn.Read(answer)
+	if err != nil {
+		return nil, err
+	}
+	return answer[:n], nil
+}
+
+func dnsLookup(host string, id uint16, exchange func(query []byte) ([]byte, error)) ([]net.IP, time.Duration, error) {
+	fqdn := host
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host}
+	}
+	var ips []net.IP
+	var ttl time.Duration
+	var lastErr error
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		query, err := (&dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+			Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+		}).Pack()
+		if err != nil {
+			return nil, 0, err
+		}
+		answer, err := exchange(query)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(answer); err != nil {
+			lastErr = err
+			continue
+		}
+		if msg.ID != id {
+			lastErr = &net.DNSError{Err: "mismatched DNS response id", Name: host}
+			continue
+		}
+		if msg.RCode == dnsmessage.RCodeNameError {
+			return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		if msg.RCode != dnsmessage.RCodeSuccess {
+			lastErr = &net.DNSError{Err: "server misbehaving: " + msg.RCode.String(), Name: host, IsTemporary: true}
+			continue
+		}
+		for _, rr := range msg.Answers {
+			var ip net.IP
+			switch body := rr.Body.(type) {
+			case *dnsmessage.AResource:
+				ip = net.IP(body.A[:])
+			case *dnsmessage.AAAAResource:
+				ip = net.IP(body.AAAA[:])
+			default:
+				continue
+			}
+			ips = append(ips, ip)
+			if recordTTL := time.Duration(rr.Header.TTL) * time.Second; ttl == 0 || recordTTL < ttl {
+				ttl = recordTTL
+			}
+		}
+	}
+	if len(ips) == 0 {
+		if lastErr != nil {
+			return nil, 0, lastErr
+		}
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, ttl, nil
+}
@@ -0,0 +1,161 @@
+package colly
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestDNSCacheUsesSystemResolver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	c := NewCollector(CacheDNS(nil))
+	if err := c.Visit("http://localhost:" + port + "/"); err != nil {
+		t.Fatalf("expected localhost to resolve with the DNS cache enabled: %v", err)
+	}
+	ips, err := c.lookupIP(context.Background(), "localhost")
+	if err != nil || len(ips) == 0 || !ips[0].IsLoopback() {
+		t.Fatalf("expected a loopback address, got %v, %v", ips, err)
+	}
+	if stats := c.DNSCacheStats(); stats.Misses != 1 || stats.Hits != 1 {
+		t.Fatalf("expected the lookup to hit the cache, got %+v", stats)
+	}
+}
+
+func TestDNSCacheTTL(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	tests := []struct {
+		name    string
+		cache   DNSCache
+		ttl     time.Duration
+		err     error
+		advance time.Duration
+		calls   int32
+	}{
+		{"fixed TTL when the resolver reports none", DNSCache{TTL: time.Minute}, 0, nil, 30 * time.Second, 1},
+		{"fixed TTL expires", DNSCache{TTL: time.Minute}, 0, nil, 2 * time.Minute, 2},
+		{"record TTL clamped to MaxTTL", DNSCache{MaxTTL: time.Minute}, time.Hour, nil, 2 * time.Minute, 2},
+		{"record TTL raised to MinTTL", DNSCache{MinTTL: time.Hour}, time.Second, nil, time.Minute, 1},
+		{"negative answers cached", DNSCache{NegativeTTL: time.Minute}, 0, errLookup, 30 * time.Second, 1},
+		{"negative answers expire", DNSCache{NegativeTTL: time.Minute}, 0, errLookup, 2 * time.Minute, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			resolve := func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+				atomic.AddInt32(&calls, 1)
+				if tt.err != nil {
+					return nil, 0, tt.err
+				}
+				return []net.IP{net.IPv4(192, 0, 2, 1)}, tt.ttl, nil
+			}
+			cache := tt.cache
+			cache.Init()
+			if _, err := cache.lookup(context.Background(), "example.com", resolve); err != tt.err {
+				t.Fatalf("unexpected error %v", err)
+			}
+			for _, el := range cache.entries {
+				el.Value.(*dnsEntry).expires = el.Value.(*dnsEntry).expires.Add(-tt.advance)
+			}
+			cache.lookup(context.Background(), "EXAMPLE.com", resolve)
+			if calls != tt.calls {
+				t.Fatalf("expected %d resolver calls, got %d", tt.calls, calls)
+			}
+		})
+	}
+}
+
+func TestDNSCacheEvictsOldestEntries(t *testing.T) {
+	cache := DNSCache{MaxEntries: 2}
+	cache.Init()
+	resolve := func(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+		return []net.IP{net.IPv4(192, 0, 2, 1)}, 0, nil
+	}
+	for _, host := range []string{"a.example", "b.example", "a.example", "c.example"} {
+		cache.lookup(context.Background(), host, resolve)
+	}
+	if _, ok := cache.entries["b.example"]; ok {
+		t.Fatal("expected the least recently used host to be evicted")
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Evictions != 1 || stats.Hits != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestUDPResolverFallsBackToTCPWhenTruncated(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		t.Skip("cannot bind TCP on the UDP port:", err)
+	}
+	defer tcp.Close()
+
+	answer := func(query []byte, truncated bool) []byte {
+		var msg dnsmessage.Message
+		if err := msg.Unpack(query); err != nil {
+			return nil
+		}
+		msg.Header.Response = true
+		msg.Header.Truncated = truncated
+		if !truncated && msg.Questions[0].Type == dnsmessage.TypeA {
+			msg.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: msg.Questions[0].Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 30},
+				Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 7}},
+			}}
+		}
+		out, _ := msg.Pack()
+		return out
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(answer(buf[:n], true), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				var size [2]byte
+				if _, err := conn.Read(size[:]); err != nil {
+					return
+				}
+				query := make([]byte, int(size[0])<<8|int(size[1]))
+				if _, err := conn.Read(query); err != nil {
+					return
+				}
+				out := answer(query, false)
+				conn.Write(append([]byte{byte(len(out) >> 8), byte(len(out))}, out...))
+			}(conn)
+		}
+	}()
+
+	ips, ttl, err := (&UDPResolver{Addr: udp.LocalAddr().String(), Timeout: 2 * time.Second}).LookupIP(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.IPv4(192, 0, 2, 7)) || ttl != 30*time.Second {
+		t.Fatalf("expected the TCP answer, got %v %v", ips, ttl)
+	}
+}
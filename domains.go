@@ -0,0 +1,180 @@
+package colly
+
+import (
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+func (c *Collector) AddHostAliases(canonical string, aliases ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.HostAliases == nil {
+		c.HostAliases = make(map[string]string)
+	}
+	canonical = strings.ToLower(canonical)
+	for _, alias := range aliases {
+		c.HostAliases[strings.ToLower(alias)] = canonical
+	}
+}
+
+func (c *Collector) foldHost(host string) string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.HostAliases == nil && !c.FoldWWW {
+		return host
+	}
+	host = strings.ToLower(host)
+	if canonical, ok := c.HostAliases[host]; ok {
+		host = canonical
+	}
+	if c.FoldWWW {
+		host = strings.TrimPrefix(host, "www.")
+	}
+	return host
+}
+
+func (c *Collector) foldURL(u *url.URL) *url.URL {
+	host := c.foldHost(u.Hostname())
+	if host == u.Hostname() {
+		return u
+	}
+	folded := *u
+	folded.Host = host
+	if port := u.Port(); port != "" {
+		folded.Host += ":" + port
+	}
+	return &folded
+}
+
+func (c *Collector) hostAliasCount() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return len(c.HostAliases)
+}
+
+func (c *Collector) foldURLString(u string) string {
+	c.lock.RLock()
+	folds := c.HostAliases != nil || c.FoldWWW
+	c.lock.RUnlock()
+	if !folds {
+		return u
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	return c.foldURL(parsed).String()
+}
+
+func hostMatches(domains []string, host string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if d == host || strings.HasSuffix(host, "."+d) || matchHostGlob(d, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHostGlob reports whether host matches pattern. A pattern of "*"
+// matches every host and "*.example.com" matches any subdomain of
+// example.com at any depth. Otherwise the pattern is compared label by
+// label with path.Match, so "*" and "?" never cross a dot and the pattern
+// must have as many labels as the host. Patterns are expected in lower case.
+func matchHostGlob(pattern, host string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok && !strings.ContainsAny(suffix, "*?[") {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	labels := strings.Split(host, ".")
+	globs := strings.Split(pattern, ".")
+	if len(labels) != len(globs) {
+		return false
+	}
+	for i, g := range globs {
+		if matched, _ := path.Match(g, labels[i]); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+type domainMatcher struct {
+	source   []string
+	foldWWW  bool
+	aliases  int
+	hosts    map[string]bool
+	suffixes map[string]bool
+	globs    []string
+}
+
+func (c *Collector) compileDomains(domains []string) *domainMatcher {
+	m := &domainMatcher{
+		source:   slices.Clone(domains),
+		foldWWW:  c.FoldWWW,
+		aliases:  c.hostAliasCount(),
+		hosts:    make(map[string]bool, len(domains)),
+		suffixes: make(map[string]bool),
+	}
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if suffix, ok := strings.CutPrefix(d, "*."); ok && !strings.ContainsAny(suffix, "*?[") {
+			m.suffixes[suffix] = true
+			continue
+		}
+		if strings.ContainsAny(d, "*?[") {
+			m.globs = append(m.globs, d)
+			continue
+		}
+		m.hosts[c.foldHost(d)] = true
+	}
+	return m
+}
+
+func (c *Collector) domainMatcher(cache *atomic.Value, domains []string) *domainMatcher {
+	if m, ok := cache.Load().(*domainMatcher); ok && m != nil && m.compiledFor(c, domains) {
+		return m
+	}
+	m := c.compileDomains(domains)
+	cache.Store(m)
+	return m
+}
+
+func (m *domainMatcher) compiledFor(c *Collector, domains []string) bool {
+	if len(m.source) != len(domains) || m.foldWWW != c.FoldWWW || m.aliases != c.hostAliasCount() {
+		return false
+	}
+	return slices.Equal(m.source, domains)
+}
+
+func (m *domainMatcher) match(domain string) bool {
+	if m.hosts[domain] {
+		return true
+	}
+	if len(m.suffixes) > 0 {
+		for i := strings.IndexByte(domain, '.'); i >= 0; {
+			if m.suffixes[domain[i+1:]] {
+				return true
+			}
+			next := strings.IndexByte(domain[i+1:], '.')
+			if next < 0 {
+				break
+			}
+			i += next + 1
+		}
+	}
+	for _, g := range m.globs {
+		if matchHostGlob(g, domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package colly
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestMatchHostGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"*", "example.com", true},
+		{"example.com", "example.com", true},
+		{"example.com", "www.example.com", false},
+		{"*.example.com", "www.example.com", true},
+		{"*.example.com", "a.b.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"*.example.com", "badexample.com", false},
+		{"api.*.com", "api.example.com", true},
+		{"api.*.com", "api.a.example.com", false},
+		{"*example.com", "www.example.com", false},
+		{"img?.example.com", "img1.example.com", true},
+		{"img?.example.com", "img12.example.com", false},
+		{"cdn[0-9].example.com", "cdn3.example.com", true},
+		{"cdn[0-9].example.com", "cdnx.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchHostGlob(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHostGlob(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHostRulesShareGlobSemantics(t *testing.T) {
+	c := NewCollector()
+	c.AllowedDomains = []string{"api.*.com"}
+	c.SetDomainMaxDepth("api.*.com", 3)
+	routed, _ := url.Parse("http://127.0.0.1:3128")
+	c.SetDomainProxyFunc("api.*.com", func(*http.Request) (*url.URL, error) {
+		return routed, nil
+	})
+	for _, tt := range []struct {
+		host string
+		want bool
+	}{
+		{"api.example.com", true},
+		{"api.a.example.com", false},
+	} {
+		if got := c.isDomainAllowed(tt.host); got != tt.want {
+			t.Errorf("isDomainAllowed(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+		if got := c.maxDepthFor(tt.host) == 3; got != tt.want {
+			t.Errorf("maxDepthFor(%q) matched = %v, want %v", tt.host, got, tt.want)
+		}
+		req, _ := http.NewRequest("GET", "http://"+tt.host+"/", nil)
+		if proxy, _ := c.routeProxy(req); (proxy == routed) != tt.want {
+			t.Errorf("routeProxy(%q) = %v, want routed %v", tt.host, proxy, tt.want)
+		}
+	}
+}
+
+func TestDomainMatcherRecompilesOnChange(t *testing.T) {
+	c := NewCollector()
+	c.AllowedDomains = []string{"a.example", "b.example"}
+	if !c.isDomainAllowed("b.example") {
+		t.Fatal("expected b.example to be allowed")
+	}
+	c.AllowedDomains[1] = "c.example"
+	if c.isDomainAllowed("b.example") || !c.isDomainAllowed("c.example") {
+		t.Fatal("expected an in-place edit of AllowedDomains to take effect")
+	}
+	c.AllowedDomains = append([]string(nil), "a.example", "c.example")
+	if !c.isDomainAllowed("c.example") {
+		t.Fatal("expected an equal copy of AllowedDomains to keep matching")
+	}
+}
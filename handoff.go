@@ -0,0 +1,134 @@
+package colly
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+func (c *Collector) HandoffTo(target *Collector, buffer, workers int) *Handoff {
+	return NewHandoff(c, target, buffer, workers)
+}
+
+type HandoffItem struct {
+	URL     string
+	Method  string
+	Ctx     *Context
+	Headers http.Header
+	Body    []byte
+	Depth   int
+}
+
+type Handoff struct {
+	source  *Collector
+	target  *Collector
+	items   chan *HandoffItem
+	workers *sync.WaitGroup
+	lock    *sync.RWMutex
+	closed  bool
+	emitted uint32
+	skipped uint32
+}
+
+func NewHandoff(source, target *Collector, buffer, workers int) *Handoff {
+	if workers < 1 {
+		workers = 1
+	}
+	h := &Handoff{
+		source:  source,
+		target:  target,
+		items:   make(chan *HandoffItem, buffer),
+		workers: &sync.WaitGroup{},
+		lock:    &sync.RWMutex{},
+	}
+	for i := 0; i < workers; i++ {
+		h.workers.Add(1)
+		go h.work()
+	}
+	return h
+}
+
+func (h *Handoff) work() {
+	defer h.workers.Done()
+	for item := range h.items {
+		var body io.Reader
+		if item.Body != nil {
+			body = bytes.NewReader(item.Body)
+		}
+		depth := item.Depth
+		if depth < 1 {
+			depth = 1
+		}
+		h.target.scrapeWith(item.URL, item.Method, depth, body, item.Ctx, item.Headers, true, false, CacheDefault)
+	}
+}
+
+func (h *Handoff) Emit(item *HandoffItem) error {
+	if item.URL == "" {
+		return ErrMissingURL
+	}
+	if item.Method == "" {
+		item.Method = http.MethodGet
+	}
+	if item.Method == http.MethodGet && !h.target.AllowURLRevisit {
+		if visited, err := h.target.HasVisited(item.URL); err == nil && visited {
+			atomic.AddUint32(&h.skipped, 1)
+			return nil
+		}
+	}
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if h.closed {
+		return ErrHandoffClosed
+	}
+	h.items <- item
+	atomic.AddUint32(&h.emitted, 1)
+	return nil
+}
+
+func (h *Handoff) EmitURL(URL string, ctx *Context) error {
+	return h.Emit(&HandoffItem{URL: URL, Ctx: ctx})
+}
+
+func (h *Handoff) EmitRequest(r *Request) error {
+	item := &HandoffItem{
+		URL:    r.URL.String(),
+		Method: r.Method,
+		Ctx:    r.Ctx,
+		Depth:  r.Depth,
+	}
+	if r.Headers != nil {
+		item.Headers = r.Headers.Clone()
+	}
+	return h.Emit(item)
+}
+
+func (h *Handoff) Pending() int {
+	return len(h.items)
+}
+
+func (h *Handoff) Emitted() uint32 {
+	return atomic.LoadUint32(&h.emitted)
+}
+
+func (h *Handoff) Skipped() uint32 {
+	return atomic.LoadUint32(&h.skipped)
+}
+
+func (h *Handoff) Close() {
+	h.lock.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.items)
+	}
+	h.lock.Unlock()
+}
+
+func (h *Handoff) Wait() {
+	h.source.Wait()
+	h.Close()
+	h.workers.Wait()
+	h.target.Wait()
+}
@@ -0,0 +1,422 @@
+package colly
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kennygrant/sanitize"
+)
+
+type HARRecorder struct {
+	Path               string
+	PerPage            bool
+	OmitBodies         bool
+	IncludeCredentials bool
+	MaxEntries         int
+	pages              []*harPage
+	entries            []*harEntry
+	dropped            int
+	lock               *sync.Mutex
+}
+
+const defaultHARMaxEntries = 10000
+
+var harCredentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+type harLog struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Pages   []*harPage  `json:"pages"`
+	Entries []*harEntry `json:"entries"`
+	Comment string      `json:"comment,omitempty"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harPage struct {
+	StartedDateTime time.Time      `json:"startedDateTime"`
+	ID              string         `json:"id"`
+	Title           string         `json:"title"`
+	PageTimings     harPageTimings `json:"pageTimings"`
+	started         time.Time
+}
+
+type harPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+type harEntry struct {
+	PageRef         string      `json:"pageref,omitempty"`
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+func NewHARRecorder(path string, perPage bool) *HARRecorder {
+	return &HARRecorder{Path: path, PerPage: perPage, lock: &sync.Mutex{}}
+}
+
+func (c *Collector) SetHARRecorder(recorder *HARRecorder) {
+	if recorder != nil && recorder.lock == nil {
+		recorder.lock = &sync.Mutex{}
+	}
+	c.har = recorder
+}
+
+func harPageID(r *Request) string {
+	return "page_" + strconv.FormatUint(uint64(r.ID), 10)
+}
+
+func (h *HARRecorder) startPage(r *Request) {
+	now := time.Now()
+	h.lock.Lock()
+	h.pages = append(h.pages, &harPage{
+		StartedDateTime: now,
+		ID:              harPageID(r),
+		Title:           r.URL.String(),
+		PageTimings:     harPageTimings{OnContentLoad: -1, OnLoad: -1},
+		started:         now,
+	})
+	h.lock.Unlock()
+}
+
+func (h *HARRecorder) finishPage(r *Request) {
+	id := harPageID(r)
+	h.lock.Lock()
+	var page *harPage
+	for _, p := range h.pages {
+		if p.ID == id {
+			page = p
+		}
+	}
+	if page == nil {
+		h.lock.Unlock()
+		return
+	}
+	page.PageTimings.OnLoad = harMillis(time.Since(page.started))
+	if !h.PerPage {
+		h.lock.Unlock()
+		return
+	}
+	var entries, rest []*harEntry
+	for _, e := range h.entries {
+		if e.PageRef == id {
+			entries = append(entries, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	h.entries = rest
+	pages := h.pages[:0]
+	for _, p := range h.pages {
+		if p != page {
+			pages = append(pages, p)
+		}
+	}
+	h.pages = pages
+	h.lock.Unlock()
+	name := sanitize.BaseName(r.URL.Host + r.URL.Path)
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	filename := filepath.Join(h.Path, fmt.Sprintf("%d-%s.har", r.ID, name))
+	if data, err := marshalHAR([]*harPage{page}, entries, 0); err == nil && os.MkdirAll(h.Path, 0750) == nil {
+		writeHAR(filename, data)
+	}
+}
+
+func (h *HARRecorder) add(r *Request, req *http.Request, response *Response, err error, started time.Time, state *requestState) {
+	end := time.Now()
+	state.lock.Lock()
+	redirects := append([]RedirectHop(nil), state.redirects...)
+	state.lock.Unlock()
+	hops := state.traceHops()
+	if len(hops) > len(redirects)+1 {
+		hops = hops[len(hops)-len(redirects)-1:]
+	}
+	hopTrace := func(i int) *HTTPTrace {
+		if i < len(hops) {
+			return hops[i].Trace
+		}
+		return nil
+	}
+	var entries []*harEntry
+	for i, hop := range redirects {
+		e := &harEntry{
+			PageRef:         harPageID(r),
+			StartedDateTime: started,
+			Request:         harRequestFor(req.Method, hop.URL, hop.proto, req.Header, nil, !h.IncludeCredentials),
+			Response: harResponse{
+				Status:      hop.StatusCode,
+				StatusText:  http.StatusText(hop.StatusCode),
+				HTTPVersion: hop.proto,
+				Cookies:     []harNameValue{},
+				Headers:     harHeaders(hop.Headers, !h.IncludeCredentials),
+				RedirectURL: hop.Headers.Get("Location"),
+				HeadersSize: -1,
+			},
+		}
+		e.setTimings(hopTrace(i), started, time.Time{})
+		entries = append(entries, e)
+	}
+	var body []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = io.ReadAll(rc)
+			rc.Close()
+		}
+	}
+	proto := ""
+	if response != nil {
+		proto = response.proto
+	}
+	e := &harEntry{
+		PageRef:  harPageID(r),
+		Request:  harRequestFor(req.Method, r.URL, proto, req.Header, body, !h.IncludeCredentials),
+		Response: harResponse{HTTPVersion: proto, Cookies: []harNameValue{}, Headers: []harNameValue{}, HeadersSize: -1, BodySize: -1},
+	}
+	e.setTimings(hopTrace(len(redirects)), started, end)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if response != nil {
+		e.Response.Status = response.StatusCode
+		e.Response.StatusText = http.StatusText(response.StatusCode)
+		if response.Headers != nil {
+			e.Response.Headers = harHeaders(*response.Headers, !h.IncludeCredentials)
+			e.Response.Content.MimeType = response.Headers.Get("Content-Type")
+		}
+		e.Response.BodySize = len(response.Body)
+		e.Response.Content.Size = len(response.Body)
+		if !h.OmitBodies {
+			if utf8.Valid(response.Body) {
+				e.Response.Content.Text = string(response.Body)
+			} else {
+				e.Response.Content.Text = base64.StdEncoding.EncodeToString(response.Body)
+				e.Response.Content.Encoding = "base64"
+			}
+		}
+	}
+	entries = append(entries, e)
+	h.lock.Lock()
+	h.entries = append(h.entries, entries...)
+	if !h.PerPage {
+		h.trim()
+	}
+	h.lock.Unlock()
+}
+
+func (h *HARRecorder) trim() {
+	limit := h.MaxEntries
+	if limit == 0 {
+		limit = defaultHARMaxEntries
+	}
+	if limit < 0 {
+		return
+	}
+	if n := len(h.entries) - limit; n > 0 {
+		h.dropped += n
+		h.entries = append([]*harEntry(nil), h.entries[n:]...)
+	}
+	if n := len(h.pages) - limit; n > 0 {
+		h.pages = append([]*harPage(nil), h.pages[n:]...)
+	}
+}
+
+func (e *harEntry) setTimings(trace *HTTPTrace, started, end time.Time) {
+	e.StartedDateTime = started
+	e.Timings = harTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	if trace == nil || trace.start.IsZero() || trace.FirstByteDuration <= 0 {
+		if !end.IsZero() {
+			e.Time = harMillis(end.Sub(started))
+			e.Timings.Wait = e.Time
+		}
+		return
+	}
+	e.StartedDateTime = trace.start
+	wait := trace.FirstByteDuration
+	if !trace.connect.IsZero() && !trace.connect.Before(trace.start) && trace.ConnectDuration < wait {
+		e.Timings.Connect = harMillis(trace.ConnectDuration)
+		wait -= trace.ConnectDuration
+	}
+	e.Timings.Wait = harMillis(wait)
+	if receive := end.Sub(trace.start.Add(trace.FirstByteDuration)); !end.IsZero() && receive > 0 {
+		e.Timings.Receive = harMillis(receive)
+	}
+	e.Time = e.Timings.Wait + e.Timings.Receive + math.Max(e.Timings.Connect, 0)
+}
+
+func harRequestFor(method string, u *url.URL, proto string, headers http.Header, body []byte, redact bool) harRequest {
+	r := harRequest{
+		Method:      method,
+		URL:         u.String(),
+		HTTPVersion: proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(headers, redact),
+		QueryString: []harNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(body),
+	}
+	for name, values := range u.Query() {
+		for _, value := range values {
+			r.QueryString = append(r.QueryString, harNameValue{name, value})
+		}
+	}
+	sort.Slice(r.QueryString, func(i, j int) bool { return r.QueryString[i].Name < r.QueryString[j].Name })
+	if body != nil {
+		r.PostData = &harPostData{MimeType: headers.Get("Content-Type"), Text: string(body)}
+	}
+	return r
+}
+
+func harHeaders(h http.Header, redact bool) []harNameValue {
+	headers := []harNameValue{}
+	for name, values := range h {
+		sensitive := redact && harCredentialHeader(name)
+		for _, value := range values {
+			if sensitive {
+				value = "[REDACTED]"
+			}
+			headers = append(headers, harNameValue{name, value})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].Name < headers[j].Name })
+	return headers
+}
+
+func harCredentialHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, h := range harCredentialHeaders {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+func harMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (h *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	data, err := h.marshal()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+func (h *HARRecorder) Save() error {
+	if h.PerPage {
+		return nil
+	}
+	data, err := h.marshal()
+	if err != nil {
+		return err
+	}
+	return writeHAR(h.Path, data)
+}
+
+func (h *HARRecorder) marshal() ([]byte, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return marshalHAR(h.pages, h.entries, h.dropped)
+}
+
+func marshalHAR(pages []*harPage, entries []*harEntry, dropped int) ([]byte, error) {
+	if pages == nil {
+		pages = []*harPage{}
+	}
+	if entries == nil {
+		entries = []*harEntry{}
+	}
+	log := harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "colly"},
+		Pages:   pages,
+		Entries: entries,
+	}
+	if dropped > 0 {
+		log.Comment = fmt.Sprintf("%d older entries dropped after reaching the entry limit", dropped)
+	}
+	return json.MarshalIndent(map[string]harLog{"log": log}, "", "  ")
+}
+
+func writeHAR(filename string, data []byte) error {
+	if err := os.WriteFile(filename+"~", data, 0640); err != nil {
+		return err
+	}
+	return os.Rename(filename+"~", filename)
+}
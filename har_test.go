@@ -0,0 +1,48 @@
+package colly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHARRecordsNegotiatedProtocol(t *testing.T) {
+	tests := []struct {
+		name  string
+		http2 bool
+		proto string
+	}{
+		{"HTTP/1.1", false, "HTTP/1.1"},
+		{"HTTP/2", true, "HTTP/2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/a" {
+					http.Redirect(w, r, "/b", http.StatusFound)
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			ts.EnableHTTP2 = tt.http2
+			ts.StartTLS()
+			defer ts.Close()
+
+			c := NewCollector()
+			c.InsecureSkipVerify(true)
+			har := NewHARRecorder("", false)
+			c.SetHARRecorder(har)
+			if err := c.Visit(ts.URL + "/a"); err != nil {
+				t.Fatal(err)
+			}
+			if len(har.entries) != 2 {
+				t.Fatalf("expected a redirect hop and a final entry, got %d entries", len(har.entries))
+			}
+			for _, e := range har.entries {
+				if e.Request.HTTPVersion != tt.proto || e.Response.HTTPVersion != tt.proto {
+					t.Fatalf("expected %s, got request %q response %q for %s", tt.proto, e.Request.HTTPVersion, e.Response.HTTPVersion, e.Request.URL)
+				}
+			}
+		})
+	}
+}
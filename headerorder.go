@@ -0,0 +1,355 @@
+package colly
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2/profiles"
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+)
+
+type orderedPool struct {
+	idle map[string][]*orderedConn
+	h2   map[string]*http2.ClientConn
+	lock *sync.Mutex
+}
+
+type orderedConn struct {
+	net.Conn
+	r      *bufio.Reader
+	idleAt time.Time
+}
+
+func (p *orderedPool) roundTrip(next http.RoundTripper, t *http.Transport, req *http.Request, proxyURL *url.URL, order []string) (*http.Response, error) {
+	key := req.URL.Scheme + "://" + orderedAddr(req.URL)
+	if proxyURL != nil {
+		key += "|" + proxyURL.String()
+	}
+	if profile, ok := req.Context().Value(profileContextKey).(*profiles.Profile); ok && profile.TLS.Client != "" {
+		key += "|" + profile.TLS.Str()
+	}
+	if cc := p.h2Conn(key); cc != nil {
+		return cc.RoundTrip(req)
+	}
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	target := req.URL.RequestURI()
+	if proxyURL != nil && req.URL.Scheme == "http" {
+		target = req.URL.String()
+		if auth := proxyAuthorization(proxyURL); auth != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Proxy-Authorization", auth)
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := writeOrderedRequest(bufio.NewWriter(buf), req, target, order, body); err != nil {
+		return nil, err
+	}
+	for {
+		conn, reused := p.get(t, key)
+		if conn == nil {
+			raw, protocol, err := dialOrdered(req.Context(), next, t, req.URL, proxyURL)
+			if err != nil {
+				return nil, err
+			}
+			if protocol == "h2" {
+				cc, err := p.putH2(t, key, raw)
+				if err != nil {
+					return nil, err
+				}
+				return cc.RoundTrip(req)
+			}
+			conn = &orderedConn{Conn: raw, r: bufio.NewReader(raw)}
+		}
+		stop := context.AfterFunc(req.Context(), func() {
+			conn.Close()
+		})
+		res, err := conn.do(req, buf.Bytes())
+		if err != nil {
+			stop()
+			conn.Close()
+			if reused && req.Context().Err() == nil {
+				continue
+			}
+			return nil, err
+		}
+		res.Body = &orderedBody{
+			ReadCloser: res.Body,
+			conn:       conn,
+			stop:       stop,
+			release: func() {
+				if !res.Close && !req.Close && !t.DisableKeepAlives {
+					p.put(t, key, conn)
+				} else {
+					conn.Close()
+				}
+			},
+		}
+		return res, nil
+	}
+}
+
+func (p *orderedPool) h2Conn(key string) *http2.ClientConn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	cc := p.h2[key]
+	if cc != nil && !cc.CanTakeNewRequest() {
+		delete(p.h2, key)
+		return nil
+	}
+	return cc
+}
+
+func (p *orderedPool) putH2(t *http.Transport, key string, conn net.Conn) (*http2.ClientConn, error) {
+	cc, err := (&http2.Transport{IdleConnTimeout: t.IdleConnTimeout}).NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	p.lock.Lock()
+	if p.h2 == nil {
+		p.h2 = make(map[string]*http2.ClientConn)
+	}
+	p.h2[key] = cc
+	p.lock.Unlock()
+	return cc, nil
+}
+
+func (conn *orderedConn) do(req *http.Request, data []byte) (*http.Response, error) {
+	if _, err := conn.Write(data); err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(conn.r, req)
+}
+
+func (p *orderedPool) get(t *http.Transport, key string) (*orderedConn, bool) {
+	timeout := t.IdleConnTimeout
+	if timeout == 0 {
+		timeout = 90 * time.Second
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for conns := p.idle[key]; len(conns) > 0; conns = p.idle[key] {
+		conn := conns[len(conns)-1]
+		p.idle[key] = conns[:len(conns)-1]
+		if time.Since(conn.idleAt) < timeout {
+			return conn, true
+		}
+		conn.Close()
+	}
+	return nil, false
+}
+
+func (p *orderedPool) put(t *http.Transport, key string, conn *orderedConn) {
+	limit := t.MaxIdleConnsPerHost
+	if limit <= 0 {
+		limit = http.DefaultMaxIdleConnsPerHost
+	}
+	conn.idleAt = time.Now()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.idle[key]) >= limit {
+		conn.Close()
+		return
+	}
+	p.idle[key] = append(p.idle[key], conn)
+}
+
+type orderedBody struct {
+	io.ReadCloser
+	conn    *orderedConn
+	stop    func() bool
+	release func()
+	eof     bool
+	closed  bool
+}
+
+func (b *orderedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *orderedBody) Close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	stopped := b.stop()
+	b.ReadCloser.Close()
+	if b.eof && stopped {
+		b.release()
+		return nil
+	}
+	return b.conn.Close()
+}
+
+func orderedAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func dialOrdered(ctx context.Context, next http.RoundTripper, t *http.Transport, u, proxyURL *url.URL) (net.Conn, string, error) {
+	addr := orderedAddr(u)
+	if ut, ok := next.(*utlsTransport); ok && u.Scheme == "https" {
+		conn, err := ut.dial(ctx, proxyURL, "tcp", addr, t.Protocols != nil && !t.Protocols.HTTP2())
+		if err != nil {
+			return nil, "", err
+		}
+		return conn, conn.ConnectionState().NegotiatedProtocol, nil
+	}
+	if u.Scheme == "https" && proxyURL == nil && t.DialTLSContext != nil {
+		conn, err := t.DialTLSContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, "", err
+		}
+		if tc, ok := conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+			return conn, tc.ConnectionState().NegotiatedProtocol, nil
+		}
+		return conn, "", nil
+	}
+	var conn net.Conn
+	var err error
+	switch {
+	case proxyURL == nil:
+		conn, err = dialTransport(ctx, t, "tcp", addr)
+	case u.Scheme == "https":
+		conn, err = dialTunnel(ctx, t, proxyURL, "tcp", addr)
+	default:
+		conn, err = dialProxy(ctx, t, proxyURL, "tcp")
+	}
+	if err != nil || u.Scheme != "https" {
+		return conn, "", err
+	}
+	config := &tls.Config{}
+	if t.TLSClientConfig != nil {
+		config = t.TLSClientConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = u.Hostname()
+	}
+	if !transportHTTP2(t) {
+		config.NextProtos = withoutH2(config.NextProtos)
+	} else if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	if len(config.NextProtos) == 0 {
+		config.NextProtos = []string{"http/1.1"}
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, "", err
+	}
+	return tlsConn, tlsConn.ConnectionState().NegotiatedProtocol, nil
+}
+
+func transportHTTP2(t *http.Transport) bool {
+	if t.Protocols != nil {
+		return t.Protocols.HTTP2()
+	}
+	if _, ok := t.TLSNextProto["h2"]; ok {
+		return true
+	}
+	if t.TLSNextProto != nil {
+		return false
+	}
+	return t.ForceAttemptHTTP2 || t.TLSClientConfig == nil && t.DialContext == nil && t.DialTLSContext == nil
+}
+
+func writeOrderedRequest(w *bufio.Writer, req *http.Request, target string, order []string, body []byte) error {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !httpguts.ValidHeaderFieldName(method) {
+		return fmt.Errorf("%w: method %q", ErrInvalidHeader, method)
+	}
+	if !httpguts.ValidHostHeader(host) {
+		return fmt.Errorf("%w: host %q", ErrInvalidHeader, host)
+	}
+	fmt.Fprintf(w, "%s %s HTTP/1.1\r\n", method, target)
+	written := make(map[string]bool)
+	writeHeader := func(name string) error {
+		canonical := http.CanonicalHeaderKey(name)
+		if written[canonical] {
+			return nil
+		}
+		written[canonical] = true
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("%w: name %q", ErrInvalidHeader, name)
+		}
+		switch canonical {
+		case "Host":
+			fmt.Fprintf(w, "%s: %s\r\n", name, host)
+			return nil
+		case "Content-Length":
+			if len(body) > 0 || req.Method == "POST" || req.Method == "PUT" || req.Method == "PATCH" {
+				fmt.Fprintf(w, "%s: %d\r\n", name, len(body))
+			}
+			return nil
+		}
+		values, ok := req.Header[name]
+		if !ok {
+			values = req.Header[canonical]
+		}
+		for _, v := range values {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return fmt.Errorf("%w: value of %s", ErrInvalidHeader, name)
+			}
+			fmt.Fprintf(w, "%s: %s\r\n", name, v)
+		}
+		return nil
+	}
+	for _, name := range order {
+		if err := writeHeader(name); err != nil {
+			return err
+		}
+	}
+	writeHeader("Host")
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := writeHeader(k); err != nil {
+			return err
+		}
+	}
+	writeHeader("Content-Length")
+	w.WriteString("\r\n")
+	w.Write(body)
+	return w.Flush()
+}
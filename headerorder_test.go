@@ -0,0 +1,215 @@
+package colly
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+func rawHeaderNames(t *testing.T, r *bufio.Reader) (string, []string) {
+	t.Helper()
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Error(err)
+		return "", nil
+	}
+	var names []string
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return "", nil
+		}
+		if l == "\r\n" {
+			break
+		}
+		names = append(names, l[:strings.IndexByte(l, ':')])
+	}
+	return strings.TrimSpace(line), names
+}
+
+func TestOrderedHeadersThroughHTTPProxy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	type captured struct {
+		line  string
+		names []string
+	}
+	got := make(chan captured, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, names := rawHeaderNames(t, bufio.NewReader(conn))
+		got <- captured{line, names}
+		io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok")
+	}()
+
+	c := NewCollector(HeaderOrder("User-Agent", "Accept", "Host"))
+	if err := c.SetProxy("http://user:pass@" + ln.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	c.OnRequest(func(r *Request) {
+		r.Headers.Set("Accept", "*/*")
+	})
+	if err := c.Visit("http://example.com/path?q=1"); err != nil {
+		t.Fatal(err)
+	}
+	req := <-got
+	if req.line != "GET http://example.com/path?q=1 HTTP/1.1" {
+		t.Fatalf("expected an absolute-form request line, got %q", req.line)
+	}
+	if len(req.names) < 3 || req.names[0] != "User-Agent" || req.names[1] != "Accept" || req.names[2] != "Host" {
+		t.Fatalf("expected the configured header order, got %v", req.names)
+	}
+	if !strings.Contains(strings.Join(req.names, ","), "Proxy-Authorization") {
+		t.Fatalf("expected proxy credentials, got %v", req.names)
+	}
+}
+
+func TestOrderedHeadersThroughConnectTunnel(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	ts.Close()
+	config := ts.TLS.Clone()
+	config.NextProtos = []string{"http/1.1"}
+	origin, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer origin.Close()
+	names := make(chan []string, 1)
+	go func() {
+		conn, err := origin.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, n := rawHeaderNames(t, bufio.NewReader(conn))
+		names <- n
+		io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok")
+	}()
+
+	var connects []string
+	var lock sync.Mutex
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		lock.Lock()
+		connects = append(connects, r.Host)
+		lock.Unlock()
+		upstream, err := net.Dial("tcp", origin.Addr().String())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		client, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		io.WriteString(client, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() {
+			io.Copy(upstream, client)
+			upstream.Close()
+		}()
+		io.Copy(client, upstream)
+		client.Close()
+	}))
+	defer proxy.Close()
+
+	c := NewCollector(HeaderOrder("Accept-Language", "User-Agent"))
+	c.InsecureSkipVerify(true)
+	if err := c.SetProxy(proxy.URL); err != nil {
+		t.Fatal(err)
+	}
+	c.OnRequest(func(r *Request) {
+		r.Headers.Set("Accept-Language", "en")
+	})
+	if err := c.Visit("https://" + origin.Addr().String() + "/"); err != nil {
+		t.Fatal(err)
+	}
+	n := <-names
+	if len(n) < 2 || n[0] != "Accept-Language" || n[1] != "User-Agent" {
+		t.Fatalf("expected the configured header order over the tunnel, got %v", n)
+	}
+	if len(connects) != 1 || connects[0] != origin.Addr().String() {
+		t.Fatalf("expected a single CONNECT to the origin, got %v", connects)
+	}
+}
+
+func TestOrderedHeadersKeepTransportALPN(t *testing.T) {
+	tests := []struct {
+		name   string
+		option func(c *Collector)
+		proto  int
+	}{
+		{"default transport", func(c *Collector) {}, 2},
+		{"HTTP/2 enabled", func(c *Collector) { c.SetHTTP2Config(&http.HTTP2Config{}) }, 2},
+		{"HTTP/1 forced", func(c *Collector) { c.ForceHTTP1() }, 1},
+		{"uTLS fingerprint", func(c *Collector) { c.SetTLSFingerprint(utls.HelloChrome_Auto) }, 2},
+		{"uTLS with HTTP/1 forced", func(c *Collector) {
+			c.ForceHTTP1()
+			c.SetTLSFingerprint(utls.HelloChrome_Auto)
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lock sync.Mutex
+			var offered [][]string
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Proto", r.Proto)
+			}))
+			ts.EnableHTTP2 = true
+			ts.StartTLS()
+			defer ts.Close()
+			ts.TLS.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				lock.Lock()
+				offered = append(offered, hello.SupportedProtos)
+				lock.Unlock()
+				return nil, nil
+			}
+
+			var protos []int
+			for _, order := range [][]string{nil, {"User-Agent", "Accept"}} {
+				c := NewCollector()
+				c.InsecureSkipVerify(true)
+				tt.option(c)
+				if order != nil {
+					c.SetHeaderOrder(order...)
+				}
+				c.OnResponse(func(r *Response) {
+					if r.Headers.Get("X-Proto") == "HTTP/2.0" {
+						protos = append(protos, 2)
+					} else {
+						protos = append(protos, 1)
+					}
+				})
+				if err := c.Visit(ts.URL); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(offered) != 2 || strings.Join(offered[0], ",") != strings.Join(offered[1], ",") {
+				t.Fatalf("expected the ordered request to offer the same ALPN, got %v", offered)
+			}
+			if len(protos) != 2 || protos[0] != tt.proto || protos[1] != tt.proto {
+				t.Fatalf("expected HTTP/%d for both requests, got %v", tt.proto, protos)
+			}
+		})
+	}
+}
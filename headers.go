@@ -0,0 +1,335 @@
+package colly
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gocolly/colly/v2/profiles"
+)
+
+func (c *Collector) SetHeaderOrder(order ...string) {
+	c.headerOrder = order
+}
+
+func (c *Collector) SetDomainHeaders(glob string, headers http.Header) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	glob = strings.ToLower(glob)
+	for _, dh := range c.domainHeaders {
+		if dh.glob == glob {
+			dh.headers = headers.Clone()
+			return
+		}
+	}
+	c.domainHeaders = append(c.domainHeaders, &domainHeaders{glob: glob, headers: headers.Clone()})
+}
+
+func (c *Collector) applyDomainHeaders(req *http.Request) *http.Request {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if len(c.domainHeaders) == 0 {
+		return req
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	var hdr http.Header
+	for _, dh := range c.domainHeaders {
+		if !matchHostGlob(dh.glob, host) {
+			continue
+		}
+		for k, v := range dh.headers {
+			if current, ok := req.Header[k]; ok && !c.defaultHeader(k, current) {
+				continue
+			}
+			if hdr == nil {
+				req = req.Clone(req.Context())
+				hdr = req.Header
+			}
+			hdr[k] = append([]string(nil), v...)
+		}
+	}
+	return req
+}
+
+func (c *Collector) defaultHeader(key string, values []string) bool {
+	if c.Headers == nil {
+		return false
+	}
+	defaults, ok := (*c.Headers)[key]
+	if !ok || len(defaults) != len(values) {
+		return false
+	}
+	for i := range defaults {
+		if defaults[i] != values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Collector) cloneDomainHeaders() []*domainHeaders {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.domainHeaders == nil {
+		return nil
+	}
+	out := make([]*domainHeaders, len(c.domainHeaders))
+	for i, dh := range c.domainHeaders {
+		out[i] = &domainHeaders{glob: dh.glob, headers: dh.headers.Clone()}
+	}
+	return out
+}
+
+func (c *Collector) SetUserAgentRotator(rotator *UserAgentRotator) {
+	rotator.Init()
+	c.UserAgents = rotator
+}
+
+func (c *Collector) userAgentFor(u *url.URL) string {
+	if c.UserAgents != nil {
+		if ua := c.UserAgents.UserAgent(u); ua != "" {
+			return ua
+		}
+	}
+	return c.UserAgent
+}
+
+func (c *Collector) UseProfile(p *profiles.Profile) {
+	c.RotateProfiles(p)
+}
+
+func (c *Collector) RotateProfiles(ps ...*profiles.Profile) {
+	c.profiles = ps
+	for _, p := range ps {
+		if p.TLS.Client != "" {
+			c.SetTLSFingerprint(p.TLS)
+			return
+		}
+	}
+}
+
+func (c *Collector) nextProfile() *profiles.Profile {
+	switch len(c.profiles) {
+	case 0:
+		return nil
+	case 1:
+		return c.profiles[0]
+	}
+	i := atomic.AddUint32(&c.profileCount, 1) - 1
+	return c.profiles[int(i)%len(c.profiles)]
+}
+
+func (c *Collector) SetCorrelationID(config *CorrelationConfig) {
+	if config.Header == "" {
+		config.Header = "X-Correlation-ID"
+	}
+	if config.CtxKey == "" {
+		config.CtxKey = "correlation_id"
+	}
+	c.correlation = config
+}
+
+func (c *Collector) SetIdentification(id *Identification) {
+	if id.ContactHeader == "" {
+		id.ContactHeader = "X-Crawler-Contact"
+	}
+	c.identification = id
+}
+
+func (c *Collector) identify() *Identification {
+	if c.identification == nil {
+		c.SetIdentification(&Identification{})
+	}
+	return c.identification
+}
+
+func (c *Collector) correlationValues(r *Request, values map[string]string) map[string]string {
+	if c.correlation != nil && r != nil && r.Headers != nil {
+		if id := r.Headers.Get(c.correlation.Header); id != "" {
+			values["correlation_id"] = id
+		}
+	}
+	return values
+}
+
+type CorrelationConfig struct {
+	Header  string
+	CtxKey  string
+	JobID   string
+	Domains []string
+}
+
+func (cc *CorrelationConfig) inject(r *Request) {
+	id := r.Headers.Get(cc.Header)
+	if v, ok := r.Ctx.GetAny(cc.CtxKey).(string); ok && id == "" {
+		id = v
+	}
+	if id == "" && cc.JobID != "" {
+		id = fmt.Sprintf("%s-%d", cc.JobID, r.ID)
+	}
+	if id == "" {
+		var buf [8]byte
+		rand.Read(buf[:])
+		id = hex.EncodeToString(buf[:])
+	}
+	if r.Ctx.GetAny(cc.CtxKey) == nil {
+		r.Ctx.Put(cc.CtxKey, id)
+	}
+	if r.Headers.Get(cc.Header) != "" || !cc.sendTo(strings.ToLower(r.URL.Hostname())) {
+		return
+	}
+	r.Headers.Set(cc.Header, id)
+}
+
+func (cc *CorrelationConfig) sendTo(host string) bool {
+	return hostMatches(cc.Domains, host)
+}
+
+func (r *Request) CorrelationID() string {
+	if r.collector == nil || r.collector.correlation == nil || r.Headers == nil {
+		return ""
+	}
+	if id := r.Headers.Get(r.collector.correlation.Header); id != "" {
+		return id
+	}
+	if r.Ctx == nil {
+		return ""
+	}
+	id, _ := r.Ctx.GetAny(r.collector.correlation.CtxKey).(string)
+	return id
+}
+
+func (r *Response) CorrelationID() string {
+	if r.Request == nil {
+		return ""
+	}
+	return r.Request.CorrelationID()
+}
+
+type Identification struct {
+	From          string
+	InfoURL       string
+	Contact       string
+	ContactHeader string
+}
+
+func (id *Identification) UserAgent(ua string) string {
+	if id.InfoURL == "" || strings.Contains(ua, id.InfoURL) {
+		return ua
+	}
+	if ua == "" {
+		return "+" + id.InfoURL
+	}
+	return ua + " (+" + id.InfoURL + ")"
+}
+
+func (id *Identification) apply(hdr http.Header) {
+	if id.From != "" && hdr.Get("From") == "" {
+		hdr.Set("From", id.From)
+	}
+	if id.Contact != "" && id.ContactHeader != "" && hdr.Get(id.ContactHeader) == "" {
+		hdr.Set(id.ContactHeader, id.Contact)
+	}
+	if id.InfoURL != "" {
+		hdr.Set("User-Agent", id.UserAgent(hdr.Get("User-Agent")))
+	}
+}
+
+type UserAgentPolicy int
+
+const (
+	RotatePerRequest UserAgentPolicy = iota
+	RotatePerDomain
+	RotateWeighted
+)
+
+type UserAgentRotator struct {
+	UserAgents []string
+	Weights    []float64
+	Generator  func(u *url.URL) string
+	Policy     UserAgentPolicy
+	Sticky     bool
+	MaxSticky  int
+	count      uint32
+	sticky     map[string]string
+	lock       sync.Mutex
+}
+
+const defaultMaxStickyUserAgents = 10000
+
+func (r *UserAgentRotator) Init() {
+	r.lock.Lock()
+	if r.sticky == nil {
+		r.sticky = make(map[string]string)
+	}
+	r.lock.Unlock()
+}
+
+func (r *UserAgentRotator) UserAgent(u *url.URL) string {
+	if r.Policy != RotatePerDomain && !r.Sticky {
+		return r.pick(u)
+	}
+	host := ""
+	if u != nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if ua, ok := r.sticky[host]; ok {
+		return ua
+	}
+	ua := r.pick(u)
+	if r.sticky == nil {
+		r.sticky = make(map[string]string)
+	}
+	limit := r.MaxSticky
+	if limit <= 0 {
+		limit = defaultMaxStickyUserAgents
+	}
+	for h := range r.sticky {
+		if len(r.sticky) < limit {
+			break
+		}
+		delete(r.sticky, h)
+	}
+	r.sticky[host] = ua
+	return ua
+}
+
+func (r *UserAgentRotator) pick(u *url.URL) string {
+	if r.Generator != nil {
+		return r.Generator(u)
+	}
+	if len(r.UserAgents) == 0 {
+		return ""
+	}
+	if r.Policy == RotateWeighted && len(r.Weights) == len(r.UserAgents) {
+		total := 0.0
+		for _, w := range r.Weights {
+			total += w
+		}
+		if total > 0 {
+			n := mathrand.Float64() * total
+			for i, w := range r.Weights {
+				if n < w {
+					return r.UserAgents[i]
+				}
+				n -= w
+			}
+			return r.UserAgents[len(r.UserAgents)-1]
+		}
+	}
+	i := atomic.AddUint32(&r.count, 1) - 1
+	return r.UserAgents[int(i)%len(r.UserAgents)]
+}
+
+type domainHeaders struct {
+	glob    string
+	headers http.Header
+}
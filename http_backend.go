@@ -0,0 +1,216 @@
+package colly
+
+import (
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"compress/gzip"
+
+	"github.com/gobwas/glob"
+)
+
+type httpBackend struct {
+	LimitRules     []*LimitRule
+	Client         *http.Client
+	lock           *sync.RWMutex
+	domainRequests sync.Map
+}
+
+type checkHeadersFunc func(req *http.Request, statusCode int, header http.Header) bool
+
+type LimitRule struct {
+	DomainRegexp   string
+	DomainGlob     string
+	Delay          time.Duration
+	RandomDelay    time.Duration
+	Parallelism    int
+	waitChan       chan bool
+	compiledRegexp *regexp.Regexp
+	compiledGlob   glob.Glob
+}
+
+func (r *LimitRule) Init() error {
+	waitChanSize := 1
+	if r.Parallelism > 1 {
+		waitChanSize = r.Parallelism
+	}
+	r.waitChan = make(chan bool, waitChanSize)
+	hasPattern := false
+	if r.DomainRegexp != "" {
+		c, err := regexp.Compile(r.DomainRegexp)
+		if err != nil {
+			return err
+		}
+		r.compiledRegexp = c
+		hasPattern = true
+	}
+	if r.DomainGlob != "" {
+		c, err := glob.Compile(r.DomainGlob)
+		if err != nil {
+			return err
+		}
+		r.compiledGlob = c
+		hasPattern = true
+	}
+	if !hasPattern {
+		return ErrNoPattern
+	}
+	return nil
+}
+
+func (h *httpBackend) Init(jar http.CookieJar) {
+	rand.Seed(time.Now().UnixNano())
+	h.Client = &http.Client{
+		Jar:     jar,
+		Timeout: 10 * time.Second,
+	}
+	h.lock = &sync.RWMutex{}
+}
+
+func (r *LimitRule) Match(domain string) bool {
+	match := false
+	if r.compiledRegexp != nil && r.compiledRegexp.MatchString(domain) {
+		match = true
+	}
+	if r.compiledGlob != nil && r.compiledGlob.Match(domain) {
+		match = true
+	}
+	return match
+}
+
+func (h *httpBackend) GetMatchingRule(domain string) *LimitRule {
+	if h.LimitRules == nil {
+		return nil
+	}
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	for _, r := range h.LimitRules {
+		if r.Match(domain) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (h *httpBackend) Cache(request *http.Request, bodySize int, checkHeadersFunc checkHeadersFunc, cacheDir string) (*Response, error) {
+	if cacheDir == "" || request.Method != "GET" || request.Header.Get("Cache-Control") == "no-cache" {
+		return h.Do(request, bodySize, checkHeadersFunc)
+	}
+	sum := sha1.Sum([]byte(request.URL.String()))
+	hash := hex.EncodeToString(sum[:])
+	dir := path.Join(cacheDir, hash[:2])
+	filename := path.Join(dir, hash)
+	if file, err := os.Open(filename); err == nil {
+		resp := new(Response)
+		err := gob.NewDecoder(file).Decode(resp)
+		file.Close()
+		checkHeadersFunc(request, resp.StatusCode, *resp.Headers)
+		if resp.StatusCode < 500 {
+			return resp, err
+		}
+	}
+	resp, err := h.Do(request, bodySize, checkHeadersFunc)
+	if err != nil || resp.StatusCode >= 500 {
+		return resp, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return resp, err
+		}
+	}
+	file, err := os.Create(filename + "~")
+	if err != nil {
+		return resp, err
+	}
+	if err := gob.NewEncoder(file).Encode(resp); err != nil {
+		file.Close()
+		return resp, err
+	}
+	file.Close()
+	return resp, os.Rename(filename+"~", filename)
+}
+
+func (h *httpBackend) Do(request *http.Request, bodySize int, checkHeadersFunc checkHeadersFunc) (*Response, error) {
+	r := h.GetMatchingRule(request.URL.Host)
+	if r != nil {
+		r.waitChan <- true
+		defer func(r *LimitRule) {
+			randomDelay := time.Duration(0)
+			if r.RandomDelay != 0 {
+				randomDelay = time.Duration(rand.Int63n(int64(r.RandomDelay)))
+			}
+			time.Sleep(r.Delay + randomDelay)
+			<-r.waitChan
+		}(r)
+	}
+
+	res, err := h.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	finalRequest := request
+	if res.Request != nil {
+		finalRequest = res.Request
+	}
+	if !checkHeadersFunc(finalRequest, res.StatusCode, res.Header) {
+		return nil, ErrAbortedAfterHeaders
+	}
+
+	var bodyReader io.Reader = res.Body
+	if bodySize > 0 {
+		bodyReader = io.LimitReader(bodyReader, int64(bodySize))
+	}
+	contentEncoding := strings.ToLower(res.Header.Get("Content-Encoding"))
+	if !res.Uncompressed && (strings.Contains(contentEncoding, "gzip") || (contentEncoding == "" && strings.Contains(strings.ToLower(res.Header.Get("Content-Type")), "gzip")) || strings.HasSuffix(strings.ToLower(finalRequest.URL.Path), ".xml.gz")) {
+		bodyReader, err = gzip.NewReader(bodyReader)
+		if err != nil {
+			return nil, err
+		}
+		defer bodyReader.(*gzip.Reader).Close()
+	}
+	sizeHint := res.ContentLength
+	if bodySize > 0 && sizeHint > int64(bodySize) {
+		sizeHint = int64(bodySize)
+	}
+	body, err := readBody(bodyReader, sizeHint)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{
+		StatusCode: res.StatusCode,
+		Body:       body,
+		Headers:    &res.Header,
+		proto:      res.Proto,
+	}, nil
+}
+
+func (h *httpBackend) Limit(rule *LimitRule) error {
+	h.lock.Lock()
+	if h.LimitRules == nil {
+		h.LimitRules = make([]*LimitRule, 0, 8)
+	}
+	h.LimitRules = append(h.LimitRules, rule)
+	h.lock.Unlock()
+	return rule.Init()
+}
+
+func (h *httpBackend) Limits(rules []*LimitRule) error {
+	for _, r := range rules {
+		if err := h.Limit(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package colly
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type InteractionStep struct {
+	Click       string
+	Script      string
+	WaitVisible string
+	Wait        time.Duration
+}
+
+type Interactor interface {
+	Interact(ctx context.Context, r *Request, steps []InteractionStep) ([]*http.Cookie, error)
+}
+
+type Interstitial struct {
+	Name        string
+	Markers     []string
+	Detect      func(*Response) bool
+	Steps       []InteractionStep
+	MaxBodySize int
+}
+
+const defaultInterstitialMaxBodySize = 64 << 10
+
+var (
+	CookieConsent = &Interstitial{
+		Name: "cookie-consent",
+		Markers: []string{
+			"onetrust-banner-sdk",
+			"cybotcookiebotdialog",
+			"didomi-notice",
+			"qc-cmp2-container",
+			"truste-consent-track",
+			"usercentrics-root",
+		},
+		Steps: []InteractionStep{
+			{Click: "#onetrust-accept-btn-handler"},
+			{Click: "#CybotCookiebotDialogBodyLevelButtonLevelOptinAllowAll"},
+			{Click: "#didomi-notice-agree-button"},
+			{Click: ".qc-cmp2-summary-buttons button[mode=primary]"},
+			{Click: "#truste-consent-button"},
+			{Script: `document.querySelector("#usercentrics-root")?.shadowRoot?.querySelector("[data-testid=uc-accept-all-button]")?.click()`},
+			{Wait: time.Second},
+		},
+	}
+	AgeGate = &Interstitial{
+		Name: "age-gate",
+		Markers: []string{
+			"age-gate",
+			"agegate",
+			"age_gate",
+			"verify your age",
+		},
+		Steps: []InteractionStep{
+			{Click: "[data-age-gate-confirm]"},
+			{Click: ".age-gate__submit--yes"},
+			{Click: "#age-gate-yes"},
+			{Wait: time.Second},
+		},
+	}
+)
+
+func (i *Interstitial) Matches(r *Response) bool {
+	if i.Detect != nil {
+		return i.Detect(r)
+	}
+	limit := i.MaxBodySize
+	if limit <= 0 {
+		limit = defaultInterstitialMaxBodySize
+	}
+	if len(r.Body) > limit {
+		challenge := false
+		for _, code := range defaultBanChallengeStatusCodes {
+			if r.StatusCode == code {
+				challenge = true
+				break
+			}
+		}
+		if !challenge {
+			return false
+		}
+	}
+	body := bytes.ToLower(r.Body)
+	for _, m := range i.Markers {
+		if bytes.Contains(body, []byte(strings.ToLower(m))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Collector) HandleInterstitials(interactor Interactor, interstitials ...*Interstitial) {
+	if len(interstitials) == 0 {
+		interstitials = []*Interstitial{CookieConsent, AgeGate}
+	}
+	c.interactor = interactor
+	c.interstitials = interstitials
+}
+
+func (c *Collector) matchInterstitial(r *Response) *Interstitial {
+	for _, i := range c.interstitials {
+		if i.Matches(r) {
+			return i
+		}
+	}
+	return nil
+}
+
+func (c *Collector) passInterstitial(req *http.Request, r *Response, matched *Interstitial) (bool, error) {
+	interactor := c.interactor
+	if i, ok := c.rendererFor(r.Request).(Interactor); ok {
+		interactor = i
+	}
+	if interactor == nil || c.ssrf != nil {
+		return false, nil
+	}
+	if c.offline {
+		return false, &NotCachedError{URL: r.Request.URL.String(), Method: r.Request.Method}
+	}
+	cookies, err := interactor.Interact(req.Context(), r.Request, matched.Steps)
+	if c.debugger != nil {
+		values := map[string]string{
+			"url":          r.Request.URL.String(),
+			"interstitial": matched.Name,
+		}
+		if err != nil {
+			values["error"] = err.Error()
+		}
+		c.debugger.Event(createEvent("interstitial", r.Request.ID, c.ID, values))
+	}
+	if err != nil {
+		return false, nil
+	}
+	if len(cookies) > 0 && c.backend.Client.Jar != nil {
+		c.backend.Client.Jar.SetCookies(r.Request.URL, cookies)
+	}
+	return true, nil
+}
@@ -0,0 +1,143 @@
+package colly
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+func (c *Collector) isLanguageAllowed(resp *Response) bool {
+	if len(c.AllowedLanguages) == 0 {
+		return true
+	}
+	lang := resp.Language()
+	if lang == "" {
+		return true
+	}
+	for _, l := range c.AllowedLanguages {
+		if primaryLanguage(l) == lang {
+			return true
+		}
+	}
+	if c.debugger != nil {
+		c.debugger.Event(createEvent("languageFiltered", resp.Request.ID, c.ID, map[string]string{
+			"url":      resp.Request.URL.String(),
+			"language": lang,
+		}))
+	}
+	return false
+}
+
+var (
+	htmlLangAttr     = regexp.MustCompile(`(?i)<html[^>]*\slang\s*=\s*["']?([a-zA-Z]{2,3}(?:[-_][a-zA-Z0-9]+)*)`)
+	metaLanguageAttr = regexp.MustCompile(`(?i)<meta[^>]+http-equiv\s*=\s*["']?content-language["']?[^>]+content\s*=\s*["']?([a-zA-Z-]+)`)
+	languageTags     = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>|<[^>]*>`)
+	languageWords    = map[string][]string{
+		"en": {"the", "and", "of", "to", "is", "in", "that", "it", "for", "with", "was", "on", "are", "this"},
+		"de": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "mit", "den", "auf", "sich", "auch", "dem"},
+		"fr": {"le", "la", "les", "et", "est", "des", "une", "dans", "pour", "que", "qui", "pas", "sur", "avec"},
+		"es": {"el", "la", "los", "las", "y", "es", "que", "del", "una", "por", "para", "con", "como", "pero"},
+		"it": {"il", "di", "che", "è", "della", "per", "una", "sono", "gli", "non", "con", "nel", "anche", "come"},
+		"pt": {"o", "os", "que", "não", "uma", "do", "da", "em", "para", "com", "mais", "como", "são", "pelo"},
+		"nl": {"de", "het", "een", "en", "van", "is", "niet", "dat", "op", "zijn", "met", "voor", "ook", "maar"},
+		"sv": {"och", "att", "det", "som", "är", "en", "på", "för", "med", "inte", "av", "till", "den", "har"},
+		"pl": {"i", "w", "nie", "się", "na", "że", "jest", "do", "to", "z", "jak", "ale", "po", "co"},
+	}
+)
+
+func (r *Response) Language() string {
+	if r.Headers != nil {
+		if l := r.Headers.Get("Content-Language"); l != "" {
+			return primaryLanguage(strings.Split(l, ",")[0])
+		}
+	}
+	head := r.Body
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	if m := htmlLangAttr.FindSubmatch(head); m != nil {
+		return primaryLanguage(string(m[1]))
+	}
+	if m := metaLanguageAttr.FindSubmatch(head); m != nil {
+		return primaryLanguage(string(m[1]))
+	}
+	contentType := ""
+	if r.Headers != nil {
+		contentType = strings.ToLower(r.Headers.Get("Content-Type"))
+	}
+	if contentType != "" && !strings.Contains(contentType, "text") && !strings.Contains(contentType, "html") && !strings.Contains(contentType, "xml") {
+		return ""
+	}
+	return DetectLanguage(string(languageTags.ReplaceAll(r.Body, []byte(" "))))
+}
+
+func DetectLanguage(text string) string {
+	if len(text) > 64*1024 {
+		text = text[:64*1024]
+	}
+	scripts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			scripts["ja"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["ko"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["zh"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts["ar"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["el"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["he"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["th"]++
+		case unicode.Is(unicode.Devanagari, r):
+			scripts["hi"]++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	if scripts["ja"] > 0 && scripts["ja"]+scripts["zh"] > letters/3 {
+		return "ja"
+	}
+	for lang, n := range scripts {
+		if n > letters/3 {
+			return lang
+		}
+	}
+	counts := make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for lang, words := range languageWords {
+			for _, sw := range words {
+				if w == sw {
+					counts[lang]++
+					break
+				}
+			}
+		}
+	}
+	best, bestCount := "", 2
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+	return best
+}
+
+func primaryLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
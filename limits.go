@@ -0,0 +1,285 @@
+package colly
+
+import (
+	"context"
+	"net/url"
+
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func (c *Collector) SetMaxConcurrency(n int) {
+	if n <= 0 {
+		c.concurrency = nil
+		return
+	}
+	c.concurrency = make(chan struct{}, n)
+}
+
+func (c *Collector) InFlight() int {
+	if c.concurrency == nil {
+		return 0
+	}
+	return len(c.concurrency)
+}
+
+func (c *Collector) acquireSlot(ctx context.Context) (func(), error) {
+	sem := c.concurrency
+	if sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type QueueOverflow int
+
+const (
+	BlockWhenFull QueueOverflow = iota
+	FailWhenFull
+)
+
+type workerPool struct {
+	workers   int
+	queueSize int
+	overflow  QueueOverflow
+	queue     []poolJob
+	running   int
+	waiting   int
+	active    map[*Context]int
+	lock      *sync.Mutex
+	notFull   *sync.Cond
+}
+
+type poolJob struct {
+	ctx *Context
+	run func()
+}
+
+func (c *Collector) SetWorkerPool(workers, queueSize int, overflow QueueOverflow) {
+	if workers <= 0 {
+		c.workerPool = nil
+		return
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	lock := &sync.Mutex{}
+	c.workerPool = &workerPool{
+		workers:   workers,
+		queueSize: queueSize,
+		overflow:  overflow,
+		active:    make(map[*Context]int),
+		lock:      lock,
+		notFull:   sync.NewCond(lock),
+	}
+}
+
+func (c *Collector) QueueLength() int {
+	if c.workerPool == nil {
+		return 0
+	}
+	c.workerPool.lock.Lock()
+	defer c.workerPool.lock.Unlock()
+	return len(c.workerPool.queue)
+}
+
+func (p *workerPool) submit(ctx *Context, run func()) error {
+	job := poolJob{ctx: ctx, run: run}
+	p.lock.Lock()
+	if p.running >= p.workers && len(p.queue) >= p.queueSize && p.active[ctx] == 0 {
+		if p.overflow == FailWhenFull {
+			p.lock.Unlock()
+			return ErrQueueFull
+		}
+		p.waiting++
+		p.notFull.Broadcast()
+		for p.running >= p.workers && len(p.queue) >= p.queueSize {
+			if p.stalled() {
+				p.waiting--
+				p.lock.Unlock()
+				p.run(job)
+				return nil
+			}
+			p.notFull.Wait()
+		}
+		p.waiting--
+	}
+	if p.running < p.workers {
+		p.running++
+		go p.work(job)
+	} else {
+		p.queue = append(p.queue, job)
+	}
+	p.lock.Unlock()
+	return nil
+}
+
+func (p *workerPool) stalled() bool {
+	return p.waiting >= p.running
+}
+
+func (p *workerPool) run(job poolJob) {
+	p.lock.Lock()
+	p.active[job.ctx]++
+	p.lock.Unlock()
+	defer func() {
+		p.lock.Lock()
+		if p.active[job.ctx]--; p.active[job.ctx] == 0 {
+			delete(p.active, job.ctx)
+		}
+		p.lock.Unlock()
+	}()
+	job.run()
+}
+
+func (p *workerPool) work(job poolJob) {
+	for job.run != nil {
+		p.run(job)
+		p.lock.Lock()
+		job = poolJob{}
+		if len(p.queue) > 0 {
+			job = p.queue[0]
+			p.queue[0] = poolJob{}
+			p.queue = p.queue[1:]
+		} else {
+			p.running--
+		}
+		p.notFull.Broadcast()
+		p.lock.Unlock()
+	}
+}
+
+func (c *Collector) durationExceeded() bool {
+	if c.MaxDuration <= 0 {
+		return false
+	}
+	start := atomic.LoadInt64(&c.crawlStart)
+	if start == 0 {
+		atomic.CompareAndSwapInt64(&c.crawlStart, 0, time.Now().UnixNano())
+		start = atomic.LoadInt64(&c.crawlStart)
+	}
+	return time.Since(time.Unix(0, start)) >= c.MaxDuration
+}
+
+func (c *Collector) SetDomainRequestBudget(f func(domain string) uint32) {
+	c.domainBudgetFunc = f
+}
+
+func (c *Collector) DomainRequestCount(domain string) uint32 {
+	if count, ok := c.backend.domainRequests.Load(strings.ToLower(domain)); ok {
+		return atomic.LoadUint32(count.(*uint32))
+	}
+	return 0
+}
+
+func (c *Collector) DomainRequestCounts() map[string]uint32 {
+	counts := make(map[string]uint32)
+	c.backend.domainRequests.Range(func(k, v interface{}) bool {
+		counts[k.(string)] = atomic.LoadUint32(v.(*uint32))
+		return true
+	})
+	return counts
+}
+
+func (c *Collector) domainBudget(domain string) uint32 {
+	if c.domainBudgetFunc != nil {
+		return c.domainBudgetFunc(strings.ToLower(domain))
+	}
+	return c.MaxRequestsPerDomain
+}
+
+func (c *Collector) domainBudgetExhausted(domain string) bool {
+	max := c.domainBudget(domain)
+	return max > 0 && c.DomainRequestCount(domain) >= max
+}
+
+func (c *Collector) reserveDomainRequest(domain string) bool {
+	max := c.domainBudget(domain)
+	count, _ := c.backend.domainRequests.LoadOrStore(strings.ToLower(domain), new(uint32))
+	for {
+		n := atomic.LoadUint32(count.(*uint32))
+		if max > 0 && n >= max {
+			return false
+		}
+		if atomic.CompareAndSwapUint32(count.(*uint32), n, n+1) {
+			return true
+		}
+	}
+}
+
+func (c *Collector) releaseDomainRequest(domain string) {
+	if count, ok := c.backend.domainRequests.Load(strings.ToLower(domain)); ok {
+		atomic.AddUint32(count.(*uint32), ^uint32(0))
+	}
+}
+
+type domainDepth struct {
+	glob  string
+	depth int
+}
+
+func (c *Collector) SetDomainMaxDepth(glob string, depth int) {
+	glob = strings.ToLower(glob)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	depths := append(make([]domainDepth, 0, len(c.domainDepths)+1), c.domainDepths...)
+	for i, d := range depths {
+		if d.glob == glob {
+			depths[i].depth = depth
+			c.domainDepths = depths
+			return
+		}
+	}
+	c.domainDepths = append(depths, domainDepth{glob: glob, depth: depth})
+}
+
+func (c *Collector) maxDepthFor(host string) int {
+	c.lock.RLock()
+	depths := c.domainDepths
+	c.lock.RUnlock()
+	host = strings.ToLower(host)
+	for _, d := range depths {
+		if matchHostGlob(d.glob, host) {
+			return d.depth
+		}
+	}
+	return c.MaxDepth
+}
+
+func (c *Collector) isSchemeAllowed(scheme string) bool {
+	if len(c.AllowedSchemes) == 0 {
+		return true
+	}
+	for _, s := range c.AllowedSchemes {
+		if strings.EqualFold(strings.TrimSpace(s), scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Collector) checkURLComplexity(parsedURL *url.URL, u string) error {
+	if c.MaxURLLength > 0 && len(u) > c.MaxURLLength {
+		return ErrURLTooLong
+	}
+	if c.MaxQueryParams > 0 && parsedURL.RawQuery != "" {
+		params := strings.FieldsFunc(parsedURL.RawQuery, func(c rune) bool { return c == '&' })
+		if len(params) > c.MaxQueryParams {
+			return ErrTooManyQueryParams
+		}
+	}
+	if c.MaxPathSegments > 0 {
+		segments := strings.FieldsFunc(parsedURL.EscapedPath(), func(c rune) bool { return c == '/' })
+		if len(segments) > c.MaxPathSegments {
+			return ErrTooManyPathSegments
+		}
+	}
+	return nil
+}
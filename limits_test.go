@@ -0,0 +1,161 @@
+package colly
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func linkServer(t *testing.T, pages int) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			w.Write([]byte("<p>leaf</p>"))
+			return
+		}
+		var b strings.Builder
+		for i := 0; i < pages; i++ {
+			fmt.Fprintf(&b, `<a href="/p%d">p</a>`, i)
+		}
+		w.Write([]byte(b.String()))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func waitDone(t *testing.T, c *Collector) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		c.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("crawl deadlocked")
+	}
+}
+
+func TestWorkerPoolBoundsConcurrencyAndBlocksVisit(t *testing.T) {
+	release := make(chan struct{})
+	var inFlight, peak int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer ts.Close()
+
+	c := NewCollector(Async(), WorkerPool(2, 1, BlockWhenFull))
+	for i := 0; i < 3; i++ {
+		if err := c.Visit(fmt.Sprintf("%s/%d", ts.URL, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- c.Visit(ts.URL + "/3")
+	}()
+	select {
+	case err := <-blocked:
+		t.Fatalf("expected Visit to block while the pool is full, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := c.QueueLength(); got != 1 {
+		t.Fatalf("expected 1 queued request, got %d", got)
+	}
+	close(release)
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+	waitDone(t, c)
+	if peak > 2 {
+		t.Fatalf("expected at most 2 concurrent fetches, got %d", peak)
+	}
+}
+
+func TestWorkerPoolFailWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+
+	c := NewCollector(Async(), WorkerPool(1, 1, FailWhenFull))
+	for i := 0; i < 2; i++ {
+		if err := c.Visit(fmt.Sprintf("%s/%d", ts.URL, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Visit(ts.URL + "/2"); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	close(release)
+	waitDone(t, c)
+}
+
+func TestWorkerPoolReentrantVisits(t *testing.T) {
+	tests := []struct {
+		name  string
+		visit func(c *Collector, e *HTMLElement)
+	}{
+		{"request visit", func(c *Collector, e *HTMLElement) {
+			e.Request.Visit(e.Attr("href"))
+		}},
+		{"collector visit", func(c *Collector, e *HTMLElement) {
+			c.Visit(e.Request.AbsoluteURL(e.Attr("href")))
+		}},
+		{"request visit from a goroutine", func(c *Collector, e *HTMLElement) {
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e.Request.Visit(e.Attr("href"))
+			}()
+			wg.Wait()
+		}},
+		{"collector visit from a goroutine", func(c *Collector, e *HTMLElement) {
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Visit(e.Request.AbsoluteURL(e.Attr("href")))
+			}()
+			wg.Wait()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := linkServer(t, 20)
+			c := NewCollector(Async(), WorkerPool(1, 0, BlockWhenFull))
+			var visited int32
+			c.OnResponse(func(r *Response) {
+				atomic.AddInt32(&visited, 1)
+			})
+			c.OnHTML("a[href]", func(e *HTMLElement) {
+				tt.visit(c, e)
+			})
+			if err := c.Visit(ts.URL + "/"); err != nil {
+				t.Fatal(err)
+			}
+			waitDone(t, c)
+			if visited != 21 {
+				t.Fatalf("expected 21 responses, got %d", visited)
+			}
+		})
+	}
+}
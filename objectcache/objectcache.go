@@ -0,0 +1,223 @@
+package objectcache
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+var (
+	ErrMissingBucket    = errors.New("Bucket is not set")
+	ErrUnexpectedStatus = errors.New("Unexpected status from object store")
+)
+
+type Layout func(u *url.URL, hash string) string
+
+type Cache struct {
+	Endpoint  string
+	Bucket    string
+	Prefix    string
+	PathStyle bool
+	Layout    Layout
+	Signer    colly.RequestSigner
+	Client    *http.Client
+}
+
+func NewS3(bucket, region, accessKeyID, secretAccessKey string) *Cache {
+	return &Cache{
+		Endpoint: "https://s3." + region + ".amazonaws.com",
+		Bucket:   bucket,
+		Signer: &colly.SigV4Signer{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			Region:          region,
+			Service:         "s3",
+		},
+	}
+}
+
+func NewGCS(bucket, accessKeyID, secretAccessKey string) *Cache {
+	return &Cache{
+		Endpoint:  "https://storage.googleapis.com",
+		Bucket:    bucket,
+		PathStyle: true,
+		Signer: &colly.SigV4Signer{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			Region:          "auto",
+			Service:         "s3",
+		},
+	}
+}
+
+func HashLayout(u *url.URL, hash string) string {
+	return hash[:2] + "/" + hash
+}
+
+func HostLayout(u *url.URL, hash string) string {
+	return strings.ToLower(u.Hostname()) + "/" + hash[:2] + "/" + hash
+}
+
+func PathLayout(u *url.URL, hash string) string {
+	p := path.Clean("/" + u.EscapedPath())
+	if p == "/" {
+		p = "/index"
+	}
+	return strings.ToLower(u.Hostname()) + p + "/" + hash[:8]
+}
+
+func (s *Cache) Init() error {
+	if s.Bucket == "" {
+		return ErrMissingBucket
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = "https://s3.amazonaws.com"
+	}
+	if s.Layout == nil {
+		s.Layout = HostLayout
+	}
+	if s.Client == nil {
+		s.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return nil
+}
+
+func (s *Cache) Get(key string) ([]byte, error) {
+	return s.GetURL(key, "")
+}
+
+func (s *Cache) GetURL(key, u string) ([]byte, error) {
+	res, err := s.do("GET", key, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, colly.ErrCacheMiss
+	}
+	if res.StatusCode == http.StatusForbidden {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		if errorCode(msg) == "AccessDenied" {
+			return nil, colly.ErrCacheMiss
+		}
+		res.Body = io.NopCloser(bytes.NewReader(msg))
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, statusError("GET", key, res)
+	}
+	return io.ReadAll(res.Body)
+}
+
+func errorCode(msg []byte) string {
+	var e struct {
+		Code string `xml:"Code"`
+	}
+	if err := xml.Unmarshal(msg, &e); err != nil {
+		return ""
+	}
+	return e.Code
+}
+
+func (s *Cache) Set(key string, value []byte) error {
+	return s.SetURL(key, "", value)
+}
+
+func (s *Cache) SetURL(key, u string, value []byte) error {
+	res, err := s.do("PUT", key, u, value)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return statusError("PUT", key, res)
+	}
+	return nil
+}
+
+func (s *Cache) Delete(key string) error {
+	return s.DeleteURL(key, "")
+}
+
+func (s *Cache) DeleteURL(key, u string) error {
+	res, err := s.do("DELETE", key, u, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 && res.StatusCode != http.StatusNotFound {
+		return statusError("DELETE", key, res)
+	}
+	return nil
+}
+
+func (s *Cache) ObjectKey(key, u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		parsed = &url.URL{}
+	}
+	return strings.TrimPrefix(s.Prefix+s.Layout(parsed, objectHash(key)), "/")
+}
+
+func objectHash(key string) string {
+	if len(key) == sha1.Size*2 {
+		if _, err := hex.DecodeString(key); err == nil {
+			return key
+		}
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Cache) objectURL(key, u string) (*url.URL, error) {
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	object := s.ObjectKey(key, u)
+	if s.PathStyle {
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + s.Bucket + "/" + object
+	} else {
+		endpoint.Host = s.Bucket + "." + endpoint.Host
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + object
+	}
+	return endpoint, nil
+}
+
+func (s *Cache) do(method, key, u string, body []byte) (*http.Response, error) {
+	object, err := s.objectURL(key, u)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, object.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if method == "PUT" {
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if u != "" {
+			req.Header.Set("X-Amz-Meta-Url", url.QueryEscape(u))
+		}
+	}
+	if s.Signer != nil {
+		if err := s.Signer.Sign(req, body); err != nil {
+			return nil, err
+		}
+	}
+	return s.Client.Do(req)
+}
+
+func statusError(method, key string, res *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("%w: %s %s: %d %s", ErrUnexpectedStatus, method, key, res.StatusCode, bytes.TrimSpace(msg))
+}
@@ -0,0 +1,102 @@
+package objectcache
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func objectServer(t *testing.T) (*httptest.Server, map[string]string) {
+	t.Helper()
+	objects := make(map[string]string)
+	var lock sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			if body, ok := objects[r.URL.Path]; ok {
+				io.WriteString(w, body)
+				return
+			}
+			switch {
+			case strings.Contains(r.URL.Path, "/denied/"):
+				w.WriteHeader(http.StatusForbidden)
+				io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+			case strings.Contains(r.URL.Path, "/badkey/"):
+				w.WriteHeader(http.StatusForbidden)
+				io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>InvalidAccessKeyId</Code><Message>The key does not exist</Message></Error>`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, objects
+}
+
+func TestCacheGetMisses(t *testing.T) {
+	ts, _ := objectServer(t)
+	tests := []struct {
+		prefix string
+		miss   bool
+	}{
+		{"missing/", true},
+		{"denied/", true},
+		{"badkey/", false},
+	}
+	for _, tt := range tests {
+		s := &Cache{Endpoint: ts.URL, Bucket: "bucket", PathStyle: true, Prefix: tt.prefix}
+		if err := s.Init(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.GetURL("key", "http://example.com/")
+		if miss := errors.Is(err, colly.ErrCacheMiss); miss != tt.miss {
+			t.Fatalf("%s: expected miss=%v, got %v", tt.prefix, tt.miss, err)
+		}
+		if !tt.miss && (!errors.Is(err, ErrUnexpectedStatus) || !strings.Contains(err.Error(), "InvalidAccessKeyId")) {
+			t.Fatalf("%s: expected the error code in the status error, got %v", tt.prefix, err)
+		}
+	}
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	ts, objects := objectServer(t)
+	s := &Cache{Endpoint: ts.URL, Bucket: "bucket", PathStyle: true, Prefix: "pages/"}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetURL("key", "http://Example.com/a", []byte("body")); err != nil {
+		t.Fatal(err)
+	}
+	object := "/bucket/" + s.ObjectKey("key", "http://Example.com/a")
+	if !strings.HasPrefix(object, "/bucket/pages/example.com/") {
+		t.Fatalf("unexpected object path %s", object)
+	}
+	if objects[object] != "body" {
+		t.Fatalf("expected the object to be stored at %s, got %v", object, objects)
+	}
+	data, err := s.GetURL("key", "http://Example.com/a")
+	if err != nil || string(data) != "body" {
+		t.Fatalf("unexpected value %q, %v", data, err)
+	}
+	if err := s.DeleteURL("key", "http://Example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetURL("key", "http://Example.com/a"); !errors.Is(err, colly.ErrCacheMiss) {
+		t.Fatalf("expected a miss after delete, got %v", err)
+	}
+}
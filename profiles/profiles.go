@@ -0,0 +1,131 @@
+package profiles
+
+import (
+	"net/http"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+type Header struct {
+	Name  string
+	Value string
+}
+
+type Profile struct {
+	Name           string
+	UserAgent      string
+	AcceptLanguage string
+	Headers        []Header
+	HeaderOrder    []string
+	TLS            utls.ClientHelloID
+}
+
+func (p *Profile) Header() http.Header {
+	hdr := http.Header{}
+	for _, h := range p.Headers {
+		hdr.Add(h.Name, h.Value)
+	}
+	if p.UserAgent != "" {
+		hdr.Set("User-Agent", p.UserAgent)
+	}
+	if p.AcceptLanguage != "" {
+		hdr.Set("Accept-Language", p.AcceptLanguage)
+	}
+	return hdr
+}
+
+func (p *Profile) WithAcceptLanguage(lang string) *Profile {
+	profile := *p
+	profile.AcceptLanguage = lang
+	return &profile
+}
+
+var Chrome120 = &Profile{
+	Name:           "chrome120",
+	UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	AcceptLanguage: "en-US,en;q=0.9",
+	Headers: []Header{
+		{"sec-ch-ua", `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`},
+		{"sec-ch-ua-mobile", "?0"},
+		{"sec-ch-ua-platform", `"Windows"`},
+		{"Upgrade-Insecure-Requests", "1"},
+		{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"},
+		{"Sec-Fetch-Site", "none"},
+		{"Sec-Fetch-Mode", "navigate"},
+		{"Sec-Fetch-User", "?1"},
+		{"Sec-Fetch-Dest", "document"},
+		{"Accept-Encoding", "gzip, deflate, br"},
+	},
+	HeaderOrder: []string{
+		"Host",
+		"Connection",
+		"sec-ch-ua",
+		"sec-ch-ua-mobile",
+		"sec-ch-ua-platform",
+		"Upgrade-Insecure-Requests",
+		"User-Agent",
+		"Accept",
+		"Sec-Fetch-Site",
+		"Sec-Fetch-Mode",
+		"Sec-Fetch-User",
+		"Sec-Fetch-Dest",
+		"Accept-Encoding",
+		"Accept-Language",
+		"Cookie",
+	},
+	TLS: utls.HelloChrome_120,
+}
+
+var Firefox120 = &Profile{
+	Name:           "firefox120",
+	UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:120.0) Gecko/20100101 Firefox/120.0",
+	AcceptLanguage: "en-US,en;q=0.5",
+	Headers: []Header{
+		{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"},
+		{"Accept-Encoding", "gzip, deflate, br"},
+		{"Upgrade-Insecure-Requests", "1"},
+		{"Sec-Fetch-Dest", "document"},
+		{"Sec-Fetch-Mode", "navigate"},
+		{"Sec-Fetch-Site", "none"},
+		{"Sec-Fetch-User", "?1"},
+		{"TE", "trailers"},
+	},
+	HeaderOrder: []string{
+		"Host",
+		"User-Agent",
+		"Accept",
+		"Accept-Language",
+		"Accept-Encoding",
+		"Connection",
+		"Cookie",
+		"Upgrade-Insecure-Requests",
+		"Sec-Fetch-Dest",
+		"Sec-Fetch-Mode",
+		"Sec-Fetch-Site",
+		"Sec-Fetch-User",
+		"TE",
+	},
+	TLS: utls.HelloFirefox_120,
+}
+
+var SafariIOS14 = &Profile{
+	Name:           "safari-ios14",
+	UserAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 14_8 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.2 Mobile/15E148 Safari/604.1",
+	AcceptLanguage: "en-us",
+	Headers: []Header{
+		{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+		{"Accept-Encoding", "gzip, deflate, br"},
+	},
+	HeaderOrder: []string{
+		"Host",
+		"Accept",
+		"Cookie",
+		"User-Agent",
+		"Accept-Language",
+		"Accept-Encoding",
+		"Connection",
+	},
+	TLS: utls.HelloIOS_14,
+}
+
+var All = []*Profile{Chrome120, Firefox120, SafariIOS14}
@@ -0,0 +1,25 @@
+package profiles
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUserAgentMatchesClientHello(t *testing.T) {
+	tests := []struct {
+		profile *Profile
+		token   string
+	}{
+		{Chrome120, "Chrome/" + Chrome120.TLS.Version + "."},
+		{Firefox120, "Firefox/" + Firefox120.TLS.Version + "."},
+		{SafariIOS14, "iPhone OS " + SafariIOS14.TLS.Version + "_"},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(tt.profile.UserAgent, tt.token) {
+			t.Errorf("%s: user agent %q does not match the %s ClientHello", tt.profile.Name, tt.profile.UserAgent, tt.profile.TLS.Str())
+		}
+	}
+	if len(All) != len(tests) {
+		t.Fatalf("expected every profile to be covered, got %d of %d", len(tests), len(All))
+	}
+}
@@ -0,0 +1,979 @@
+package colly
+
+import (
+	"context"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+func (c *Collector) SetDomainProxy(glob string, proxyURLs ...string) error {
+	if len(proxyURLs) == 0 {
+		c.SetDomainProxyFunc(glob, nil)
+		return nil
+	}
+	s, err := NewProxySwitcher(ProxyRoundRobin, proxyURLs...)
+	if err != nil {
+		return err
+	}
+	c.SetDomainProxySwitcher(glob, s)
+	return nil
+}
+
+func (c *Collector) SetDomainProxySwitcher(glob string, s *ProxySwitcher) {
+	if c.offline {
+		s.setOffline(true)
+	}
+	c.setDomainProxy(&domainProxy{glob: strings.ToLower(glob), proxy: s.GetProxy, switcher: s})
+}
+
+func (c *Collector) SetDomainProxyFunc(glob string, p ProxyFunc) {
+	c.setDomainProxy(&domainProxy{glob: strings.ToLower(glob), proxy: p})
+}
+
+func (c *Collector) setDomainProxy(dp *domainProxy) {
+	if !c.proxyRouted && c.proxyFunc == nil {
+		if t := c.httpTransport(); t != nil && t.Proxy != nil {
+			c.proxyFunc = t.Proxy
+		}
+	}
+	c.lock.Lock()
+	replaced := false
+	for i, existing := range c.domainProxies {
+		if existing.glob == dp.glob {
+			c.domainProxies[i] = dp
+			replaced = true
+		}
+	}
+	if !replaced {
+		c.domainProxies = append(c.domainProxies, dp)
+	}
+	c.lock.Unlock()
+	if !c.proxyRouted {
+		c.installProxyFunc(false)
+	}
+}
+
+func (c *Collector) installProxyFunc(disableKeepAlives bool) {
+	c.proxyRouted = true
+	p := c.recordProxy(c.routeProxy)
+	if w, ok := c.baseTransport().(transportWrapper); ok {
+		if t := w.httpTransport(); t != nil {
+			t.Proxy = p
+			t.DisableKeepAlives = c.keepAlivesDisabled(t.DisableKeepAlives || disableKeepAlives)
+			return
+		}
+	}
+	t, ok := c.baseTransport().(*http.Transport)
+	if c.baseTransport() != nil && ok {
+		t.Proxy = p
+		t.DisableKeepAlives = c.keepAlivesDisabled(t.DisableKeepAlives || disableKeepAlives)
+	} else {
+		c.setBaseTransport(&http.Transport{
+			Proxy:             p,
+			DisableKeepAlives: c.keepAlivesDisabled(disableKeepAlives),
+		})
+	}
+}
+
+func (c *Collector) routeProxy(req *http.Request) (*url.URL, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	c.lock.RLock()
+	routes := c.domainProxies
+	c.lock.RUnlock()
+	for _, r := range routes {
+		if !matchHostGlob(r.glob, host) {
+			continue
+		}
+		if r.proxy == nil {
+			return nil, nil
+		}
+		return r.proxy(req)
+	}
+	if c.proxyFunc != nil {
+		return c.proxyFunc(req)
+	}
+	return nil, nil
+}
+
+func (c *Collector) reportProxy(proxyURL string, latency time.Duration, err error) {
+	if c.proxySwitcher != nil {
+		c.proxySwitcher.Report(proxyURL, latency, err)
+	}
+	c.lock.RLock()
+	routes := c.domainProxies
+	c.lock.RUnlock()
+	for _, r := range routes {
+		if r.switcher != nil && r.switcher != c.proxySwitcher {
+			r.switcher.Report(proxyURL, latency, err)
+		}
+	}
+}
+
+func (c *Collector) SetProxySwitcher(s *ProxySwitcher) {
+	if c.offline {
+		s.setOffline(true)
+	}
+	c.proxySwitcher = s
+	c.SetProxyFunc(s.GetProxy)
+}
+
+func (c *Collector) SetProxySessionFunc(f func(*Request) string) {
+	c.proxySession = f
+}
+
+func (c *Collector) SetProxyProvider(provider ProxyProvider, interval time.Duration) error {
+	s := c.proxySwitcher
+	if s == nil {
+		s = &ProxySwitcher{
+			strategy: ProxyRoundRobin,
+			sticky:   make(map[string]*proxyEntry),
+			sessions: make(map[string]*proxyEntry),
+			lock:     &sync.RWMutex{},
+		}
+	}
+	if c.offline {
+		s.setOffline(true)
+	}
+	if err := s.SetProvider(provider, interval); err != nil {
+		return err
+	}
+	if c.proxySwitcher != s {
+		c.SetProxySwitcher(s)
+	}
+	return nil
+}
+
+func (c *Collector) recordProxy(p ProxyFunc) ProxyFunc {
+	return func(req *http.Request) (*url.URL, error) {
+		usage, _ := req.Context().Value(proxyUsageContextKey).(*proxyUsage)
+		if usage != nil && usage.resolved {
+			return usage.proxy, nil
+		}
+		u, err := p(req)
+		if usage != nil && u != nil {
+			usage.url = u.String()
+		}
+		return u, err
+	}
+}
+
+func (c *Collector) resolveProxy(req *http.Request) (*url.URL, error) {
+	if !c.proxyRouted {
+		return nil, nil
+	}
+	t := c.httpTransport()
+	if t == nil || t.Proxy == nil {
+		return nil, nil
+	}
+	u, err := t.Proxy(req)
+	if err != nil {
+		return nil, err
+	}
+	if usage, ok := req.Context().Value(proxyUsageContextKey).(*proxyUsage); ok {
+		usage.resolved = true
+		usage.proxy = u
+	}
+	return u, nil
+}
+
+func (c *Collector) socksChain(next http.RoundTripper, proxyURL *url.URL) http.RoundTripper {
+	switch t := next.(type) {
+	case nil:
+		return c.socksTransport(http.DefaultTransport.(*http.Transport), proxyURL)
+	case *http.Transport:
+		return c.socksTransport(t, proxyURL)
+	case chainedTransport:
+		return t.withInner(c.socksChain(t.inner(), proxyURL))
+	}
+	return next
+}
+
+func (c *Collector) socksTransport(base *http.Transport, proxyURL *url.URL) *http.Transport {
+	key := fmt.Sprintf("%p|%s", base, proxyURL)
+	c.socksLock.Lock()
+	defer c.socksLock.Unlock()
+	if t, ok := c.socksTransports[key]; ok {
+		return t
+	}
+	t := base.Clone()
+	t.Proxy = nil
+	t.DialContext = socksDialContext(proxyURL, t.DialContext, c.guardedLookup)
+	t.DialTLSContext = nil
+	if c.socksTransports == nil {
+		c.socksTransports = make(map[string]*http.Transport)
+	}
+	c.socksTransports[key] = t
+	return t
+}
+
+type ProxyStrategy int
+
+const (
+	ProxyRoundRobin ProxyStrategy = iota
+	ProxyRandom
+	ProxyWeighted
+	ProxyLeastRecentlyBanned
+	ProxyStickyPerHost
+)
+
+type ProxySwitcher struct {
+	strategy ProxyStrategy
+	proxies  []*proxyEntry
+	sticky   map[string]*proxyEntry
+	sessions map[string]*proxyEntry
+	index    uint32
+	health   *ProxyHealthConfig
+	stop     chan struct{}
+	provider *proxyProvider
+	offline  bool
+	lock     *sync.RWMutex
+}
+
+type proxyEntry struct {
+	url                 *url.URL
+	weight              float64
+	bannedAt            time.Time
+	bans                int
+	requests            int
+	failures            int
+	consecutiveFailures int
+	latency             time.Duration
+	quarantinedAt       time.Time
+	retired             map[string]time.Time
+	provided            bool
+}
+
+type proxyUsage struct {
+	url      string
+	resolved bool
+	proxy    *url.URL
+}
+
+type ProxyHealthConfig struct {
+	MaxConsecutiveFailures int
+	MaxErrorRate           float64
+	MinRequests            int
+	ProbeURL               string
+	ProbeInterval          time.Duration
+	ProbeTimeout           time.Duration
+	QuarantinePeriod       time.Duration
+}
+
+type ProxyHealth struct {
+	URL                 string
+	Weight              float64
+	Requests            int
+	Failures            int
+	ConsecutiveFailures int
+	Latency             time.Duration
+	Bans                int
+	LastBanned          time.Time
+	Quarantined         bool
+	QuarantinedAt       time.Time
+}
+
+func (h *ProxyHealthConfig) Init() {
+	if h.MaxConsecutiveFailures == 0 {
+		h.MaxConsecutiveFailures = 3
+	}
+	if h.MinRequests == 0 {
+		h.MinRequests = 10
+	}
+	if h.ProbeInterval == 0 {
+		h.ProbeInterval = 30 * time.Second
+	}
+	if h.ProbeTimeout == 0 {
+		h.ProbeTimeout = 10 * time.Second
+	}
+	if h.QuarantinePeriod == 0 {
+		h.QuarantinePeriod = 5 * time.Minute
+	}
+}
+
+func NewProxySwitcher(strategy ProxyStrategy, proxyURLs ...string) (*ProxySwitcher, error) {
+	if len(proxyURLs) < 1 {
+		return nil, ErrEmptyProxyURL
+	}
+	s := &ProxySwitcher{
+		strategy: strategy,
+		sticky:   make(map[string]*proxyEntry),
+		sessions: make(map[string]*proxyEntry),
+		lock:     &sync.RWMutex{},
+	}
+	for _, u := range proxyURLs {
+		if err := s.AddProxy(u, 1); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *ProxySwitcher) AddProxy(proxyURL string, weight float64) error {
+	u, err := parseProxyURL(proxyURL)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, e := range s.proxies {
+		if e.url.String() == u.String() {
+			e.weight = weight
+			e.provided = false
+			return nil
+		}
+	}
+	s.proxies = append(s.proxies, &proxyEntry{url: u, weight: weight})
+	return nil
+}
+
+func (s *ProxySwitcher) SetWeight(proxyURL string, weight float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if e := s.entry(proxyURL); e != nil {
+		e.weight = weight
+	}
+}
+
+func (s *ProxySwitcher) Ban(proxyURL string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e := s.entry(proxyURL)
+	if e == nil {
+		return
+	}
+	e.bannedAt = time.Now()
+	e.bans++
+	for host, sticky := range s.sticky {
+		if sticky == e {
+			delete(s.sticky, host)
+		}
+	}
+}
+
+func (s *ProxySwitcher) EnableHealthChecks(config *ProxyHealthConfig) {
+	config.Init()
+	s.lock.Lock()
+	s.health = config
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	if config.ProbeURL != "" {
+		s.stop = make(chan struct{})
+		go s.probe(config, s.stop)
+	}
+	s.lock.Unlock()
+}
+
+func (s *ProxySwitcher) setOffline(offline bool) {
+	s.lock.Lock()
+	s.offline = offline
+	s.lock.Unlock()
+}
+
+func (s *ProxySwitcher) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	if s.provider != nil {
+		close(s.provider.stop)
+		s.provider = nil
+	}
+}
+
+func (s *ProxySwitcher) Report(proxyURL string, latency time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e := s.entry(proxyURL)
+	if e == nil {
+		return
+	}
+	e.requests++
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency = (e.latency*4 + latency) / 5
+	}
+	if err == nil {
+		e.consecutiveFailures = 0
+		return
+	}
+	if ClassifyError(err) == ErrorCanceled {
+		return
+	}
+	e.failures++
+	e.consecutiveFailures++
+	if s.health == nil || !e.quarantinedAt.IsZero() {
+		return
+	}
+	if e.consecutiveFailures >= s.health.MaxConsecutiveFailures ||
+		(s.health.MaxErrorRate > 0 && e.requests >= s.health.MinRequests && float64(e.failures)/float64(e.requests) > s.health.MaxErrorRate) {
+		s.quarantine(e)
+	}
+}
+
+func (s *ProxySwitcher) Quarantine(proxyURL string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if e := s.entry(proxyURL); e != nil {
+		s.quarantine(e)
+	}
+}
+
+func (s *ProxySwitcher) Readmit(proxyURL string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if e := s.entry(proxyURL); e != nil {
+		e.readmit()
+	}
+}
+
+func (s *ProxySwitcher) Health() []ProxyHealth {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	health := make([]ProxyHealth, len(s.proxies))
+	for i, e := range s.proxies {
+		health[i] = ProxyHealth{
+			URL:                 e.url.String(),
+			Weight:              e.weight,
+			Requests:            e.requests,
+			Failures:            e.failures,
+			ConsecutiveFailures: e.consecutiveFailures,
+			Latency:             e.latency,
+			Bans:                e.bans,
+			LastBanned:          e.bannedAt,
+			Quarantined:         !e.quarantinedAt.IsZero(),
+			QuarantinedAt:       e.quarantinedAt,
+		}
+	}
+	return health
+}
+
+func (s *ProxySwitcher) quarantine(e *proxyEntry) {
+	e.quarantinedAt = time.Now()
+	for host, sticky := range s.sticky {
+		if sticky == e {
+			delete(s.sticky, host)
+		}
+	}
+	for session, sticky := range s.sessions {
+		if sticky == e {
+			delete(s.sessions, session)
+		}
+	}
+}
+
+func (s *ProxySwitcher) readmitExpired() {
+	if s.health == nil || s.health.ProbeURL != "" {
+		return
+	}
+	for _, e := range s.proxies {
+		if !e.quarantinedAt.IsZero() && time.Since(e.quarantinedAt) >= s.health.QuarantinePeriod {
+			e.readmit()
+		}
+	}
+}
+
+func (e *proxyEntry) readmit() {
+	e.quarantinedAt = time.Time{}
+	e.consecutiveFailures = 0
+	e.requests = 0
+	e.failures = 0
+}
+
+func (s *ProxySwitcher) probe(config *ProxyHealthConfig, stop chan struct{}) {
+	ticker := time.NewTicker(config.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		s.lock.RLock()
+		if s.offline {
+			s.lock.RUnlock()
+			continue
+		}
+		var quarantined []*url.URL
+		for _, e := range s.proxies {
+			if !e.quarantinedAt.IsZero() {
+				quarantined = append(quarantined, e.url)
+			}
+		}
+		s.lock.RUnlock()
+		for _, u := range quarantined {
+			if probeProxy(u, config) {
+				s.Readmit(u.String())
+			}
+		}
+	}
+}
+
+func probeProxy(u *url.URL, config *ProxyHealthConfig) bool {
+	transport := &http.Transport{Proxy: http.ProxyURL(u), DisableKeepAlives: true}
+	if isSOCKSProxy(u) {
+		transport.Proxy = nil
+		transport.DialContext = socksDialContext(u, nil, nil)
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: config.ProbeTimeout}
+	res, err := client.Get(config.ProbeURL)
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return res.StatusCode < 500 && res.StatusCode != http.StatusProxyAuthRequired
+}
+
+func (s *ProxySwitcher) GetProxy(pr *http.Request) (*url.URL, error) {
+	session := proxySessionFor(pr)
+	s.lock.Lock()
+	s.readmitExpired()
+	var e *proxyEntry
+	if session != "" {
+		e = s.pickSession(session, strings.ToLower(pr.URL.Hostname()))
+	} else {
+		e = s.pick(strings.ToLower(pr.URL.Hostname()))
+	}
+	s.lock.Unlock()
+	if e == nil {
+		return nil, ErrNoHealthyProxy
+	}
+	ctx := context.WithValue(pr.Context(), ProxyURLKey, e.url.String())
+	*pr = *pr.WithContext(ctx)
+	return e.url, nil
+}
+
+func (s *ProxySwitcher) entry(proxyURL string) *proxyEntry {
+	for _, e := range s.proxies {
+		if e.url.String() == proxyURL {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *ProxySwitcher) pick(host string) *proxyEntry {
+	proxies := make([]*proxyEntry, 0, len(s.proxies))
+	for _, e := range s.proxies {
+		if e.available(host) {
+			proxies = append(proxies, e)
+		}
+	}
+	if len(proxies) == 0 {
+		return nil
+	}
+	switch s.strategy {
+	case ProxyRandom:
+		return proxies[mathrand.Intn(len(proxies))]
+	case ProxyWeighted:
+		total := 0.0
+		for _, e := range proxies {
+			total += e.weight
+		}
+		if total > 0 {
+			n := mathrand.Float64() * total
+			for _, e := range proxies {
+				if n < e.weight {
+					return e
+				}
+				n -= e.weight
+			}
+		}
+	case ProxyLeastRecentlyBanned:
+		var candidates []*proxyEntry
+		for _, e := range proxies {
+			if len(candidates) == 0 || e.bannedAt.Before(candidates[0].bannedAt) {
+				candidates = []*proxyEntry{e}
+			} else if e.bannedAt.Equal(candidates[0].bannedAt) {
+				candidates = append(candidates, e)
+			}
+		}
+		s.index++
+		return candidates[int(s.index-1)%len(candidates)]
+	case ProxyStickyPerHost:
+		if e, ok := s.sticky[host]; ok && e.available(host) {
+			return e
+		}
+		s.index++
+		e := proxies[int(s.index-1)%len(proxies)]
+		s.sticky[host] = e
+		return e
+	}
+	s.index++
+	return proxies[int(s.index-1)%len(proxies)]
+}
+
+type domainProxy struct {
+	glob     string
+	proxy    ProxyFunc
+	switcher *ProxySwitcher
+}
+
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "socks5", "socks5h":
+		return u, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedProxyScheme, u.Scheme)
+}
+
+func isSOCKSProxy(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	return scheme == "socks5" || scheme == "socks5h"
+}
+
+type dialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f dialContextFunc) Dial(network, addr string) (net.Conn, error) {
+	return f(context.Background(), network, addr)
+}
+
+func (f dialContextFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+func socksDialContext(proxyURL *url.URL, forward dialContextFunc, lookup func(context.Context, string) ([]net.IP, error)) dialContextFunc {
+	if forward == nil {
+		forward = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	if lookup == nil {
+		lookup = func(ctx context.Context, host string) ([]net.IP, error) {
+			addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			if len(addrs) == 0 {
+				return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+			}
+			ips := make([]net.IP, len(addrs))
+			for i, a := range addrs {
+				ips[i] = a.IP
+			}
+			return ips, nil
+		}
+	}
+	var auth *proxy.Auth
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+	}
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "1080")
+	}
+	remoteDNS := strings.ToLower(proxyURL.Scheme) == "socks5h"
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialer, err := proxy.SOCKS5("tcp", proxyAddr, auth, forward)
+		if err != nil {
+			return nil, err
+		}
+		target, _ := ctx.Value(ssrfTargetContextKey).(string)
+		if !remoteDNS || target != "" {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			ips, err := lookup(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			addr = net.JoinHostPort(ips[0].String(), port)
+		}
+		return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+	}
+}
+
+type Proxy struct {
+	URL    string
+	Weight float64
+}
+
+type ProxyProvider interface {
+	Fetch(ctx context.Context) ([]Proxy, error)
+}
+
+type ProxyProviderFunc func(ctx context.Context) ([]Proxy, error)
+
+func (f ProxyProviderFunc) Fetch(ctx context.Context) ([]Proxy, error) {
+	return f(ctx)
+}
+
+type proxyProvider struct {
+	provider ProxyProvider
+	err      error
+	updated  time.Time
+	stop     chan struct{}
+	cancel   context.CancelFunc
+}
+
+const defaultProxyProviderTimeout = 30 * time.Second
+
+func (s *ProxySwitcher) SetProvider(provider ProxyProvider, interval time.Duration) error {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &proxyProvider{
+		provider: provider,
+		stop:     make(chan struct{}),
+		cancel:   cancel,
+	}
+	fetchCtx, fetchCancel := context.WithTimeout(ctx, defaultProxyProviderTimeout)
+	err := s.refresh(fetchCtx, p)
+	fetchCancel()
+	if err != nil {
+		cancel()
+		return err
+	}
+	s.lock.Lock()
+	if s.provider != nil {
+		close(s.provider.stop)
+	}
+	s.provider = p
+	s.lock.Unlock()
+	go s.poll(ctx, p, interval)
+	return nil
+}
+
+func (s *ProxySwitcher) Refresh(ctx context.Context) error {
+	s.lock.RLock()
+	p := s.provider
+	s.lock.RUnlock()
+	if p == nil {
+		return nil
+	}
+	return s.refresh(ctx, p)
+}
+
+func (s *ProxySwitcher) ProviderError() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.provider == nil {
+		return nil
+	}
+	return s.provider.err
+}
+
+func (s *ProxySwitcher) ProviderUpdated() time.Time {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.provider == nil {
+		return time.Time{}
+	}
+	return s.provider.updated
+}
+
+func (s *ProxySwitcher) poll(ctx context.Context, p *proxyProvider, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer p.cancel()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			fetchCtx, fetchCancel := context.WithTimeout(ctx, defaultProxyProviderTimeout)
+			s.refresh(fetchCtx, p)
+			fetchCancel()
+		}
+	}
+}
+
+func (s *ProxySwitcher) refresh(ctx context.Context, p *proxyProvider) error {
+	s.lock.RLock()
+	offline := s.offline
+	s.lock.RUnlock()
+	if offline {
+		err := &NotCachedError{Method: "GET"}
+		s.lock.Lock()
+		p.err = err
+		s.lock.Unlock()
+		return err
+	}
+	proxies, err := p.provider.Fetch(ctx)
+	if err == nil && len(proxies) == 0 {
+		err = ErrEmptyProxyURL
+	}
+	entries := make([]*proxyEntry, 0, len(proxies))
+	if err == nil {
+		for _, proxy := range proxies {
+			u, perr := parseProxyURL(proxy.URL)
+			if perr != nil {
+				err = perr
+				break
+			}
+			weight := proxy.Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			entries = append(entries, &proxyEntry{url: u, weight: weight})
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p.err = err
+	if err != nil {
+		return err
+	}
+	var merged []*proxyEntry
+	for _, e := range s.proxies {
+		if !e.provided {
+			merged = append(merged, e)
+		}
+	}
+	for _, e := range entries {
+		if existing := s.entry(e.url.String()); existing != nil {
+			if !existing.provided {
+				continue
+			}
+			existing.weight = e.weight
+			e = existing
+		}
+		e.provided = true
+		merged = append(merged, e)
+	}
+	s.proxies = merged
+	for host, sticky := range s.sticky {
+		if s.entry(sticky.url.String()) != sticky {
+			delete(s.sticky, host)
+		}
+	}
+	for session, sticky := range s.sessions {
+		if s.entry(sticky.url.String()) != sticky {
+			delete(s.sessions, session)
+		}
+	}
+	p.updated = time.Now()
+	return nil
+}
+
+const ProxySessionCtxKey = "proxySession"
+
+func (r *Request) SetProxySession(session string) {
+	r.Ctx.Put(ProxySessionCtxKey, session)
+}
+
+func (r *Request) ProxySession() string {
+	if r.collector != nil && r.collector.proxySession != nil {
+		if session := r.collector.proxySession(r); session != "" {
+			return session
+		}
+	}
+	if r.Ctx == nil {
+		return ""
+	}
+	session, _ := r.Ctx.GetAny(ProxySessionCtxKey).(string)
+	return session
+}
+
+func proxySessionFor(req *http.Request) string {
+	if r, ok := req.Context().Value(requestContextKey).(*Request); ok {
+		return r.ProxySession()
+	}
+	return ""
+}
+
+func (s *ProxySwitcher) BindSession(session, proxyURL string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e := s.entry(proxyURL)
+	if e == nil {
+		return fmt.Errorf("%w: %s", ErrNoHealthyProxy, proxyURL)
+	}
+	s.sessions[session] = e
+	return nil
+}
+
+func (s *ProxySwitcher) EndSession(session string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.sessions, session)
+}
+
+func (s *ProxySwitcher) SessionProxy(session string) string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if e, ok := s.sessions[session]; ok {
+		return e.url.String()
+	}
+	return ""
+}
+
+func (s *ProxySwitcher) pickSession(session, host string) *proxyEntry {
+	if e, ok := s.sessions[session]; ok && e.available(host) {
+		return e
+	}
+	e := s.pick(host)
+	if e != nil {
+		s.sessions[session] = e
+	}
+	return e
+}
+
+func (c *Collector) retireProxy(proxyURL, host string, d time.Duration) {
+	if c.proxySwitcher != nil {
+		c.proxySwitcher.Retire(proxyURL, host, d)
+	}
+	c.lock.RLock()
+	routes := c.domainProxies
+	c.lock.RUnlock()
+	for _, r := range routes {
+		if r.switcher != nil && r.switcher != c.proxySwitcher {
+			r.switcher.Retire(proxyURL, host, d)
+		}
+	}
+}
+
+func (s *ProxySwitcher) Retire(proxyURL, host string, d time.Duration) {
+	host = strings.ToLower(host)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	e := s.entry(proxyURL)
+	if e == nil {
+		return
+	}
+	if e.retired == nil {
+		e.retired = make(map[string]time.Time)
+	}
+	e.retired[host] = time.Now().Add(d)
+	if s.sticky[host] == e {
+		delete(s.sticky, host)
+	}
+}
+
+func (e *proxyEntry) available(host string) bool {
+	if !e.quarantinedAt.IsZero() {
+		return false
+	}
+	until, ok := e.retired[host]
+	if !ok {
+		return true
+	}
+	if time.Now().Before(until) {
+		return false
+	}
+	delete(e.retired, host)
+	return true
+}
@@ -0,0 +1,95 @@
+package colly
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProxySwitcherReportCountsFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		counted bool
+	}{
+		{"timeout", &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}, true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"tls", x509.UnknownAuthorityError{}, true},
+		{"dns", &net.DNSError{Err: "no such host", Name: "proxy.invalid"}, true},
+		{"connection", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"canceled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewProxySwitcher(ProxyRoundRobin, "http://127.0.0.1:1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.EnableHealthChecks(&ProxyHealthConfig{MaxConsecutiveFailures: 2})
+			s.Report("http://127.0.0.1:1", time.Millisecond, tt.err)
+			s.Report("http://127.0.0.1:1", time.Millisecond, tt.err)
+			h := s.Health()[0]
+			if counted := h.Failures == 2; counted != tt.counted {
+				t.Fatalf("expected counted=%v, got %d failures", tt.counted, h.Failures)
+			}
+			if h.Quarantined != tt.counted {
+				t.Fatalf("expected quarantined=%v", tt.counted)
+			}
+		})
+	}
+}
+
+func TestBlackholedProxyIsQuarantined(t *testing.T) {
+	blackhole, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blackhole.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			conn, err := blackhole.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer good.Close()
+
+	blackholeURL := "http://" + blackhole.Addr().String()
+	s, err := NewProxySwitcher(ProxyRoundRobin, blackholeURL, good.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.EnableHealthChecks(&ProxyHealthConfig{MaxConsecutiveFailures: 2})
+	c := NewCollector()
+	c.SetResponseHeaderTimeout(200 * time.Millisecond)
+	c.SetProxySwitcher(s)
+	for i := 0; i < 6; i++ {
+		c.Visit(fmt.Sprintf("http://example.com/%d", i))
+	}
+	for _, h := range s.Health() {
+		if h.URL == blackholeURL && !h.Quarantined {
+			t.Fatalf("expected the blackholed proxy to be quarantined, got %+v", h)
+		}
+		if h.URL == good.URL && h.Quarantined {
+			t.Fatalf("expected the working proxy to stay in rotation, got %+v", h)
+		}
+	}
+}
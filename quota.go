@@ -0,0 +1,130 @@
+package colly
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func (c *Collector) SetDiskQuota(maxBytes int64, policy DiskQuotaPolicy) {
+	c.diskQuota = &diskQuota{
+		max:    maxBytes,
+		policy: policy,
+		lock:   &sync.Mutex{},
+	}
+}
+
+func (c *Collector) DiskUsage() int64 {
+	if c.diskQuota == nil {
+		return 0
+	}
+	c.diskQuota.lock.Lock()
+	defer c.diskQuota.lock.Unlock()
+	return c.diskQuota.used
+}
+
+func (c *Collector) tempDir() string {
+	if c.TempDir != "" {
+		return c.TempDir
+	}
+	return os.TempDir()
+}
+
+type DiskQuotaPolicy int
+
+const (
+	DiskQuotaFail DiskQuotaPolicy = iota
+	DiskQuotaEvictCache
+)
+
+type diskQuota struct {
+	max     int64
+	used    int64
+	policy  DiskQuotaPolicy
+	scanned bool
+	lock    *sync.Mutex
+}
+
+type quotaWriter struct {
+	w         io.Writer
+	quota     *diskQuota
+	collector *Collector
+	reserved  *int64
+}
+
+func (w *quotaWriter) Write(p []byte) (int, error) {
+	if err := w.quota.reserve(w.collector.CacheDir, int64(len(p))); err != nil {
+		return 0, err
+	}
+	*w.reserved += int64(len(p))
+	return w.w.Write(p)
+}
+
+func (q *diskQuota) reserve(cacheDir string, n int64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.scan(cacheDir)
+	if q.used+n <= q.max {
+		q.used += n
+		return nil
+	}
+	if q.policy == DiskQuotaEvictCache && cacheDir != "" {
+		q.evict(cacheDir, q.used+n-q.max)
+		if q.used+n <= q.max {
+			q.used += n
+			return nil
+		}
+	}
+	return ErrDiskQuotaExceeded
+}
+
+func (q *diskQuota) release(n int64) {
+	q.lock.Lock()
+	q.used -= n
+	if q.used < 0 {
+		q.used = 0
+	}
+	q.lock.Unlock()
+}
+
+func (q *diskQuota) scan(cacheDir string) {
+	if q.scanned || cacheDir == "" {
+		return
+	}
+	q.scanned = true
+	filepath.Walk(cacheDir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			q.used += info.Size()
+		}
+		return nil
+	})
+}
+
+func (q *diskQuota) evict(cacheDir string, need int64) {
+	type entry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []entry
+	filepath.Walk(cacheDir, func(p string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !strings.HasSuffix(p, "~") {
+			entries = append(entries, entry{p, info.Size(), info.ModTime()})
+		}
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	for _, e := range entries {
+		if need <= 0 {
+			return
+		}
+		if os.Remove(e.path) == nil {
+			q.used -= e.size
+			need -= e.size
+		}
+	}
+}
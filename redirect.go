@@ -0,0 +1,162 @@
+package colly
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+func (r *Response) Redirects() []RedirectHop {
+	if r.redirects != nil {
+		return append([]RedirectHop(nil), r.redirects...)
+	}
+	if r.Request == nil || r.Request.collector == nil {
+		return nil
+	}
+	v, ok := r.Request.collector.requestStates.Load(r.Request)
+	if !ok {
+		return nil
+	}
+	return v.(*requestState).redirectHops()
+}
+
+type RedirectHop struct {
+	URL        *url.URL
+	StatusCode int
+	Headers    http.Header
+	proto      string
+}
+
+type Redirect struct {
+	From       *url.URL
+	To         *url.URL
+	StatusCode int
+	Headers    http.Header
+	Request    *Request
+	abort      bool
+}
+
+func (r *Redirect) Abort() {
+	r.abort = true
+}
+
+func (r *Redirect) Rewrite(destination string) error {
+	u, err := r.To.Parse(destination)
+	if err != nil {
+		return err
+	}
+	r.To = u
+	return nil
+}
+
+type RedirectAction int
+
+const (
+	RedirectFollow RedirectAction = iota
+	RedirectStop
+	RedirectFail
+)
+
+type RedirectPolicy struct {
+	Permanent       RedirectAction
+	Temporary       RedirectAction
+	Statuses        map[int]RedirectAction
+	AllowDowngrade  bool
+	RecordPermanent bool
+	remaps          map[string]string
+	lock            *sync.RWMutex
+}
+
+func (p *RedirectPolicy) Init() {
+	if p.remaps == nil {
+		p.remaps = make(map[string]string)
+	}
+	if p.lock == nil {
+		p.lock = &sync.RWMutex{}
+	}
+}
+
+func (c *Collector) SetRedirectPolicy(policy *RedirectPolicy) {
+	if policy != nil {
+		policy.Init()
+	}
+	c.redirectPolicy = policy
+}
+
+func isPermanentRedirect(status int) bool {
+	return status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+}
+
+func (p *RedirectPolicy) action(status int) RedirectAction {
+	if action, ok := p.Statuses[status]; ok {
+		return action
+	}
+	if isPermanentRedirect(status) {
+		return p.Permanent
+	}
+	return p.Temporary
+}
+
+func (p *RedirectPolicy) check(req *http.Request, via []*http.Request) error {
+	from := via[len(via)-1].URL
+	if !p.AllowDowngrade && from.Scheme == "https" && req.URL.Scheme == "http" {
+		return fmt.Errorf("%w: %s -> %s", ErrRedirectDowngrade, from, req.URL)
+	}
+	status := 0
+	if req.Response != nil {
+		status = req.Response.StatusCode
+	}
+	switch p.action(status) {
+	case RedirectStop:
+		return http.ErrUseLastResponse
+	case RedirectFail:
+		return fmt.Errorf("%w: %d %s -> %s", ErrRedirectRefused, status, from, req.URL)
+	}
+	if p.RecordPermanent && isPermanentRedirect(status) && req.Method == "GET" {
+		p.lock.Lock()
+		p.remaps[from.String()] = req.URL.String()
+		p.lock.Unlock()
+	}
+	return nil
+}
+
+func (p *RedirectPolicy) Remaps() map[string]string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	remaps := make(map[string]string, len(p.remaps))
+	for from, to := range p.remaps {
+		remaps[from] = to
+	}
+	return remaps
+}
+
+func (p *RedirectPolicy) AddRemap(from, to string) {
+	p.lock.Lock()
+	p.remaps[from] = to
+	p.lock.Unlock()
+}
+
+func (p *RedirectPolicy) remap(u *url.URL) *url.URL {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if len(p.remaps) == 0 {
+		return u
+	}
+	current := u.String()
+	for i := 0; i < 10; i++ {
+		next, ok := p.remaps[current]
+		if !ok || next == current {
+			break
+		}
+		current = next
+	}
+	if current == u.String() {
+		return u
+	}
+	remapped, err := url.Parse(current)
+	if err != nil {
+		return u
+	}
+	return remapped
+}
@@ -0,0 +1,37 @@
+package colly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseRedirectsOutliveRequestState(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		default:
+			w.Write([]byte("done"))
+		}
+	}))
+	defer ts.Close()
+
+	c := NewCollector()
+	var res *Response
+	c.OnResponse(func(r *Response) {
+		res = r
+	})
+	if err := c.Visit(ts.URL + "/a"); err != nil {
+		t.Fatal(err)
+	}
+	hops := res.Redirects()
+	if len(hops) != 2 || hops[0].StatusCode != http.StatusMovedPermanently || hops[1].StatusCode != http.StatusFound {
+		t.Fatalf("unexpected redirect hops %+v", hops)
+	}
+	if hops[0].URL.Path != "/a" || hops[1].URL.Path != "/b" {
+		t.Fatalf("unexpected redirect URLs %v %v", hops[0].URL, hops[1].URL)
+	}
+}
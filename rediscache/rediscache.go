@@ -0,0 +1,267 @@
+package rediscache
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+var (
+	ErrClosed         = errors.New("Cache is closed")
+	ErrInvalidReply   = errors.New("Invalid reply from Redis")
+	ErrMissingAddress = errors.New("Redis address is not set")
+)
+
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+type Cache struct {
+	Address   string
+	Username  string
+	Password  string
+	DB        int
+	Prefix    string
+	Expires   time.Duration
+	Timeout   time.Duration
+	MaxIdle   int
+	TLSConfig *tls.Config
+	idle      []*conn
+	closed    bool
+	lock      sync.Mutex
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (s *Cache) Init() error {
+	if s.Address == "" {
+		return ErrMissingAddress
+	}
+	if s.Prefix == "" {
+		s.Prefix = "colly:cache:"
+	}
+	if s.Timeout == 0 {
+		s.Timeout = 5 * time.Second
+	}
+	if s.MaxIdle == 0 {
+		s.MaxIdle = 8
+	}
+	_, err := s.do("PING")
+	return err
+}
+
+func (s *Cache) Get(key string) ([]byte, error) {
+	reply, err := s.do("GET", s.Prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, colly.ErrCacheMiss
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, ErrInvalidReply
+	}
+	return data, nil
+}
+
+func (s *Cache) Set(key string, value []byte) error {
+	args := []interface{}{"SET", s.Prefix + key, value}
+	if s.Expires > 0 {
+		args = append(args, "PX", strconv.FormatInt(s.Expires.Milliseconds(), 10))
+	}
+	_, err := s.do(args...)
+	return err
+}
+
+func (s *Cache) Delete(key string) error {
+	_, err := s.do("DEL", s.Prefix+key)
+	return err
+}
+
+func (s *Cache) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	for _, c := range s.idle {
+		c.Close()
+	}
+	s.idle = nil
+	return nil
+}
+
+func (s *Cache) do(args ...interface{}) (interface{}, error) {
+	c, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(s.Timeout, args...)
+	if err != nil {
+		if _, ok := err.(RedisError); !ok {
+			c.Close()
+			return nil, err
+		}
+	}
+	s.release(c)
+	return reply, err
+}
+
+func (s *Cache) conn() (*conn, error) {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.lock.Unlock()
+		return c, nil
+	}
+	s.lock.Unlock()
+	if s.Address == "" {
+		return nil, ErrMissingAddress
+	}
+	dialer := &net.Dialer{Timeout: s.Timeout}
+	var nc net.Conn
+	var err error
+	if s.TLSConfig != nil {
+		nc, err = tls.DialWithDialer(dialer, "tcp", s.Address, s.TLSConfig)
+	} else {
+		nc, err = dialer.Dial("tcp", s.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	if s.Password != "" {
+		args := []interface{}{"AUTH", s.Password}
+		if s.Username != "" {
+			args = []interface{}{"AUTH", s.Username, s.Password}
+		}
+		if _, err := c.do(s.Timeout, args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if s.DB != 0 {
+		if _, err := c.do(s.Timeout, "SELECT", strconv.Itoa(s.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (s *Cache) release(c *conn) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	maxIdle := s.MaxIdle
+	if maxIdle == 0 {
+		maxIdle = 8
+	}
+	if s.closed || len(s.idle) >= maxIdle {
+		c.Close()
+		return
+	}
+	s.idle = append(s.idle, c)
+}
+
+func (c *conn) do(timeout time.Duration, args ...interface{}) (interface{}, error) {
+	if timeout > 0 {
+		c.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := c.write(args); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *conn) write(args []interface{}) error {
+	if _, err := fmt.Fprintf(c.w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		}
+		if _, err := fmt.Fprintf(c.w, "$%d\r\n", len(b)); err != nil {
+			return err
+		}
+		if _, err := c.w.Write(b); err != nil {
+			return err
+		}
+		if _, err := c.w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+	return c.w.Flush()
+}
+
+func (c *conn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, ErrInvalidReply
+	}
+	value := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return value, nil
+	case '-':
+		return nil, RedisError(value)
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, ErrInvalidReply
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, ErrInvalidReply
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				if _, ok := err.(RedisError); !ok {
+					return nil, err
+				}
+			}
+		}
+		return items, nil
+	}
+	return nil, ErrInvalidReply
+}
@@ -0,0 +1,236 @@
+package rediscache
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+	data     map[string][]byte
+	commands [][]string
+	lock     sync.Mutex
+}
+
+func newFakeRedis(t *testing.T, password string, config *tls.Config) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config != nil {
+		ln = tls.NewListener(ln, config)
+	}
+	r := &fakeRedis{ln: ln, password: password, data: make(map[string][]byte)}
+	t.Cleanup(func() {
+		ln.Close()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	authed := r.password == ""
+	for {
+		args, err := readCommand(br)
+		if err != nil {
+			return
+		}
+		r.lock.Lock()
+		r.commands = append(r.commands, args)
+		var reply string
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			authed = args[len(args)-1] == r.password
+			reply = "+OK\r\n"
+			if !authed {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "PING":
+			reply = "+PONG\r\n"
+		case cmd == "SELECT":
+			reply = "+OK\r\n"
+		case cmd == "SET":
+			r.data[args[1]] = []byte(args[2])
+			reply = "+OK\r\n"
+		case cmd == "GET":
+			v, ok := r.data[args[1]]
+			reply = "$-1\r\n"
+			if ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			}
+		case cmd == "DEL":
+			_, ok := r.data[args[1]]
+			delete(r.data, args[1])
+			reply = ":0\r\n"
+			if ok {
+				reply = ":1\r\n"
+			}
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		r.lock.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, errors.New("expected an array")
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil || line[0] != '$' {
+			return nil, errors.New("expected a bulk string")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (r *fakeRedis) sent(name string) [][]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var out [][]string
+	for _, c := range r.commands {
+		if strings.EqualFold(c[0], name) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	r := newFakeRedis(t, "secret", nil)
+	s := &Cache{Address: r.ln.Addr().String(), Username: "colly", Password: "secret", DB: 2, Expires: time.Minute}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.Get("missing"); !errors.Is(err, colly.ErrCacheMiss) {
+		t.Fatalf("expected a cache miss, got %v", err)
+	}
+	if err := s.Set("key", []byte("value\r\nwith crlf")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.Get("key")
+	if err != nil || string(data) != "value\r\nwith crlf" {
+		t.Fatalf("unexpected value %q, %v", data, err)
+	}
+	if err := s.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("key"); !errors.Is(err, colly.ErrCacheMiss) {
+		t.Fatalf("expected a cache miss after delete, got %v", err)
+	}
+	if auth := r.sent("AUTH"); len(auth) == 0 || strings.Join(auth[0][1:], " ") != "colly secret" {
+		t.Fatalf("expected AUTH with username and password, got %v", auth)
+	}
+	if sel := r.sent("SELECT"); len(sel) == 0 || sel[0][1] != "2" {
+		t.Fatalf("expected SELECT 2, got %v", sel)
+	}
+	if set := r.sent("SET"); len(set) != 1 || set[0][1] != "colly:cache:key" || set[0][3] != "PX" || set[0][4] != "60000" {
+		t.Fatalf("unexpected SET %v", set)
+	}
+}
+
+func TestCacheErrors(t *testing.T) {
+	r := newFakeRedis(t, "secret", nil)
+	s := &Cache{Address: r.ln.Addr().String(), Password: "wrong"}
+	var redisErr RedisError
+	if err := s.Init(); !errors.As(err, &redisErr) {
+		t.Fatalf("expected a Redis error for a bad password, got %v", err)
+	}
+	if err := (&Cache{}).Init(); !errors.Is(err, ErrMissingAddress) {
+		t.Fatalf("expected ErrMissingAddress, got %v", err)
+	}
+	s = &Cache{Address: r.ln.Addr().String(), Password: "secret"}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if _, err := s.Get("key"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
+func TestCacheWithoutInit(t *testing.T) {
+	r := newFakeRedis(t, "", nil)
+	var s Cache
+	if _, err := s.Get("key"); !errors.Is(err, ErrMissingAddress) {
+		t.Fatalf("expected ErrMissingAddress, got %v", err)
+	}
+	s.Address = r.ln.Addr().String()
+	if err := s.Set("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.Get("key"); err != nil || string(data) != "value" {
+		t.Fatalf("unexpected value %q, %v", data, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCacheTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	ts.Close()
+	r := newFakeRedis(t, "", ts.TLS)
+	s := &Cache{Address: r.ln.Addr().String(), TLSConfig: &tls.Config{InsecureSkipVerify: true}}
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Set("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.Get("key"); err != nil || string(data) != "value" {
+		t.Fatalf("unexpected value %q, %v", data, err)
+	}
+	plain := &Cache{Address: r.ln.Addr().String(), Timeout: time.Second}
+	if err := plain.Init(); err == nil {
+		t.Fatal("expected a plaintext client to fail against a TLS server")
+	}
+}
@@ -0,0 +1,197 @@
+package colly
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+
+	"strings"
+)
+
+type Renderer interface {
+	Render(ctx context.Context, r *Request) (*Response, error)
+}
+
+type RendererFunc func(ctx context.Context, r *Request) (*Response, error)
+
+func (f RendererFunc) Render(ctx context.Context, r *Request) (*Response, error) {
+	return f(ctx, r)
+}
+
+type renderRoute struct {
+	renderer Renderer
+	domains  []string
+	filters  []*regexp.Regexp
+}
+
+func (c *Collector) SetRenderer(renderer Renderer, domains ...string) {
+	lowered := make([]string, len(domains))
+	for i, d := range domains {
+		lowered[i] = strings.ToLower(d)
+	}
+	c.addRenderRoute(&renderRoute{renderer: renderer, domains: lowered})
+}
+
+func (c *Collector) RenderURLs(renderer Renderer, filters ...*regexp.Regexp) {
+	c.addRenderRoute(&renderRoute{renderer: renderer, filters: filters})
+}
+
+func (c *Collector) addRenderRoute(route *renderRoute) {
+	c.lock.Lock()
+	c.renderRoutes = append(c.renderRoutes, route)
+	c.lock.Unlock()
+}
+
+func (c *Collector) rendererFor(r *Request) Renderer {
+	c.lock.RLock()
+	routes := c.renderRoutes
+	c.lock.RUnlock()
+	if len(routes) == 0 {
+		return nil
+	}
+	host := strings.ToLower(r.URL.Hostname())
+	u := r.URL.String()
+	for _, route := range routes {
+		if len(route.filters) > 0 {
+			for _, f := range route.filters {
+				if f.MatchString(u) {
+					return route.renderer
+				}
+			}
+			continue
+		}
+		if hostMatches(route.domains, host) {
+			return route.renderer
+		}
+	}
+	return nil
+}
+
+func (c *Collector) render(renderer Renderer, req *http.Request, request *Request, checkHeadersFunc checkHeadersFunc) (*Response, error) {
+	if c.offline {
+		return nil, &NotCachedError{URL: req.URL.String(), Method: req.Method}
+	}
+	if c.ssrf != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRenderFailed, ErrUnguardedTransport)
+	}
+	ctx := req.Context()
+	if t := c.httpTransport(); t != nil && t.Proxy != nil {
+		proxyURL, err := t.Proxy(req)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRenderFailed, err)
+		}
+		if proxyURL != nil {
+			request.ProxyURL = proxyURL.String()
+			ctx = context.WithValue(ctx, ProxyURLKey, proxyURL.String())
+		}
+	}
+	jar := c.backend.Client.Jar
+	if jar != nil {
+		ctx = context.WithValue(ctx, RenderCookiesKey, jar.Cookies(req.URL))
+	}
+	var response *Response
+	var shot *Screenshot
+	var err error
+	if sr, ok := renderer.(ScreenshotRenderer); ok && c.screenshots != nil {
+		response, shot, err = sr.RenderScreenshot(ctx, request, c.screenshots)
+	} else {
+		response, err = renderer.Render(ctx, request)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRenderFailed, err)
+	}
+	if response == nil {
+		return nil, ErrRenderFailed
+	}
+	if response.StatusCode == 0 {
+		response.StatusCode = http.StatusOK
+	}
+	if response.Headers == nil {
+		response.Headers = &http.Header{}
+	}
+	if response.Headers.Get("Content-Type") == "" {
+		response.Headers.Set("Content-Type", "text/html; charset=utf-8")
+	}
+	final := req
+	if response.Request != nil && response.Request.URL != nil && response.Request.URL.String() != req.URL.String() {
+		final = req.Clone(req.Context())
+		final.URL = response.Request.URL
+		if err := c.checkFilters(final.URL.String(), final.URL.Hostname()); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRenderFailed, err)
+		}
+	}
+	if jar != nil {
+		jar.SetCookies(final.URL, (&http.Response{Header: *response.Headers}).Cookies())
+	}
+	if !checkHeadersFunc(final, response.StatusCode, *response.Headers) {
+		return nil, ErrAbortedAfterHeaders
+	}
+	if size := c.bodySizeFor(response.Headers.Get("Content-Type")); size > 0 && len(response.Body) > size {
+		response.Body = response.Body[:size]
+	}
+	response.Request = request
+	response.Ctx = request.Ctx
+	if shot != nil {
+		response.screenshot = shot
+		c.handleOnScreenshot(response, shot)
+	}
+	return response, nil
+}
+
+type ScreenshotFormat string
+
+const (
+	ScreenshotPNG  ScreenshotFormat = "png"
+	ScreenshotJPEG ScreenshotFormat = "jpeg"
+)
+
+type ScreenshotOptions struct {
+	Format   ScreenshotFormat
+	Quality  int
+	FullPage bool
+}
+
+type Screenshot struct {
+	Format ScreenshotFormat
+	Data   []byte
+}
+
+type ScreenshotRenderer interface {
+	Renderer
+	RenderScreenshot(ctx context.Context, r *Request, options *ScreenshotOptions) (*Response, *Screenshot, error)
+}
+
+func (c *Collector) CaptureScreenshots(options *ScreenshotOptions) {
+	if options != nil {
+		if options.Format == "" {
+			options.Format = ScreenshotPNG
+		}
+		if options.Quality == 0 {
+			options.Quality = 90
+		}
+	}
+	c.screenshots = options
+}
+
+func (c *Collector) handleOnScreenshot(r *Response, s *Screenshot) {
+	for _, f := range c.screenshotCallbacks {
+		f(r, s)
+	}
+}
+
+func (r *Response) Screenshot() *Screenshot {
+	return r.screenshot
+}
+
+func (s *Screenshot) Extension() string {
+	if s.Format == ScreenshotJPEG {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+func (s *Screenshot) Save(filename string) error {
+	return os.WriteFile(filename, s.Data, 0644)
+}
@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"hash/fnv"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -21,31 +22,53 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/antchfx/htmlquery"
 	"github.com/antchfx/xmlquery"
 	"github.com/gocolly/colly/v2/debug"
+	"github.com/gocolly/colly/v2/profiles"
 	"github.com/gocolly/colly/v2/storage"
 	"github.com/kennygrant/sanitize"
 	whatwgUrl "github.com/nlnwa/whatwg-url/url"
+	utls "github.com/refraction-networking/utls"
 	"github.com/temoto/robotstxt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/oauth2"
 	"google.golang.org/appengine/urlfetch"
 )
 
 type CollectorOption func(*Collector)
 
+type HashAlgorithm int
+
+const (
+	HashFNV64a HashAlgorithm = iota
+	HashXXHash
+	HashSHA1
+	HashSHA256
+)
+
 type Collector struct {
 	UserAgent                string
+	UserAgents               *UserAgentRotator
 	Headers                  *http.Header
 	MaxDepth                 int
 	AllowedDomains           []string
 	DisallowedDomains        []string
+	AllowedRegisteredDomains []string
+	AllowedSchemes           []string
+	HostAliases              map[string]string
+	FoldWWW                  bool
 	DisallowedURLFilters     []*regexp.Regexp
 	URLFilters               []*regexp.Regexp
 	AllowURLRevisit          bool
 	MaxBodySize              int
+	MaxBodySizes             map[string]int
 	CacheDir                 string
+	TempDir                  string
 	IgnoreRobotsTxt          bool
 	Async                    bool
 	ParseHTTPErrorResponse   bool
@@ -56,6 +79,15 @@ type Collector struct {
 	TraceHTTP                bool
 	Context                  context.Context
 	MaxRequests              uint32
+	MaxDuration              time.Duration
+	MaxRequestsPerDomain     uint32
+	MaxURLLength             int
+	MaxQueryParams           int
+	MaxPathSegments          int
+	MaxRedirects             int
+	AllowedLanguages         []string
+	AllowedContentTypes      []string
+	DisallowedContentTypes   []string
 	store                    storage.Storage
 	debugger                 debug.Debugger
 	robotsMap                map[string]*robotstxt.RobotsData
@@ -67,269 +99,1155 @@ type Collector struct {
 	errorCallbacks           []ErrorCallback
 	scrapedCallbacks         []ScrapedCallback
 	requestCount             uint32
+	crawlStart               int64
+	domainBudgetFunc         func(domain string) uint32
+	domainDepths             []domainDepth
+	queryRules               []*queryRoute
+	canonicalizers           []CanonicalizeFunc
+	allowedDomains           atomic.Value
+	disallowedDomains        atomic.Value
+	registeredDomains        atomic.Value
+	ssrf                     *ssrfGuard
+	dialTransport            *http.Transport
+	orderedConns             *orderedPool
+	dnsCache                 *DNSCache
+	resolver                 Resolver
+	ipFamily                 IPFamily
+	fallbackDelay            time.Duration
+	dialer                   dialContextFunc
+	unixSockets              []unixSocketRoute
+	keepAlives               *bool
+	failOnRedirectLimit      bool
+	redirectPolicy           *RedirectPolicy
 	responseCount            uint32
+	trapRule                 *TrapRule
+	trapCallbacks            []TrapCallback
+	banDetector              *BanDetector
+	banCallbacks             []BanCallback
+	redirectCallbacks        []RedirectCallback
+	blockPolicy              *BlockPolicy
+	captchaSolver            CaptchaSolver
+	renderRoutes             []*renderRoute
+	screenshots              *ScreenshotOptions
+	screenshotCallbacks      []ScreenshotCallback
+	interstitials            []*Interstitial
+	interactor               Interactor
+	retryPolicy              *RetryPolicy
+	retryCallbacks           []RetryCallback
+	hedging                  *HedgePolicy
+	autoThrottle             *AutoThrottleConfig
+	bandwidth                *bandwidthLimiter
+	concurrency              chan struct{}
+	workerPool               *workerPool
+	coolDowns                *sync.Map
+	urlClusterer             *URLClusterer
+	differ                   *crawlDiffer
+	xmlNamespaces            map[string]string
+	seeds                    *seedQueue
+	correlation              *CorrelationConfig
+	tidyHTML                 bool
+	tidyDomains              []string
+	bodyPredicate            HeaderPredicate
+	hashAlgorithm            HashAlgorithm
+	hashSalt                 string
+	streamCallbacks          []ResponseStreamCallback
+	streamPredicate          HeaderPredicate
+	requestStates            *sync.Map
+	spillThreshold           int64
+	diskQuota                *diskQuota
+	cacheHMACKey             []byte
+	cacheRevalidate          bool
+	cacheMaxAge              time.Duration
+	cacheDomainMaxAges       []domainMaxAge
+	cacheHonorHeaders        bool
+	cacheIndex               *cacheIndex
+	cacheStorage             CacheStorage
+	cacheCompress            bool
+	offline                  bool
+	cassette                 *Cassette
+	har                      *HARRecorder
+	audit                    *SiteAudit
+	changedCallbacks         []changedCallbackContainer
+	contentHashes            *contentHashes
+	disableCompression       bool
+	compressRequests         bool
+	compressMinSize          int
+	identification           *Identification
+	headerOrder              []string
+	domainHeaders            []*domainHeaders
+	signers                  []*domainSigner
+	authenticators           []*domainAuthenticator
+	seedHost                 string
+	digest                   *DigestAuthenticator
+	clientCerts              []*clientCertificate
+	certificatePins          *pinSet
+	optionErr                error
+	proxySwitcher            *ProxySwitcher
+	proxySession             func(*Request) string
+	proxyFunc                ProxyFunc
+	domainProxies            []*domainProxy
+	proxyRouted              bool
+	socksTransports          map[string]*http.Transport
+	socksLock                *sync.Mutex
+	profiles                 []*profiles.Profile
+	profileCount             uint32
 	backend                  *httpBackend
 	wg                       *sync.WaitGroup
 	lock                     *sync.RWMutex
 }
 
-type RequestCallback func(*Request)
+type RequestCallback func(*Request)
+
+type ResponseHeadersCallback func(*Response)
+
+type ResponseCallback func(*Response)
+
+type HTMLCallback func(*HTMLElement)
+
+type XMLCallback func(*XMLElement)
+
+type ErrorCallback func(*Response, error)
+
+type ScrapedCallback func(*Response)
+
+type ProxyFunc func(*http.Request) (*url.URL, error)
+
+type HeaderPredicate func(statusCode int, headers http.Header) bool
+
+type ResponseStreamCallback func(*StreamedResponse) error
+
+type TrapCallback func(*Trap)
+
+type BanCallback func(*Ban)
+
+type ScreenshotCallback func(*Response, *Screenshot)
+
+type RetryCallback func(*RetryAttempt)
+
+type RedirectCallback func(*Redirect)
+
+type ChangedCallback func(old, new *Response, diff Diff)
+
+type AlreadyVisitedError struct {
+	Destination *url.URL
+}
+
+func (e *AlreadyVisitedError) Error() string {
+	return fmt.Sprintf("%q already visited", e.Destination)
+}
+
+type htmlCallbackContainer struct {
+	Selector string
+	Function HTMLCallback
+	sem      chan struct{}
+}
+
+type xmlCallbackContainer struct {
+	Query    string
+	Function XMLCallback
+	sem      chan struct{}
+}
+
+func (cc *htmlCallbackContainer) call(e *HTMLElement) {
+	if cc.sem != nil {
+		cc.sem <- struct{}{}
+		defer func() { <-cc.sem }()
+	}
+	cc.Function(e)
+}
+
+func (cc *xmlCallbackContainer) call(e *XMLElement) {
+	if cc.sem != nil {
+		cc.sem <- struct{}{}
+		defer func() { <-cc.sem }()
+	}
+	cc.Function(e)
+}
+
+type cookieJarSerializer struct {
+	store storage.Storage
+	lock  *sync.RWMutex
+}
+
+var collectorCounter uint32
+
+type key int
+
+const (
+	ProxyURLKey key = iota
+	requestContextKey
+	profileContextKey
+	tlsHostContextKey
+	proxyUsageContextKey
+	ssrfTargetContextKey
+	cacheModeContextKey
+	RenderCookiesKey
+)
+
+const HeaderOrderKey = "Header-Order:"
+
+var (
+	ErrForbiddenDomain        = errors.New("Forbidden domain")
+	ErrMissingURL             = errors.New("Missing URL")
+	ErrMaxDepth               = errors.New("Max depth limit reached")
+	ErrForbiddenURL           = errors.New("ForbiddenURL")
+	ErrNoURLFiltersMatch      = errors.New("No URLFilters match")
+	ErrRobotsTxtBlocked       = errors.New("URL blocked by robots.txt")
+	ErrNoCookieJar            = errors.New("Cookie jar is not available")
+	ErrNoPattern              = errors.New("No pattern defined in LimitRule")
+	ErrEmptyProxyURL          = errors.New("Proxy URL list is empty")
+	ErrAbortedAfterHeaders    = errors.New("Aborted after receiving response headers")
+	ErrQueueFull              = errors.New("Queue MaxSize reached")
+	ErrMaxRequests            = errors.New("Max Requests limit reached")
+	ErrMaxDuration            = errors.New("Max Duration limit reached")
+	ErrMaxRequestsPerDomain   = errors.New("Max Requests per domain limit reached")
+	ErrURLTooLong             = errors.New("URL length limit exceeded")
+	ErrForbiddenScheme        = errors.New("Forbidden URL scheme")
+	ErrForbiddenAddress       = errors.New("Forbidden target address")
+	ErrTooManyRedirects       = errors.New("Redirect limit exceeded")
+	ErrRedirectDowngrade      = errors.New("Refusing to follow redirect from https to http")
+	ErrRedirectRefused        = errors.New("Redirect refused by policy")
+	ErrTooManyQueryParams     = errors.New("Query parameter limit exceeded")
+	ErrTooManyPathSegments    = errors.New("Path segment limit exceeded")
+	ErrRetryBodyUnseekable    = errors.New("Retry Body Unseekable")
+	ErrTrapDetected           = errors.New("Crawl trap detected")
+	ErrInvalidSeed            = errors.New("Invalid seed request")
+	ErrHandoffClosed          = errors.New("Handoff is closed")
+	ErrDiskQuotaExceeded      = errors.New("Disk quota exceeded")
+	ErrCacheMiss              = errors.New("Cache miss")
+	ErrNotCached              = errors.New("Response is not cached")
+	ErrUnexpectedRequest      = errors.New("Request not found in cassette")
+	ErrNotInArchive           = errors.New("URL not found in archive")
+	ErrInvalidWARC            = errors.New("Invalid WARC record")
+	ErrInvalidCurl            = errors.New("Invalid curl command")
+	ErrCertificatePin         = errors.New("Certificate does not match any pinned key")
+	ErrInvalidCABundle        = errors.New("No certificates found in CA bundle")
+	ErrUnsupportedProxyScheme = errors.New("Unsupported proxy scheme")
+	ErrBlocked                = errors.New("Blocked by target site")
+	ErrRenderFailed           = errors.New("Rendering failed")
+	ErrNoHealthyProxy         = errors.New("All proxies are quarantined")
+	ErrUnguardedTransport     = errors.New("Transport cannot enforce private network blocking")
+	ErrHeaderOrderUnsupported = errors.New("Header ordering is not supported with this transport")
+	ErrFingerprintUnsupported = errors.New("TLS fingerprinting is not supported by the configured transport")
+	ErrH2CUnsupported         = errors.New("h2c is not supported by the configured transport")
+	ErrInvalidHeader          = errors.New("Invalid header")
+)
+
+var envMap = map[string]func(*Collector, string){
+	"ALLOWED_DOMAINS": func(c *Collector, val string) {
+		c.AllowedDomains = strings.Split(val, ",")
+	},
+	"ALLOWED_REGISTERED_DOMAINS": func(c *Collector, val string) {
+		c.AllowedRegisteredDomains = strings.Split(val, ",")
+	},
+	"ALLOWED_SCHEMES": func(c *Collector, val string) {
+		c.AllowedSchemes = strings.Split(val, ",")
+	},
+	"ALLOWED_CONTENT_TYPES": func(c *Collector, val string) {
+		c.AllowedContentTypes = strings.Split(val, ",")
+	},
+	"ALLOWED_LANGUAGES": func(c *Collector, val string) {
+		c.AllowedLanguages = strings.Split(val, ",")
+	},
+	"AUTOTHROTTLE": func(c *Collector, val string) {
+		if isYesString(val) {
+			c.EnableAutoThrottle(nil)
+		}
+	},
+	"BANDWIDTH_LIMIT": func(c *Collector, val string) {
+		bytesPerSecond, err := strconv.ParseInt(val, 0, 64)
+		if err == nil {
+			c.SetBandwidthLimit(bytesPerSecond)
+		}
+	},
+	"CACHE_DIR": func(c *Collector, val string) {
+		c.CacheDir = val
+	},
+	"CACHE_MAX_AGE": func(c *Collector, val string) {
+		maxAge, err := time.ParseDuration(val)
+		if err == nil {
+			c.SetCacheMaxAge(maxAge)
+		}
+	},
+	"CACHE_COMPRESS": func(c *Collector, val string) {
+		c.cacheCompress = isYesString(val)
+	},
+	"OFFLINE": func(c *Collector, val string) {
+		c.SetOffline(isYesString(val))
+	},
+	"SKIP_DUPLICATE_CONTENT": func(c *Collector, val string) {
+		if isYesString(val) {
+			c.SetSkipDuplicateContent(false)
+		}
+	},
+	"CACHE_MAX_SIZE": func(c *Collector, val string) {
+		maxBytes, err := strconv.ParseInt(val, 0, 64)
+		if err == nil {
+			c.SetCacheMaxSize(maxBytes)
+		}
+	},
+	"DETECT_CHARSET": func(c *Collector, val string) {
+		c.DetectCharset = isYesString(val)
+	},
+	"COMPRESS_REQUEST_BODIES": func(c *Collector, val string) {
+		c.compressRequests = isYesString(val)
+	},
+	"CRAWL_CONTACT": func(c *Collector, val string) {
+		c.identify().Contact = val
+	},
+	"CRAWL_FROM": func(c *Collector, val string) {
+		c.identify().From = val
+	},
+	"CRAWL_INFO_URL": func(c *Collector, val string) {
+		c.identify().InfoURL = val
+	},
+	"DISABLE_COMPRESSION": func(c *Collector, val string) {
+		c.disableCompression = isYesString(val)
+	},
+	"DISABLE_COOKIES": func(c *Collector, _ string) {
+		c.backend.Client.Jar = nil
+	},
+	"DISALLOWED_CONTENT_TYPES": func(c *Collector, val string) {
+		c.DisallowedContentTypes = strings.Split(val, ",")
+	},
+	"DISALLOWED_DOMAINS": func(c *Collector, val string) {
+		c.DisallowedDomains = strings.Split(val, ",")
+	},
+	"IGNORE_ROBOTSTXT": func(c *Collector, val string) {
+		c.IgnoreRobotsTxt = isYesString(val)
+	},
+	"FORCE_HTTP1": func(c *Collector, val string) {
+		if isYesString(val) {
+			c.ForceHTTP1()
+		}
+	},
+	"FOLD_WWW": func(c *Collector, val string) {
+		c.FoldWWW = isYesString(val)
+	},
+	"FOLLOW_REDIRECTS": func(c *Collector, val string) {
+		if !isYesString(val) {
+			c.redirectHandler = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+		}
+	},
+	"HASH_SALT": func(c *Collector, val string) {
+		c.hashSalt = val
+	},
+	"MAX_BODY_SIZE": func(c *Collector, val string) {
+		size, err := strconv.Atoi(val)
+		if err == nil {
+			c.MaxBodySize = size
+		}
+	},
+	"MAX_CONCURRENCY": func(c *Collector, val string) {
+		maxConcurrency, err := strconv.Atoi(val)
+		if err == nil {
+			c.SetMaxConcurrency(maxConcurrency)
+		}
+	},
+	"MAX_DEPTH": func(c *Collector, val string) {
+		maxDepth, err := strconv.Atoi(val)
+		if err == nil {
+			c.MaxDepth = maxDepth
+		}
+	},
+	"MAX_DURATION": func(c *Collector, val string) {
+		maxDuration, err := time.ParseDuration(val)
+		if err == nil {
+			c.MaxDuration = maxDuration
+		}
+	},
+	"MAX_URL_LENGTH": func(c *Collector, val string) {
+		maxLength, err := strconv.Atoi(val)
+		if err == nil {
+			c.MaxURLLength = maxLength
+		}
+	},
+	"MAX_REDIRECTS": func(c *Collector, val string) {
+		maxRedirects, err := strconv.Atoi(val)
+		if err == nil {
+			c.MaxRedirects = maxRedirects
+		}
+	},
+	"MAX_REQUESTS": func(c *Collector, val string) {
+		maxRequests, err := strconv.ParseUint(val, 0, 32)
+		if err == nil {
+			c.MaxRequests = uint32(maxRequests)
+		}
+	},
+	"MAX_REQUESTS_PER_DOMAIN": func(c *Collector, val string) {
+		maxRequests, err := strconv.ParseUint(val, 0, 32)
+		if err == nil {
+			c.MaxRequestsPerDomain = uint32(maxRequests)
+		}
+	},
+	"MAX_RETRIES": func(c *Collector, val string) {
+		maxRetries, err := strconv.Atoi(val)
+		if err == nil {
+			c.SetRetryPolicy(&RetryPolicy{MaxAttempts: maxRetries + 1})
+		}
+	},
+	"PARSE_HTTP_ERROR_RESPONSE": func(c *Collector, val string) {
+		c.ParseHTTPErrorResponse = isYesString(val)
+	},
+	"TEMP_DIR": func(c *Collector, val string) {
+		c.TempDir = val
+	},
+	"TRACE_HTTP": func(c *Collector, val string) {
+		c.TraceHTTP = isYesString(val)
+	},
+	"USER_AGENT": func(c *Collector, val string) {
+		c.UserAgent = val
+	},
+}
+
+var urlParser = whatwgUrl.NewParser(whatwgUrl.WithPercentEncodeSinglePercentSign())
+
+func NewCollector(options ...CollectorOption) *Collector {
+	c := &Collector{}
+	c.Init()
+
+	for _, f := range options {
+		f(c)
+	}
+
+	c.parseSettingsFromEnv()
+
+	return c
+}
+
+func UserAgent(ua string) CollectorOption {
+	return func(c *Collector) {
+		c.UserAgent = ua
+	}
+}
+
+func UserAgents(rotator *UserAgentRotator) CollectorOption {
+	return func(c *Collector) {
+		c.SetUserAgentRotator(rotator)
+	}
+}
+
+func Headers(headers map[string]string) CollectorOption {
+	return func(c *Collector) {
+		customHeaders := make(http.Header)
+		for header, value := range headers {
+			customHeaders.Add(header, value)
+		}
+		c.Headers = &customHeaders
+	}
+}
+
+func DomainHeaders(glob string, headers map[string]string) CollectorOption {
+	return func(c *Collector) {
+		hdr := http.Header{}
+		for k, v := range headers {
+			hdr.Add(k, v)
+		}
+		c.SetDomainHeaders(glob, hdr)
+	}
+}
+
+func MaxDepth(depth int) CollectorOption {
+	return func(c *Collector) {
+		c.MaxDepth = depth
+	}
+}
+
+func MaxRequests(max uint32) CollectorOption {
+	return func(c *Collector) {
+		c.MaxRequests = max
+	}
+}
+
+func DomainMaxDepth(glob string, depth int) CollectorOption {
+	return func(c *Collector) {
+		c.SetDomainMaxDepth(glob, depth)
+	}
+}
+
+func Canonicalize(steps ...CanonicalizeFunc) CollectorOption {
+	return func(c *Collector) {
+		c.SetCanonicalization(steps...)
+	}
+}
+
+func NormalizeQuery(glob string, rule *QueryRule) CollectorOption {
+	return func(c *Collector) {
+		c.SetQueryRule(glob, rule)
+	}
+}
+
+func MaxRequestsPerDomain(max uint32) CollectorOption {
+	return func(c *Collector) {
+		c.MaxRequestsPerDomain = max
+	}
+}
+
+func MaxRequestsPerDomainFunc(f func(domain string) uint32) CollectorOption {
+	return func(c *Collector) {
+		c.SetDomainRequestBudget(f)
+	}
+}
+
+func MaxURLLength(max int) CollectorOption {
+	return func(c *Collector) {
+		c.MaxURLLength = max
+	}
+}
+
+func MaxQueryParams(max int) CollectorOption {
+	return func(c *Collector) {
+		c.MaxQueryParams = max
+	}
+}
+
+func MaxPathSegments(max int) CollectorOption {
+	return func(c *Collector) {
+		c.MaxPathSegments = max
+	}
+}
+
+func MaxRedirects(max int) CollectorOption {
+	return func(c *Collector) {
+		c.MaxRedirects = max
+	}
+}
+
+func RedirectWith(policy *RedirectPolicy) CollectorOption {
+	return func(c *Collector) {
+		c.SetRedirectPolicy(policy)
+	}
+}
+
+func FailOnRedirectLimit() CollectorOption {
+	return func(c *Collector) {
+		c.failOnRedirectLimit = true
+	}
+}
+
+func MaxDuration(d time.Duration) CollectorOption {
+	return func(c *Collector) {
+		c.MaxDuration = d
+	}
+}
+
+func AllowedDomains(domains ...string) CollectorOption {
+	return func(c *Collector) {
+		c.AllowedDomains = domains
+		c.allowedDomains.Store(c.compileDomains(domains))
+	}
+}
+
+func HostAliases(canonical string, aliases ...string) CollectorOption {
+	return func(c *Collector) {
+		c.AddHostAliases(canonical, aliases...)
+	}
+}
+
+func FoldWWW() CollectorOption {
+	return func(c *Collector) {
+		c.FoldWWW = true
+	}
+}
+
+func AllowedLanguages(languages ...string) CollectorOption {
+	return func(c *Collector) {
+		c.AllowedLanguages = languages
+	}
+}
+
+func AllowedContentTypes(types ...string) CollectorOption {
+	return func(c *Collector) {
+		c.AllowedContentTypes = types
+	}
+}
+
+func DisallowedContentTypes(types ...string) CollectorOption {
+	return func(c *Collector) {
+		c.DisallowedContentTypes = types
+	}
+}
+
+func ParseHTTPErrorResponse() CollectorOption {
+	return func(c *Collector) {
+		c.ParseHTTPErrorResponse = true
+	}
+}
+
+func AllowedRegisteredDomains(domains ...string) CollectorOption {
+	return func(c *Collector) {
+		c.AllowedRegisteredDomains = domains
+	}
+}
+
+func BlockPrivateNetworks(allowCIDRs ...string) CollectorOption {
+	return func(c *Collector) {
+		c.setOptionErr(c.BlockPrivateNetworks(allowCIDRs...))
+	}
+}
+
+func AllowedSchemes(schemes ...string) CollectorOption {
+	return func(c *Collector) {
+		c.AllowedSchemes = schemes
+	}
+}
+
+func DisallowedDomains(domains ...string) CollectorOption {
+	return func(c *Collector) {
+		c.DisallowedDomains = domains
+		c.disallowedDomains.Store(c.compileDomains(domains))
+	}
+}
+
+func DisallowedURLFilters(filters ...*regexp.Regexp) CollectorOption {
+	return func(c *Collector) {
+		c.DisallowedURLFilters = filters
+	}
+}
+
+func URLFilters(filters ...*regexp.Regexp) CollectorOption {
+	return func(c *Collector) {
+		c.URLFilters = filters
+	}
+}
+
+func AllowURLRevisit() CollectorOption {
+	return func(c *Collector) {
+		c.AllowURLRevisit = true
+	}
+}
+
+func MaxBodySize(sizeInBytes int) CollectorOption {
+	return func(c *Collector) {
+		c.MaxBodySize = sizeInBytes
+	}
+}
+
+func MaxBodySizeFor(mediatype string, sizeInBytes int) CollectorOption {
+	return func(c *Collector) {
+		c.SetMaxBodySizeFor(mediatype, sizeInBytes)
+	}
+}
+
+func FetchBodyIf(p HeaderPredicate) CollectorOption {
+	return func(c *Collector) {
+		c.bodyPredicate = p
+	}
+}
+
+func VisitedHash(algorithm HashAlgorithm, salt string) CollectorOption {
+	return func(c *Collector) {
+		c.hashAlgorithm = algorithm
+		c.hashSalt = salt
+	}
+}
+
+func TempDir(path string) CollectorOption {
+	return func(c *Collector) {
+		c.TempDir = path
+	}
+}
+
+func DiskQuota(maxBytes int64, policy DiskQuotaPolicy) CollectorOption {
+	return func(c *Collector) {
+		c.SetDiskQuota(maxBytes, policy)
+	}
+}
+
+func CacheHMACKey(key []byte) CollectorOption {
+	return func(c *Collector) {
+		c.cacheHMACKey = key
+	}
+}
+
+func RevalidateCache() CollectorOption {
+	return func(c *Collector) {
+		c.cacheRevalidate = true
+	}
+}
+
+func CacheMaxAge(maxAge time.Duration) CollectorOption {
+	return func(c *Collector) {
+		c.SetCacheMaxAge(maxAge)
+	}
+}
+
+func DomainCacheMaxAge(glob string, maxAge time.Duration) CollectorOption {
+	return func(c *Collector) {
+		c.SetDomainCacheMaxAge(glob, maxAge)
+	}
+}
+
+func HonorCacheHeaders() CollectorOption {
+	return func(c *Collector) {
+		c.cacheHonorHeaders = true
+	}
+}
+
+func CacheMaxSize(maxBytes int64) CollectorOption {
+	return func(c *Collector) {
+		c.SetCacheMaxSize(maxBytes)
+	}
+}
+
+func CompressCache() CollectorOption {
+	return func(c *Collector) {
+		c.cacheCompress = true
+	}
+}
+
+func Offline() CollectorOption {
+	return func(c *Collector) {
+		c.SetOffline(true)
+	}
+}
+
+func SkipDuplicateContent(normalize bool) CollectorOption {
+	return func(c *Collector) {
+		c.SetSkipDuplicateContent(normalize)
+	}
+}
+
+func AuditSite(audit *SiteAudit) CollectorOption {
+	return func(c *Collector) {
+		c.SetSiteAudit(audit)
+	}
+}
+
+func RecordHAR(recorder *HARRecorder) CollectorOption {
+	return func(c *Collector) {
+		c.SetHARRecorder(recorder)
+	}
+}
+
+func ReplayWARC(archive *WARCArchive) CollectorOption {
+	return func(c *Collector) {
+		c.WithTransport(archive)
+	}
+}
+
+func UseCassette(cassette *Cassette) CollectorOption {
+	return func(c *Collector) {
+		c.SetCassette(cassette)
+	}
+}
+
+func CacheIn(storage CacheStorage) CollectorOption {
+	return func(c *Collector) {
+		c.SetCacheStorage(storage)
+	}
+}
+
+func SpillBodiesOver(sizeInBytes int64) CollectorOption {
+	return func(c *Collector) {
+		c.spillThreshold = sizeInBytes
+	}
+}
+
+func CacheDir(path string) CollectorOption {
+	return func(c *Collector) {
+		c.CacheDir = path
+	}
+}
+
+func IgnoreRobotsTxt() CollectorOption {
+	return func(c *Collector) {
+		c.IgnoreRobotsTxt = true
+	}
+}
+
+func TraceHTTP() CollectorOption {
+	return func(c *Collector) {
+		c.TraceHTTP = true
+	}
+}
+
+func StdlibContext(ctx context.Context) CollectorOption {
+	return func(c *Collector) {
+		c.Context = ctx
+	}
+}
+
+func ID(id uint32) CollectorOption {
+	return func(c *Collector) {
+		c.ID = id
+	}
+}
+
+func Async(a ...bool) CollectorOption {
+	return func(c *Collector) {
+		if len(a) > 0 {
+			c.Async = a[0]
+		} else {
+			c.Async = true
+		}
+	}
+}
+
+func DetectCharset() CollectorOption {
+	return func(c *Collector) {
+		c.DetectCharset = true
+	}
+}
+
+func Debugger(d debug.Debugger) CollectorOption {
+	return func(c *Collector) {
+		d.Init()
+		c.debugger = d
+	}
+}
+
+func DisableCompression() CollectorOption {
+	return func(c *Collector) {
+		c.disableCompression = true
+	}
+}
+
+func CompressRequestBodies(minSize int) CollectorOption {
+	return func(c *Collector) {
+		c.compressRequests = true
+		c.compressMinSize = minSize
+	}
+}
+
+func ForceHTTP1() CollectorOption {
+	return func(c *Collector) {
+		c.ForceHTTP1()
+	}
+}
+
+func EnableH2C() CollectorOption {
+	return func(c *Collector) {
+		c.EnableH2C()
+	}
+}
+
+func HTTP2Settings(config *http.HTTP2Config) CollectorOption {
+	return func(c *Collector) {
+		c.SetHTTP2Config(config)
+	}
+}
+
+func Authenticate(auth Authenticator, domains ...string) CollectorOption {
+	return func(c *Collector) {
+		c.SetAuthenticator(auth, domains...)
+	}
+}
+
+func OAuth2(source oauth2.TokenSource, domains ...string) CollectorOption {
+	return func(c *Collector) {
+		c.SetAuthenticator(NewOAuth2Authenticator(source), domains...)
+	}
+}
+
+func DigestAuth(domain, username, password string) CollectorOption {
+	return func(c *Collector) {
+		c.SetDigestCredentials(domain, username, password)
+	}
+}
+
+func NTLMAuth(domain, username, password string) CollectorOption {
+	return func(c *Collector) {
+		c.SetNTLMCredentials(domain, username, password)
+	}
+}
+
+func WithClientCertificate(certFile, keyFile string) CollectorOption {
+	return func(c *Collector) {
+		c.setOptionErr(c.SetClientCertificate(certFile, keyFile))
+	}
+}
+
+func WithClientCertificateFor(domain, certFile, keyFile string) CollectorOption {
+	return func(c *Collector) {
+		c.setOptionErr(c.SetClientCertificate(certFile, keyFile, domain))
+	}
+}
+
+func RootCAs(pool *x509.CertPool) CollectorOption {
+	return func(c *Collector) {
+		c.SetRootCAs(pool)
+	}
+}
+
+func CABundle(pemFile string) CollectorOption {
+	return func(c *Collector) {
+		c.setOptionErr(c.LoadCABundle(pemFile))
+	}
+}
+
+func CertificatePins(domain string, pins ...string) CollectorOption {
+	return func(c *Collector) {
+		c.PinCertificates(domain, pins...)
+	}
+}
+
+func MinTLSVersion(version uint16) CollectorOption {
+	return func(c *Collector) {
+		c.SetMinTLSVersion(version)
+	}
+}
+
+func InsecureSkipVerify() CollectorOption {
+	return func(c *Collector) {
+		c.InsecureSkipVerify(true)
+	}
+}
 
-type ResponseHeadersCallback func(*Response)
+func DomainProxy(glob string, proxyURLs ...string) CollectorOption {
+	return func(c *Collector) {
+		c.setOptionErr(c.SetDomainProxy(glob, proxyURLs...))
+	}
+}
 
-type ResponseCallback func(*Response)
+func ProxySessions(f func(*Request) string) CollectorOption {
+	return func(c *Collector) {
+		c.SetProxySessionFunc(f)
+	}
+}
 
-type HTMLCallback func(*HTMLElement)
+func ProxiesFrom(provider ProxyProvider, interval time.Duration) CollectorOption {
+	return func(c *Collector) {
+		c.setOptionErr(c.SetProxyProvider(provider, interval))
+	}
+}
 
-type XMLCallback func(*XMLElement)
+func SignRequests(signer RequestSigner, domains ...string) CollectorOption {
+	return func(c *Collector) {
+		c.SignRequests(signer, domains...)
+	}
+}
 
-type ErrorCallback func(*Response, error)
+func HeaderOrder(order ...string) CollectorOption {
+	return func(c *Collector) {
+		c.SetHeaderOrder(order...)
+	}
+}
 
-type ScrapedCallback func(*Response)
+func Profile(p *profiles.Profile) CollectorOption {
+	return func(c *Collector) {
+		c.UseProfile(p)
+	}
+}
 
-type ProxyFunc func(*http.Request) (*url.URL, error)
+func RotateProfiles(ps ...*profiles.Profile) CollectorOption {
+	return func(c *Collector) {
+		c.RotateProfiles(ps...)
+	}
+}
 
-type AlreadyVisitedError struct {
-	Destination *url.URL
+func TLSFingerprint(hello utls.ClientHelloID) CollectorOption {
+	return func(c *Collector) {
+		c.SetTLSFingerprint(hello)
+	}
 }
 
-func (e *AlreadyVisitedError) Error() string {
-	return fmt.Sprintf("%q already visited", e.Destination)
+func HTTP3(domains ...string) CollectorOption {
+	return func(c *Collector) {
+		c.EnableHTTP3(domains...)
+	}
 }
 
-type htmlCallbackContainer struct {
-	Selector string
-	Function HTMLCallback
+func CheckHead() CollectorOption {
+	return func(c *Collector) {
+		c.CheckHead = true
+	}
 }
 
-type xmlCallbackContainer struct {
-	Query    string
-	Function XMLCallback
+func DetectTraps(rule *TrapRule) CollectorOption {
+	return func(c *Collector) {
+		c.DetectTraps(rule)
+	}
 }
 
-type cookieJarSerializer struct {
-	store storage.Storage
-	lock  *sync.RWMutex
+func DetectBans(detector *BanDetector) CollectorOption {
+	return func(c *Collector) {
+		c.DetectBans(detector)
+	}
 }
 
-var collectorCounter uint32
+func OnBlock(policy *BlockPolicy) CollectorOption {
+	return func(c *Collector) {
+		c.SetBlockPolicy(policy)
+	}
+}
 
-type key int
+func SolveCaptchas(solver CaptchaSolver) CollectorOption {
+	return func(c *Collector) {
+		c.SetCaptchaSolver(solver)
+	}
+}
 
-const ProxyURLKey key = iota
+func RenderWith(renderer Renderer, domains ...string) CollectorOption {
+	return func(c *Collector) {
+		c.SetRenderer(renderer, domains...)
+	}
+}
 
-var (
-	ErrForbiddenDomain     = errors.New("Forbidden domain")
-	ErrMissingURL          = errors.New("Missing URL")
-	ErrMaxDepth            = errors.New("Max depth limit reached")
-	ErrForbiddenURL        = errors.New("ForbiddenURL")
-	ErrNoURLFiltersMatch   = errors.New("No URLFilters match")
-	ErrRobotsTxtBlocked    = errors.New("URL blocked by robots.txt")
-	ErrNoCookieJar         = errors.New("Cookie jar is not available")
-	ErrNoPattern           = errors.New("No pattern defined in LimitRule")
-	ErrEmptyProxyURL       = errors.New("Proxy URL list is empty")
-	ErrAbortedAfterHeaders = errors.New("Aborted after receiving response headers")
-	ErrQueueFull           = errors.New("Queue MaxSize reached")
-	ErrMaxRequests         = errors.New("Max Requests limit reached")
-	ErrRetryBodyUnseekable = errors.New("Retry Body Unseekable")
-)
+func RenderURLs(renderer Renderer, filters ...*regexp.Regexp) CollectorOption {
+	return func(c *Collector) {
+		c.RenderURLs(renderer, filters...)
+	}
+}
 
-var envMap = map[string]func(*Collector, string){
-	"ALLOWED_DOMAINS": func(c *Collector, val string) {
-		c.AllowedDomains = strings.Split(val, ",")
-	},
-	"CACHE_DIR": func(c *Collector, val string) {
-		c.CacheDir = val
-	},
-	"DETECT_CHARSET": func(c *Collector, val string) {
-		c.DetectCharset = isYesString(val)
-	},
-	"DISABLE_COOKIES": func(c *Collector, _ string) {
-		c.backend.Client.Jar = nil
-	},
-	"DISALLOWED_DOMAINS": func(c *Collector, val string) {
-		c.DisallowedDomains = strings.Split(val, ",")
-	},
-	"IGNORE_ROBOTSTXT": func(c *Collector, val string) {
-		c.IgnoreRobotsTxt = isYesString(val)
-	},
-	"FOLLOW_REDIRECTS": func(c *Collector, val string) {
-		if !isYesString(val) {
-			c.redirectHandler = func(req *http.Request, via []*http.Request) error {
-				return http.ErrUseLastResponse
-			}
-		}
-	},
-	"MAX_BODY_SIZE": func(c *Collector, val string) {
-		size, err := strconv.Atoi(val)
-		if err == nil {
-			c.MaxBodySize = size
-		}
-	},
-	"MAX_DEPTH": func(c *Collector, val string) {
-		maxDepth, err := strconv.Atoi(val)
-		if err == nil {
-			c.MaxDepth = maxDepth
-		}
-	},
-	"MAX_REQUESTS": func(c *Collector, val string) {
-		maxRequests, err := strconv.ParseUint(val, 0, 32)
-		if err == nil {
-			c.MaxRequests = uint32(maxRequests)
-		}
-	},
-	"PARSE_HTTP_ERROR_RESPONSE": func(c *Collector, val string) {
-		c.ParseHTTPErrorResponse = isYesString(val)
-	},
-	"TRACE_HTTP": func(c *Collector, val string) {
-		c.TraceHTTP = isYesString(val)
-	},
-	"USER_AGENT": func(c *Collector, val string) {
-		c.UserAgent = val
-	},
+func Screenshots(options *ScreenshotOptions) CollectorOption {
+	return func(c *Collector) {
+		c.CaptureScreenshots(options)
+	}
 }
 
-var urlParser = whatwgUrl.NewParser(whatwgUrl.WithPercentEncodeSinglePercentSign())
+func Interstitials(interactor Interactor, interstitials ...*Interstitial) CollectorOption {
+	return func(c *Collector) {
+		c.HandleInterstitials(interactor, interstitials...)
+	}
+}
 
-func NewCollector(options ...CollectorOption) *Collector {
-	c := &Collector{}
-	c.Init()
+func RetryWith(policy *RetryPolicy) CollectorOption {
+	return func(c *Collector) {
+		c.SetRetryPolicy(policy)
+	}
+}
 
-	for _, f := range options {
-		f(c)
+func HedgeRequests(policy *HedgePolicy) CollectorOption {
+	return func(c *Collector) {
+		c.SetHedgePolicy(policy)
 	}
+}
 
-	c.parseSettingsFromEnv()
+func AutoThrottle(config *AutoThrottleConfig) CollectorOption {
+	return func(c *Collector) {
+		c.EnableAutoThrottle(config)
+	}
+}
 
-	return c
+func BandwidthLimit(bytesPerSecond int64) CollectorOption {
+	return func(c *Collector) {
+		c.SetBandwidthLimit(bytesPerSecond)
+	}
 }
 
-func UserAgent(ua string) CollectorOption {
+func HostBandwidthLimit(glob string, bytesPerSecond int64) CollectorOption {
 	return func(c *Collector) {
-		c.UserAgent = ua
+		c.SetHostBandwidthLimit(glob, bytesPerSecond)
 	}
 }
 
-func Headers(headers map[string]string) CollectorOption {
+func MaxConcurrency(n int) CollectorOption {
 	return func(c *Collector) {
-		customHeaders := make(http.Header)
-		for header, value := range headers {
-			customHeaders.Add(header, value)
-		}
-		c.Headers = &customHeaders
+		c.SetMaxConcurrency(n)
 	}
 }
 
-func MaxDepth(depth int) CollectorOption {
+func WorkerPool(workers, queueSize int, overflow QueueOverflow) CollectorOption {
 	return func(c *Collector) {
-		c.MaxDepth = depth
+		c.SetWorkerPool(workers, queueSize, overflow)
 	}
 }
 
-func MaxRequests(max uint32) CollectorOption {
+func DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) CollectorOption {
 	return func(c *Collector) {
-		c.MaxRequests = max
+		c.SetDialContext(dial)
 	}
 }
 
-func AllowedDomains(domains ...string) CollectorOption {
+func UnixSocket(glob, socketPath string) CollectorOption {
 	return func(c *Collector) {
-		c.AllowedDomains = domains
+		c.SetUnixSocket(glob, socketPath)
 	}
 }
 
-func ParseHTTPErrorResponse() CollectorOption {
+func MaxIdleConnsPerHost(n int) CollectorOption {
 	return func(c *Collector) {
-		c.ParseHTTPErrorResponse = true
+		c.SetMaxIdleConnsPerHost(n)
 	}
 }
 
-func DisallowedDomains(domains ...string) CollectorOption {
+func IdleConnTimeout(d time.Duration) CollectorOption {
 	return func(c *Collector) {
-		c.DisallowedDomains = domains
+		c.SetIdleConnTimeout(d)
 	}
 }
 
-func DisallowedURLFilters(filters ...*regexp.Regexp) CollectorOption {
+func TLSHandshakeTimeout(d time.Duration) CollectorOption {
 	return func(c *Collector) {
-		c.DisallowedURLFilters = filters
+		c.SetTLSHandshakeTimeout(d)
 	}
 }
 
-func URLFilters(filters ...*regexp.Regexp) CollectorOption {
+func ResponseHeaderTimeout(d time.Duration) CollectorOption {
 	return func(c *Collector) {
-		c.URLFilters = filters
+		c.SetResponseHeaderTimeout(d)
 	}
 }
 
-func AllowURLRevisit() CollectorOption {
+func KeepAlives(enabled bool) CollectorOption {
 	return func(c *Collector) {
-		c.AllowURLRevisit = true
+		c.SetKeepAlives(enabled)
 	}
 }
 
-func MaxBodySize(sizeInBytes int) CollectorOption {
+func UseIPFamily(family IPFamily) CollectorOption {
 	return func(c *Collector) {
-		c.MaxBodySize = sizeInBytes
+		c.SetIPFamily(family)
 	}
 }
 
-func CacheDir(path string) CollectorOption {
+func HappyEyeballs(fallbackDelay time.Duration) CollectorOption {
 	return func(c *Collector) {
-		c.CacheDir = path
+		c.SetFallbackDelay(fallbackDelay)
 	}
 }
 
-func IgnoreRobotsTxt() CollectorOption {
+func ResolveWith(resolver Resolver) CollectorOption {
 	return func(c *Collector) {
-		c.IgnoreRobotsTxt = true
+		c.SetResolver(resolver)
 	}
 }
 
-func TraceHTTP() CollectorOption {
+func CacheDNS(cache *DNSCache) CollectorOption {
 	return func(c *Collector) {
-		c.TraceHTTP = true
+		c.SetDNSCache(cache)
 	}
 }
 
-func StdlibContext(ctx context.Context) CollectorOption {
+func ClusterURLs(clusterer *URLClusterer) CollectorOption {
 	return func(c *Collector) {
-		c.Context = ctx
+		c.ClusterURLs(clusterer)
 	}
 }
 
-func ID(id uint32) CollectorOption {
+func Baseline(fingerprints map[string]string) CollectorOption {
 	return func(c *Collector) {
-		c.ID = id
+		c.SetBaseline(fingerprints)
 	}
 }
 
-func Async(a ...bool) CollectorOption {
+func XMLNamespaces(namespaces map[string]string) CollectorOption {
 	return func(c *Collector) {
-		if len(a) > 0 {
-			c.Async = a[0]
-		} else {
-			c.Async = true
+		for prefix, uri := range namespaces {
+			c.RegisterXMLNamespace(prefix, uri)
 		}
 	}
 }
 
-func DetectCharset() CollectorOption {
+func CorrelationID(config *CorrelationConfig) CollectorOption {
 	return func(c *Collector) {
-		c.DetectCharset = true
+		c.SetCorrelationID(config)
 	}
 }
 
-func Debugger(d debug.Debugger) CollectorOption {
+func Identify(id *Identification) CollectorOption {
 	return func(c *Collector) {
-		d.Init()
-		c.debugger = d
+		c.SetIdentification(id)
 	}
 }
 
-func CheckHead() CollectorOption {
+func TidyHTML(domains ...string) CollectorOption {
 	return func(c *Collector) {
-		c.CheckHead = true
+		c.tidyHTML = true
+		c.tidyDomains = domains
 	}
 }
 
@@ -338,19 +1256,30 @@ func (c *Collector) Init() {
 	c.Headers = nil
 	c.MaxDepth = 0
 	c.MaxRequests = 0
-	c.store = &storage.InMemoryStorage{}
+	c.MaxDuration = 0
+	c.MaxRequestsPerDomain = 0
+	c.MaxURLLength = 0
+	c.MaxQueryParams = 0
+	c.MaxPathSegments = 0
+	c.MaxRedirects = 10
+	c.cacheIndex = newCacheIndex()
+	c.store = &memoryStorage{InMemoryStorage: &storage.InMemoryStorage{}}
 	c.store.Init()
 	c.MaxBodySize = 10 * 1024 * 1024
 	c.backend = &httpBackend{}
 	jar, _ := cookiejar.New(nil)
 	c.backend.Init(jar)
 	c.backend.Client.CheckRedirect = c.checkRedirectFunc()
+	c.installTransport()
 	c.wg = &sync.WaitGroup{}
 	c.lock = &sync.RWMutex{}
+	c.socksLock = &sync.Mutex{}
 	c.robotsMap = make(map[string]*robotstxt.RobotsData)
 	c.IgnoreRobotsTxt = true
 	c.ID = atomic.AddUint32(&collectorCounter, 1)
 	c.TraceHTTP = false
+	c.requestStates = &sync.Map{}
+	c.orderedConns = &orderedPool{idle: make(map[string][]*orderedConn), lock: &sync.Mutex{}}
 	c.Context = context.Background()
 }
 
@@ -360,7 +1289,7 @@ func (c *Collector) Appengine(ctx context.Context) {
 	client.CheckRedirect = c.backend.Client.CheckRedirect
 	client.Timeout = c.backend.Client.Timeout
 
-	c.backend.Client = client
+	c.SetClient(client)
 }
 
 func (c *Collector) Visit(URL string) error {
@@ -396,7 +1325,6 @@ func (c *Collector) PostMultipart(URL string, requestData map[string][]byte) err
 	boundary := randomBoundary()
 	hdr := http.Header{}
 	hdr.Set("Content-Type", "multipart/form-data; boundary="+boundary)
-	hdr.Set("User-Agent", c.UserAgent)
 	return c.scrape(URL, "POST", 1, createMultipartReader(boundary, requestData), nil, hdr, true)
 }
 
@@ -439,6 +1367,13 @@ func (c *Collector) UnmarshalRequest(r []byte) (*Request, error) {
 }
 
 func (c *Collector) scrape(u, method string, depth int, requestData io.Reader, ctx *Context, hdr http.Header, checkRevisit bool) error {
+	return c.scrapeWith(u, method, depth, requestData, ctx, hdr, checkRevisit, c.Async, CacheDefault)
+}
+
+func (c *Collector) scrapeWith(u, method string, depth int, requestData io.Reader, ctx *Context, hdr http.Header, checkRevisit, async bool, mode CacheMode) error {
+	if c.optionErr != nil {
+		return c.optionErr
+	}
 	parsedWhatwgURL, err := urlParser.Parse(u)
 	if err != nil {
 		return err
@@ -447,18 +1382,40 @@ func (c *Collector) scrape(u, method string, depth int, requestData io.Reader, c
 	if err != nil {
 		return err
 	}
+	c.normalizeQuery(parsedURL)
+	if c.redirectPolicy != nil {
+		parsedURL = c.redirectPolicy.remap(parsedURL)
+	}
 	if hdr == nil {
 		hdr = http.Header{}
 		if c.Headers != nil {
 			for k, v := range *c.Headers {
+				if _, ok := hdr[k]; ok {
+					continue
+				}
 				for _, value := range v {
 					hdr.Add(k, value)
 				}
 			}
 		}
 	}
+	profile := c.nextProfile()
+	if profile != nil {
+		for k, v := range profile.Header() {
+			if _, ok := hdr[k]; !ok {
+				hdr[k] = v
+			}
+		}
+		if _, ok := hdr[HeaderOrderKey]; !ok && len(profile.HeaderOrder) > 0 && len(c.headerOrder) == 0 {
+			hdr[HeaderOrderKey] = []string{strings.Join(profile.HeaderOrder, ",")}
+		}
+	}
 	if _, ok := hdr["User-Agent"]; !ok {
-		hdr.Set("User-Agent", c.UserAgent)
+		hdr.Set("User-Agent", c.userAgentFor(parsedURL))
+	}
+	userAgent := hdr.Get("User-Agent")
+	if c.identification != nil {
+		c.identification.apply(hdr)
 	}
 	if seeker, ok := requestData.(io.ReadSeeker); ok {
 		_, err := seeker.Seek(0, io.SeekStart)
@@ -466,6 +1423,12 @@ func (c *Collector) scrape(u, method string, depth int, requestData io.Reader, c
 			return err
 		}
 	}
+	if c.shouldCompressRequest(method, requestData, hdr) {
+		requestData, err = c.compressRequestBody(requestData, hdr)
+		if err != nil {
+			return err
+		}
+	}
 
 	req, err := http.NewRequest(method, parsedURL.String(), requestData)
 	if err != nil {
@@ -475,13 +1438,38 @@ func (c *Collector) scrape(u, method string, depth int, requestData io.Reader, c
 	if hostHeader := hdr.Get("Host"); hostHeader != "" {
 		req.Host = hostHeader
 	}
-	req = req.WithContext(c.Context)
-	if err := c.requestCheck(parsedURL, method, req.GetBody, depth, checkRevisit); err != nil {
+	if profile != nil {
+		req = req.WithContext(context.WithValue(c.Context, profileContextKey, profile))
+	} else {
+		req = req.WithContext(c.Context)
+	}
+	if mode != CacheDefault {
+		req = req.WithContext(context.WithValue(req.Context(), cacheModeContextKey, mode))
+	}
+	if err := c.requestCheck(parsedURL, method, userAgent, req.GetBody, depth, checkRevisit); err != nil {
 		return err
 	}
+	if depth == 1 {
+		c.bindSeedHost(parsedURL.Hostname())
+	}
+	if c.urlClusterer != nil {
+		c.urlClusterer.Add(c.foldURL(parsedURL))
+	}
 	u = parsedURL.String()
 	c.wg.Add(1)
-	if c.Async {
+	if async && c.workerPool != nil {
+		if ctx == nil {
+			ctx = NewContext()
+		}
+		err := c.workerPool.submit(ctx, func() {
+			c.fetch(u, method, depth, requestData, ctx, hdr, req)
+		})
+		if err != nil {
+			c.wg.Done()
+		}
+		return err
+	}
+	if async {
 		go c.fetch(u, method, depth, requestData, ctx, hdr, req)
 		return nil
 	}
@@ -490,6 +1478,9 @@ func (c *Collector) scrape(u, method string, depth int, requestData io.Reader, c
 
 func (c *Collector) fetch(u, method string, depth int, requestData io.Reader, ctx *Context, hdr http.Header, req *http.Request) error {
 	defer c.wg.Done()
+	if c.durationExceeded() {
+		return ErrMaxDuration
+	}
 	if ctx == nil {
 		ctx = NewContext()
 	}
@@ -508,6 +1499,19 @@ func (c *Collector) fetch(u, method string, depth int, requestData io.Reader, ct
 	if req.Header.Get("Accept") == "" {
 		req.Header.Set("Accept", "*/*")
 	}
+	if !c.disableCompression && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br, zstd")
+	}
+
+	if c.correlation != nil {
+		c.correlation.inject(request)
+	}
+
+	state := &requestState{}
+	state.cacheMode, _ = req.Context().Value(cacheModeContextKey).(CacheMode)
+	c.requestStates.Store(request, state)
+	defer c.requestStates.Delete(request)
+	defer state.close()
 
 	c.handleOnRequest(request)
 
@@ -519,10 +1523,17 @@ func (c *Collector) fetch(u, method string, depth int, requestData io.Reader, ct
 		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	}
 
+	req = req.WithContext(context.WithValue(req.Context(), requestContextKey, request))
+
 	var hTrace *HTTPTrace
-	if c.TraceHTTP {
+	if c.TraceHTTP || c.har != nil {
 		hTrace = &HTTPTrace{}
 		req = hTrace.WithTrace(req)
+		state.hops = &traceHops{}
+	}
+	if c.har != nil {
+		c.har.startPage(request)
+		defer c.har.finishPage(request)
 	}
 	origURL := req.URL
 	checkHeadersFunc := func(req *http.Request, statusCode int, headers http.Header) bool {
@@ -530,12 +1541,141 @@ func (c *Collector) fetch(u, method string, depth int, requestData io.Reader, ct
 			request.URL = req.URL
 			request.Headers = &req.Header
 		}
-		c.handleOnResponseHeaders(&Response{Ctx: ctx, Request: request, StatusCode: statusCode, Headers: &headers})
-		return !request.abort
+		c.handleOnResponseHeaders(&Response{Ctx: ctx, Request: request, StatusCode: statusCode, Headers: &headers})
+		if request.abort || !c.isContentTypeAllowed(headers.Get("Content-Type")) {
+			return false
+		}
+		return c.bodyPredicate == nil || c.bodyPredicate(statusCode, headers)
+	}
+	bodySize := c.bodySizeCeiling()
+	var response *Response
+	var err error
+	var elapsed time.Duration
+	solved := false
+	interacted := false
+	for retries := 1; ; retries++ {
+		for attempt := 0; ; attempt++ {
+			if err := c.waitCoolDown(req.Context(), req.URL.Hostname()); err != nil {
+				return err
+			}
+			if c.autoThrottle != nil {
+				if err := c.autoThrottle.wait(req.Context(), req.URL.Hostname()); err != nil {
+					return err
+				}
+			}
+			fetchStart := time.Now()
+			sentURL := req.URL
+			release, slotErr := c.acquireSlot(req.Context())
+			if slotErr != nil {
+				return slotErr
+			}
+			if renderer := c.rendererFor(request); renderer != nil {
+				response, err = c.render(renderer, req, request, checkHeadersFunc)
+			} else {
+				response, err = c.cache(req, bodySize, checkHeadersFunc)
+			}
+			release()
+			elapsed = time.Since(fetchStart)
+			if c.autoThrottle != nil && !state.cached {
+				c.autoThrottle.observe(sentURL.Hostname(), time.Since(fetchStart), response, err)
+			}
+			if c.har != nil {
+				c.har.add(request, req, response, err, fetchStart, state)
+			}
+			if proxyURL, ok := req.Context().Value(ProxyURLKey).(string); ok {
+				request.ProxyURL = proxyURL
+			} else if state.proxyURL != "" {
+				request.ProxyURL = state.proxyURL
+			}
+			if err != nil || response == nil {
+				break
+			}
+			response.Ctx = ctx
+			response.Request = request
+			if matched := c.matchInterstitial(response); matched != nil {
+				state.cacheWrite = nil
+				if !interacted {
+					passed, interErr := c.passInterstitial(req, response, matched)
+					if interErr != nil {
+						err = interErr
+						break
+					}
+					if passed && rewindRequestBody(req) {
+						interacted = true
+						req.URL = origURL
+						request.URL = origURL
+						request.Headers = &req.Header
+						attempt--
+						continue
+					}
+				}
+			}
+			if c.banDetector == nil {
+				break
+			}
+			ban := c.banDetector.Check(response, sentURL)
+			if ban == nil {
+				break
+			}
+			c.handleOnBanDetected(ban)
+			err = &BlockedError{Ban: ban}
+			if c.captchaSolver != nil && !solved && (ban.Reason == BanChallenge || ban.Reason == BanCaptchaRedirect) {
+				solved = true
+				if c.offline {
+					err = &BlockedError{Ban: ban, Err: &NotCachedError{URL: sentURL.String(), Method: req.Method}}
+					break
+				}
+				solution, solveErr := c.captchaSolver.Solve(req.Context(), response)
+				if solveErr != nil {
+					err = &BlockedError{Ban: ban, Err: solveErr}
+				} else if solution != nil && rewindRequestBody(req) {
+					c.applyCaptchaSolution(req, origURL, solution)
+					request.URL = origURL
+					request.Headers = &req.Header
+					attempt--
+					continue
+				}
+			}
+			if c.blockPolicy == nil {
+				break
+			}
+			c.blockPolicy.apply(c, ban, origURL.Hostname())
+			if !c.blockPolicy.retry(attempt) || !rewindRequestBody(req) {
+				break
+			}
+			request.URL = origURL
+			request.Headers = &req.Header
+			request.ProxyURL = ""
+			state.proxyURL = ""
+		}
+		delay, retry := c.retryDelay(request, response, err, retries)
+		if !retry || c.durationExceeded() || !rewindRequestBody(req) {
+			break
+		}
+		c.handleOnRetry(&RetryAttempt{
+			Request:  request,
+			Response: response,
+			Err:      err,
+			Attempt:  retries,
+			Delay:    delay,
+		})
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return err
+		}
+		req.URL = origURL
+		request.URL = origURL
+		request.Headers = &req.Header
+		request.ProxyURL = ""
+		state.proxyURL = ""
+	}
+	if err == nil && response != nil {
+		err = c.commitCacheEntry(state, response)
 	}
-	response, err := c.backend.Cache(req, c.MaxBodySize, checkHeadersFunc, c.CacheDir)
-	if proxyURL, ok := req.Context().Value(ProxyURLKey).(string); ok {
-		request.ProxyURL = proxyURL
+	if c.differ != nil {
+		c.differ.record(c.foldURLString(u), response, err)
+	}
+	if c.audit != nil {
+		c.audit.record(u, request, response, err, elapsed, state)
 	}
 	if err := c.handleOnError(response, err, request, ctx); err != nil {
 		return err
@@ -544,22 +1684,38 @@ func (c *Collector) fetch(u, method string, depth int, requestData io.Reader, ct
 	response.Ctx = ctx
 	response.Request = request
 	response.Trace = hTrace
+	response.redirects = state.redirectHops()
+	response.traceHops = state.traceHops()
 
-	err = response.fixCharset(c.DetectCharset, request.ResponseCharacterEncoding)
+	err = response.decodeCharset(c.DetectCharset, request.ResponseCharacterEncoding)
 	if err != nil {
 		return err
 	}
 
+	if c.shouldTidy(response) {
+		response.Body = RepairHTML(response.Body)
+	}
+
 	c.handleOnResponse(response)
 
-	err = c.handleOnHTML(response)
-	if err != nil {
-		c.handleOnError(response, err, request, ctx)
+	if state.previous != nil {
+		c.handleOnChanged(state.previous, response)
 	}
 
-	err = c.handleOnXML(response)
-	if err != nil {
-		c.handleOnError(response, err, request, ctx)
+	if c.isLanguageAllowed(response) && !c.isDuplicateContent(response) {
+		body := &sharedBody{data: response.Body}
+		if response.Spilled() {
+			body.src = response.BodyReader()
+		}
+		err = c.handleOnHTML(response, body)
+		if err != nil {
+			c.handleOnError(response, err, request, ctx)
+		}
+
+		err = c.handleOnXML(response, body)
+		if err != nil {
+			c.handleOnError(response, err, request, ctx)
+		}
 	}
 
 	c.handleOnScraped(response)
@@ -567,27 +1723,44 @@ func (c *Collector) fetch(u, method string, depth int, requestData io.Reader, ct
 	return err
 }
 
-func (c *Collector) requestCheck(parsedURL *url.URL, method string, getBody func() (io.ReadCloser, error), depth int, checkRevisit bool) error {
+func (c *Collector) requestCheck(parsedURL *url.URL, method, userAgent string, getBody func() (io.ReadCloser, error), depth int, checkRevisit bool) error {
 	u := parsedURL.String()
-	if c.MaxDepth > 0 && c.MaxDepth < depth {
+	if !c.isSchemeAllowed(parsedURL.Scheme) {
+		return ErrForbiddenScheme
+	}
+	if err := c.checkURLComplexity(parsedURL, u); err != nil {
+		return err
+	}
+	if maxDepth := c.maxDepthFor(parsedURL.Hostname()); maxDepth > 0 && maxDepth < depth {
 		return ErrMaxDepth
 	}
 	if c.MaxRequests > 0 && c.requestCount >= c.MaxRequests {
 		return ErrMaxRequests
 	}
+	if c.durationExceeded() {
+		return ErrMaxDuration
+	}
+	if c.domainBudgetExhausted(parsedURL.Hostname()) {
+		return ErrMaxRequestsPerDomain
+	}
 	if err := c.checkFilters(u, parsedURL.Hostname()); err != nil {
 		return err
 	}
 	if method != "HEAD" && !c.IgnoreRobotsTxt {
-		if err := c.checkRobots(parsedURL); err != nil {
+		if err := c.checkRobots(parsedURL, userAgent); err != nil {
 			return err
 		}
 	}
-	if checkRevisit && !c.AllowURLRevisit {
-		if method != "GET" && getBody == nil {
-			return nil
+	if !c.reserveDomainRequest(parsedURL.Hostname()) {
+		return ErrMaxRequestsPerDomain
+	}
+	reserved := true
+	defer func() {
+		if reserved {
+			c.releaseDomainRequest(parsedURL.Hostname())
 		}
-
+	}()
+	if checkRevisit && !c.AllowURLRevisit && (method == "GET" || getBody != nil) {
 		var body io.ReadCloser
 		if getBody != nil {
 			var err error
@@ -597,16 +1770,25 @@ func (c *Collector) requestCheck(parsedURL *url.URL, method string, getBody func
 			}
 			defer body.Close()
 		}
-		uHash := requestHash(u, body)
-		visited, err := c.store.IsVisited(uHash)
+		key := c.visitKey(u, body)
+		visited, err := c.isVisited(key)
 		if err != nil {
 			return err
 		}
 		if visited {
 			return &AlreadyVisitedError{parsedURL}
 		}
-		return c.store.Visited(uHash)
+		if err := c.setVisited(key); err != nil {
+			return err
+		}
+	}
+	if c.trapRule != nil {
+		if trap := c.trapRule.check(c.foldURL(parsedURL)); trap != nil {
+			c.handleOnTrapDetected(trap)
+			return &TrapError{Trap: trap}
+		}
 	}
+	reserved = false
 	return nil
 }
 
@@ -628,29 +1810,44 @@ func (c *Collector) checkFilters(URL, domain string) error {
 }
 
 func (c *Collector) isDomainAllowed(domain string) bool {
-	for _, d2 := range c.DisallowedDomains {
-		if d2 == domain {
-			return false
-		}
+	domain = c.foldHost(strings.ToLower(domain))
+	if len(c.DisallowedDomains) > 0 && c.domainMatcher(&c.disallowedDomains, c.DisallowedDomains).match(domain) {
+		return false
 	}
-	if c.AllowedDomains == nil || len(c.AllowedDomains) == 0 {
+	if len(c.AllowedDomains) == 0 && len(c.AllowedRegisteredDomains) == 0 {
 		return true
 	}
-	for _, d2 := range c.AllowedDomains {
-		if d2 == domain {
-			return true
-		}
+	if len(c.AllowedDomains) > 0 && c.domainMatcher(&c.allowedDomains, c.AllowedDomains).match(domain) {
+		return true
 	}
-	return false
+	if len(c.AllowedRegisteredDomains) == 0 {
+		return false
+	}
+	registered, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(domain))
+	if err != nil {
+		return false
+	}
+	return c.domainMatcher(&c.registeredDomains, c.AllowedRegisteredDomains).hosts[registered]
 }
 
-func (c *Collector) checkRobots(u *url.URL) error {
+func (c *Collector) checkRobots(u *url.URL, userAgent string) error {
 	c.lock.RLock()
 	robot, ok := c.robotsMap[u.Host]
 	c.lock.RUnlock()
 
+	if !ok && c.offline {
+		return nil
+	}
 	if !ok {
-		resp, err := c.backend.Client.Get(u.Scheme + "://" + u.Host + "/robots.txt")
+		req, err := http.NewRequest("GET", u.Scheme+"://"+u.Host+"/robots.txt", nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if c.identification != nil {
+			c.identification.apply(req.Header)
+		}
+		resp, err := c.backend.Client.Do(req)
 		if err != nil {
 			return err
 		}
@@ -665,7 +1862,7 @@ func (c *Collector) checkRobots(u *url.URL) error {
 		c.lock.Unlock()
 	}
 
-	uaGroup := robot.FindGroup(c.UserAgent)
+	uaGroup := robot.FindGroup(userAgent)
 	if uaGroup == nil {
 		return nil
 	}
@@ -744,6 +1941,32 @@ func (c *Collector) OnXML(xpathQuery string, f XMLCallback) {
 	c.lock.Unlock()
 }
 
+func (c *Collector) OnHTMLWithConcurrency(goquerySelector string, parallelism int, f HTMLCallback) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	c.lock.Lock()
+	c.htmlCallbacks = append(c.htmlCallbacks, &htmlCallbackContainer{
+		Selector: goquerySelector,
+		Function: f,
+		sem:      make(chan struct{}, parallelism),
+	})
+	c.lock.Unlock()
+}
+
+func (c *Collector) OnXMLWithConcurrency(xpathQuery string, parallelism int, f XMLCallback) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	c.lock.Lock()
+	c.xmlCallbacks = append(c.xmlCallbacks, &xmlCallbackContainer{
+		Query:    xpathQuery,
+		Function: f,
+		sem:      make(chan struct{}, parallelism),
+	})
+	c.lock.Unlock()
+}
+
 func (c *Collector) OnHTMLDetach(goquerySelector string) {
 	c.lock.Lock()
 	deleteIdx := -1
@@ -774,6 +1997,61 @@ func (c *Collector) OnXMLDetach(xpathQuery string) {
 	c.lock.Unlock()
 }
 
+func (c *Collector) OnRedirect(f RedirectCallback) {
+	c.lock.Lock()
+	if c.redirectCallbacks == nil {
+		c.redirectCallbacks = make([]RedirectCallback, 0, 4)
+	}
+	c.redirectCallbacks = append(c.redirectCallbacks, f)
+	c.lock.Unlock()
+}
+
+func (c *Collector) OnTrapDetected(f TrapCallback) {
+	c.lock.Lock()
+	if c.trapCallbacks == nil {
+		c.trapCallbacks = make([]TrapCallback, 0, 4)
+	}
+	c.trapCallbacks = append(c.trapCallbacks, f)
+	c.lock.Unlock()
+}
+
+func (c *Collector) OnBanDetected(f BanCallback) {
+	c.lock.Lock()
+	if c.banCallbacks == nil {
+		c.banCallbacks = make([]BanCallback, 0, 4)
+	}
+	c.banCallbacks = append(c.banCallbacks, f)
+	c.lock.Unlock()
+}
+
+func (c *Collector) OnScreenshot(f ScreenshotCallback) {
+	c.lock.Lock()
+	if c.screenshotCallbacks == nil {
+		c.screenshotCallbacks = make([]ScreenshotCallback, 0, 4)
+	}
+	c.screenshotCallbacks = append(c.screenshotCallbacks, f)
+	c.lock.Unlock()
+}
+
+func (c *Collector) OnRetry(f RetryCallback) {
+	c.lock.Lock()
+	if c.retryCallbacks == nil {
+		c.retryCallbacks = make([]RetryCallback, 0, 4)
+	}
+	c.retryCallbacks = append(c.retryCallbacks, f)
+	c.lock.Unlock()
+}
+
+func (c *Collector) OnResponseStream(f ResponseStreamCallback) {
+	c.lock.Lock()
+	c.streamCallbacks = append(c.streamCallbacks, f)
+	c.lock.Unlock()
+}
+
+func (c *Collector) StreamBodyIf(p HeaderPredicate) {
+	c.streamPredicate = p
+}
+
 func (c *Collector) OnError(f ErrorCallback) {
 	c.lock.Lock()
 	if c.errorCallbacks == nil {
@@ -794,10 +2072,14 @@ func (c *Collector) OnScraped(f ScrapedCallback) {
 
 func (c *Collector) SetClient(client *http.Client) {
 	c.backend.Client = client
+	c.installTransport()
+	if c.customDial() {
+		c.installDialer()
+	}
 }
 
 func (c *Collector) WithTransport(transport http.RoundTripper) {
-	c.backend.Client.Transport = transport
+	c.setBaseTransport(transport)
 }
 
 func (c *Collector) DisableCookies() {
@@ -822,7 +2104,7 @@ func (c *Collector) SetStorage(s storage.Storage) error {
 }
 
 func (c *Collector) SetProxy(proxyURL string) error {
-	proxyParsed, err := url.Parse(proxyURL)
+	proxyParsed, err := parseProxyURL(proxyURL)
 	if err != nil {
 		return err
 	}
@@ -833,16 +2115,63 @@ func (c *Collector) SetProxy(proxyURL string) error {
 }
 
 func (c *Collector) SetProxyFunc(p ProxyFunc) {
-	t, ok := c.backend.Client.Transport.(*http.Transport)
-	if c.backend.Client.Transport != nil && ok {
-		t.Proxy = p
-		t.DisableKeepAlives = true
-	} else {
-		c.backend.Client.Transport = &http.Transport{
-			Proxy:             p,
-			DisableKeepAlives: true,
+	c.proxyFunc = p
+	c.installProxyFunc(true)
+}
+
+func (c *Collector) setOptionErr(err error) {
+	if err != nil && c.optionErr == nil {
+		c.optionErr = err
+	}
+}
+
+func (c *Collector) FetchBodyIf(p HeaderPredicate) {
+	c.bodyPredicate = p
+}
+
+func (c *Collector) SetMaxBodySizeFor(mediatype string, sizeInBytes int) {
+	c.lock.Lock()
+	sizes := make(map[string]int, len(c.MaxBodySizes)+1)
+	for k, v := range c.MaxBodySizes {
+		sizes[k] = v
+	}
+	sizes[strings.ToLower(mediatype)] = sizeInBytes
+	c.MaxBodySizes = sizes
+	c.lock.Unlock()
+}
+
+func (c *Collector) maxBodySizes() map[string]int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.MaxBodySizes
+}
+
+func (c *Collector) bodySizeCeiling() int {
+	size := c.MaxBodySize
+	for _, s := range c.maxBodySizes() {
+		if size <= 0 || s <= 0 {
+			return 0
+		}
+		if s > size {
+			size = s
+		}
+	}
+	return size
+}
+
+func (c *Collector) bodySizeFor(contentType string) int {
+	mediatype, _, _ := strings.Cut(contentType, ";")
+	mediatype = strings.TrimSpace(strings.ToLower(mediatype))
+	sizes := c.maxBodySizes()
+	if size, ok := sizes[mediatype]; ok {
+		return size
+	}
+	if major, _, ok := strings.Cut(mediatype, "/"); ok {
+		if size, ok := sizes[major+"/*"]; ok {
+			return size
 		}
 	}
+	return c.MaxBodySize
 }
 
 func createEvent(eventType string, requestID, collectorID uint32, kvargs map[string]string) *debug.Event {
@@ -856,9 +2185,9 @@ func createEvent(eventType string, requestID, collectorID uint32, kvargs map[str
 
 func (c *Collector) handleOnRequest(r *Request) {
 	if c.debugger != nil {
-		c.debugger.Event(createEvent("request", r.ID, c.ID, map[string]string{
+		c.debugger.Event(createEvent("request", r.ID, c.ID, c.correlationValues(r, map[string]string{
 			"url": r.URL.String(),
-		}))
+		})))
 	}
 	for _, f := range c.requestCallbacks {
 		f(r)
@@ -867,10 +2196,10 @@ func (c *Collector) handleOnRequest(r *Request) {
 
 func (c *Collector) handleOnResponse(r *Response) {
 	if c.debugger != nil {
-		c.debugger.Event(createEvent("response", r.Request.ID, c.ID, map[string]string{
+		c.debugger.Event(createEvent("response", r.Request.ID, c.ID, c.correlationValues(r.Request, map[string]string{
 			"url":    r.Request.URL.String(),
 			"status": http.StatusText(r.StatusCode),
-		}))
+		})))
 	}
 	for _, f := range c.responseCallbacks {
 		f(r)
@@ -879,17 +2208,17 @@ func (c *Collector) handleOnResponse(r *Response) {
 
 func (c *Collector) handleOnResponseHeaders(r *Response) {
 	if c.debugger != nil {
-		c.debugger.Event(createEvent("responseHeaders", r.Request.ID, c.ID, map[string]string{
+		c.debugger.Event(createEvent("responseHeaders", r.Request.ID, c.ID, c.correlationValues(r.Request, map[string]string{
 			"url":    r.Request.URL.String(),
 			"status": http.StatusText(r.StatusCode),
-		}))
+		})))
 	}
 	for _, f := range c.responseHeadersCallbacks {
 		f(r)
 	}
 }
 
-func (c *Collector) handleOnHTML(resp *Response) error {
+func (c *Collector) handleOnHTML(resp *Response, body *sharedBody) error {
 	if len(c.htmlCallbacks) == 0 {
 		return nil
 	}
@@ -907,10 +2236,11 @@ func (c *Collector) handleOnHTML(resp *Response) error {
 		return nil
 	}
 
-	doc, err := goquery.NewDocumentFromReader(bytes.NewBuffer(resp.Body))
+	root, err := body.html()
 	if err != nil {
 		return err
 	}
+	doc := goquery.NewDocumentFromNode(root)
 	if href, found := doc.Find("base[href]").Attr("href"); found {
 		u, err := urlParser.ParseRef(resp.Request.URL.String(), href)
 		if err == nil {
@@ -933,14 +2263,14 @@ func (c *Collector) handleOnHTML(resp *Response) error {
 						"url":      resp.Request.URL.String(),
 					}))
 				}
-				cc.Function(e)
+				cc.call(e)
 			}
 		})
 	}
 	return nil
 }
 
-func (c *Collector) handleOnXML(resp *Response) error {
+func (c *Collector) handleOnXML(resp *Response, body *sharedBody) error {
 	if len(c.xmlCallbacks) == 0 {
 		return nil
 	}
@@ -951,7 +2281,7 @@ func (c *Collector) handleOnXML(resp *Response) error {
 	}
 
 	if strings.Contains(contentType, "html") {
-		doc, err := htmlquery.Parse(bytes.NewBuffer(resp.Body))
+		doc, err := body.html()
 		if err != nil {
 			return err
 		}
@@ -976,17 +2306,21 @@ func (c *Collector) handleOnXML(resp *Response) error {
 						"url":      resp.Request.URL.String(),
 					}))
 				}
-				cc.Function(e)
+				cc.call(e)
 			}
 		}
 	} else if strings.Contains(contentType, "xml") || isXMLFile {
-		doc, err := xmlquery.Parse(bytes.NewBuffer(resp.Body))
+		doc, err := xmlquery.Parse(body.reader())
 		if err != nil {
 			return err
 		}
 
 		for _, cc := range c.xmlCallbacks {
-			xmlquery.FindEach(doc, cc.Query, func(i int, n *xmlquery.Node) {
+			nodes, err := c.findXMLNodes(doc, cc.Query)
+			if err != nil {
+				return err
+			}
+			for _, n := range nodes {
 				e := NewXMLElementFromXMLNode(resp, n)
 				if c.debugger != nil {
 					c.debugger.Event(createEvent("xml", resp.Request.ID, c.ID, map[string]string{
@@ -994,13 +2328,61 @@ func (c *Collector) handleOnXML(resp *Response) error {
 						"url":      resp.Request.URL.String(),
 					}))
 				}
-				cc.Function(e)
-			})
+				cc.call(e)
+			}
 		}
 	}
 	return nil
 }
 
+func (c *Collector) isContentTypeAllowed(contentType string) bool {
+	if len(c.AllowedContentTypes) == 0 && len(c.DisallowedContentTypes) == 0 {
+		return true
+	}
+	mediatype, _, _ := strings.Cut(contentType, ";")
+	mediatype = strings.TrimSpace(strings.ToLower(mediatype))
+	if mediatype == "" {
+		return true
+	}
+	if matchesMediaType(c.DisallowedContentTypes, mediatype) {
+		return false
+	}
+	return len(c.AllowedContentTypes) == 0 || matchesMediaType(c.AllowedContentTypes, mediatype)
+}
+
+type sharedBody struct {
+	data []byte
+	src  io.ReadSeeker
+}
+
+func (b *sharedBody) reader() io.Reader {
+	if b.src != nil {
+		b.src.Seek(0, io.SeekStart)
+		return b.src
+	}
+	return bytes.NewReader(b.data)
+}
+
+func (b *sharedBody) html() (*html.Node, error) {
+	return html.Parse(b.reader())
+}
+
+func (r *Response) decodeCharset(detectCharset bool, defaultEncoding string) error {
+	charset := strings.ToLower(strings.TrimSpace(defaultEncoding))
+	if charset == "" {
+		_, params, _ := strings.Cut(strings.ToLower(r.Headers.Get("Content-Type")), "charset=")
+		charset, _, _ = strings.Cut(params, ";")
+		charset = strings.Trim(strings.TrimSpace(charset), `"'`)
+		if charset == "" && detectCharset && utf8.Valid(r.Body) {
+			return nil
+		}
+	}
+	if charset == "utf-8" || charset == "utf8" {
+		return nil
+	}
+	return r.fixCharset(detectCharset, defaultEncoding)
+}
+
 func (c *Collector) handleOnError(response *Response, err error, request *Request, ctx *Context) error {
 	if err == nil && (c.ParseHTTPErrorResponse || response.StatusCode < 203) {
 		return nil
@@ -1015,10 +2397,10 @@ func (c *Collector) handleOnError(response *Response, err error, request *Reques
 		}
 	}
 	if c.debugger != nil {
-		c.debugger.Event(createEvent("error", request.ID, c.ID, map[string]string{
+		c.debugger.Event(createEvent("error", request.ID, c.ID, c.correlationValues(request, map[string]string{
 			"url":    request.URL.String(),
 			"status": http.StatusText(response.StatusCode),
-		}))
+		})))
 	}
 	if response.Request == nil {
 		response.Request = request
@@ -1032,11 +2414,104 @@ func (c *Collector) handleOnError(response *Response, err error, request *Reques
 	return err
 }
 
+func (c *Collector) handleOnTrapDetected(t *Trap) {
+	if c.debugger != nil {
+		c.debugger.Event(createEvent("trap", 0, c.ID, map[string]string{
+			"url":     t.URL.String(),
+			"pattern": t.Pattern,
+			"reason":  t.Reason,
+		}))
+	}
+	for _, f := range c.trapCallbacks {
+		f(t)
+	}
+}
+
+func (c *Collector) handleOnRedirect(req *http.Request, via []*http.Request) error {
+	r := &Redirect{
+		From: via[len(via)-1].URL,
+		To:   req.URL,
+	}
+	if req.Response != nil {
+		r.StatusCode = req.Response.StatusCode
+		r.Headers = req.Response.Header
+	}
+	r.Request, _ = req.Context().Value(requestContextKey).(*Request)
+	for _, f := range c.redirectCallbacks {
+		f(r)
+		if r.abort {
+			break
+		}
+	}
+	if c.debugger != nil {
+		requestID := uint32(0)
+		if r.Request != nil {
+			requestID = r.Request.ID
+		}
+		c.debugger.Event(createEvent("redirect", requestID, c.ID, map[string]string{
+			"from":    r.From.String(),
+			"to":      r.To.String(),
+			"status":  strconv.Itoa(r.StatusCode),
+			"aborted": strconv.FormatBool(r.abort),
+		}))
+	}
+	if r.abort {
+		return http.ErrUseLastResponse
+	}
+	if r.To != req.URL {
+		if r.To.Host != req.URL.Host {
+			req.Host = ""
+			for _, k := range redirectSensitiveHeaders {
+				req.Header.Del(k)
+			}
+		}
+		req.URL = r.To
+	}
+	return nil
+}
+
+var redirectSensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Www-Authenticate", "Cookie", "Cookie2"}
+
+func (c *Collector) handleOnBanDetected(b *Ban) {
+	if c.debugger != nil {
+		c.debugger.Event(createEvent("ban", b.Response.Request.ID, c.ID, map[string]string{
+			"url":    b.URL.String(),
+			"status": strconv.Itoa(b.StatusCode),
+			"reason": string(b.Reason),
+			"marker": b.Marker,
+			"proxy":  b.ProxyURL,
+		}))
+	}
+	for _, f := range c.banCallbacks {
+		f(b)
+	}
+}
+
+func (c *Collector) handleOnRetry(r *RetryAttempt) {
+	if c.debugger != nil {
+		values := map[string]string{
+			"url":     r.Request.URL.String(),
+			"attempt": strconv.Itoa(r.Attempt),
+			"delay":   r.Delay.String(),
+		}
+		if r.Err != nil {
+			values["error"] = r.Err.Error()
+		}
+		if r.Response != nil {
+			values["status"] = strconv.Itoa(r.Response.StatusCode)
+		}
+		c.debugger.Event(createEvent("retry", r.Request.ID, c.ID, values))
+	}
+	for _, f := range c.retryCallbacks {
+		f(r)
+	}
+}
+
 func (c *Collector) handleOnScraped(r *Response) {
 	if c.debugger != nil {
-		c.debugger.Event(createEvent("scraped", r.Request.ID, c.ID, map[string]string{
+		c.debugger.Event(createEvent("scraped", r.Request.ID, c.ID, c.correlationValues(r.Request, map[string]string{
 			"url": r.Request.URL.String(),
-		}))
+		})))
 	}
 	for _, f := range c.scrapedCallbacks {
 		f(r)
@@ -1081,48 +2556,181 @@ func (c *Collector) Cookies(URL string) []*http.Cookie {
 
 func (c *Collector) Clone() *Collector {
 	return &Collector{
-		AllowedDomains:         c.AllowedDomains,
-		AllowURLRevisit:        c.AllowURLRevisit,
-		CacheDir:               c.CacheDir,
-		DetectCharset:          c.DetectCharset,
-		DisallowedDomains:      c.DisallowedDomains,
-		ID:                     atomic.AddUint32(&collectorCounter, 1),
-		IgnoreRobotsTxt:        c.IgnoreRobotsTxt,
-		MaxBodySize:            c.MaxBodySize,
-		MaxDepth:               c.MaxDepth,
-		MaxRequests:            c.MaxRequests,
-		DisallowedURLFilters:   c.DisallowedURLFilters,
-		URLFilters:             c.URLFilters,
-		CheckHead:              c.CheckHead,
-		ParseHTTPErrorResponse: c.ParseHTTPErrorResponse,
-		UserAgent:              c.UserAgent,
-		Headers:                c.Headers,
-		TraceHTTP:              c.TraceHTTP,
-		Context:                c.Context,
-		store:                  c.store,
-		backend:                c.backend,
-		debugger:               c.debugger,
-		Async:                  c.Async,
-		redirectHandler:        c.redirectHandler,
-		errorCallbacks:         make([]ErrorCallback, 0, 8),
-		htmlCallbacks:          make([]*htmlCallbackContainer, 0, 8),
-		xmlCallbacks:           make([]*xmlCallbackContainer, 0, 8),
-		scrapedCallbacks:       make([]ScrapedCallback, 0, 8),
-		lock:                   c.lock,
-		requestCallbacks:       make([]RequestCallback, 0, 8),
-		responseCallbacks:      make([]ResponseCallback, 0, 8),
-		robotsMap:              c.robotsMap,
-		wg:                     &sync.WaitGroup{},
+		AllowedDomains:           c.AllowedDomains,
+		AllowURLRevisit:          c.AllowURLRevisit,
+		CacheDir:                 c.CacheDir,
+		DetectCharset:            c.DetectCharset,
+		DisallowedDomains:        c.DisallowedDomains,
+		AllowedRegisteredDomains: c.AllowedRegisteredDomains,
+		AllowedSchemes:           c.AllowedSchemes,
+		HostAliases:              c.HostAliases,
+		FoldWWW:                  c.FoldWWW,
+		ID:                       atomic.AddUint32(&collectorCounter, 1),
+		IgnoreRobotsTxt:          c.IgnoreRobotsTxt,
+		MaxBodySize:              c.MaxBodySize,
+		MaxBodySizes:             c.MaxBodySizes,
+		MaxDepth:                 c.MaxDepth,
+		MaxRequests:              c.MaxRequests,
+		MaxDuration:              c.MaxDuration,
+		MaxRequestsPerDomain:     c.MaxRequestsPerDomain,
+		MaxURLLength:             c.MaxURLLength,
+		MaxQueryParams:           c.MaxQueryParams,
+		MaxPathSegments:          c.MaxPathSegments,
+		MaxRedirects:             c.MaxRedirects,
+		failOnRedirectLimit:      c.failOnRedirectLimit,
+		redirectPolicy:           c.redirectPolicy,
+		domainBudgetFunc:         c.domainBudgetFunc,
+		domainDepths:             c.domainDepths,
+		queryRules:               c.queryRules,
+		canonicalizers:           c.canonicalizers,
+		ssrf:                     c.ssrf,
+		dialTransport:            c.dialTransport,
+		orderedConns:             c.orderedConns,
+		dnsCache:                 c.dnsCache,
+		resolver:                 c.resolver,
+		ipFamily:                 c.ipFamily,
+		fallbackDelay:            c.fallbackDelay,
+		dialer:                   c.dialer,
+		unixSockets:              c.unixSockets,
+		keepAlives:               c.keepAlives,
+		AllowedLanguages:         c.AllowedLanguages,
+		AllowedContentTypes:      c.AllowedContentTypes,
+		DisallowedContentTypes:   c.DisallowedContentTypes,
+		DisallowedURLFilters:     c.DisallowedURLFilters,
+		URLFilters:               c.URLFilters,
+		CheckHead:                c.CheckHead,
+		ParseHTTPErrorResponse:   c.ParseHTTPErrorResponse,
+		UserAgent:                c.UserAgent,
+		UserAgents:               c.UserAgents,
+		Headers:                  c.Headers,
+		TraceHTTP:                c.TraceHTTP,
+		Context:                  c.Context,
+		store:                    c.store,
+		backend:                  c.backend,
+		debugger:                 c.debugger,
+		Async:                    c.Async,
+		redirectHandler:          c.redirectHandler,
+		errorCallbacks:           make([]ErrorCallback, 0, 8),
+		htmlCallbacks:            make([]*htmlCallbackContainer, 0, 8),
+		xmlCallbacks:             make([]*xmlCallbackContainer, 0, 8),
+		scrapedCallbacks:         make([]ScrapedCallback, 0, 8),
+		lock:                     c.lock,
+		socksTransports:          c.socksTransports,
+		socksLock:                c.socksLock,
+		requestCallbacks:         make([]RequestCallback, 0, 8),
+		responseCallbacks:        make([]ResponseCallback, 0, 8),
+		robotsMap:                c.robotsMap,
+		trapRule:                 c.trapRule,
+		banDetector:              c.banDetector,
+		blockPolicy:              c.blockPolicy,
+		captchaSolver:            c.captchaSolver,
+		renderRoutes:             c.renderRoutes,
+		screenshots:              c.screenshots,
+		interstitials:            c.interstitials,
+		interactor:               c.interactor,
+		retryPolicy:              c.retryPolicy,
+		hedging:                  c.hedging,
+		autoThrottle:             c.autoThrottle,
+		bandwidth:                c.bandwidth,
+		concurrency:              c.concurrency,
+		workerPool:               c.workerPool,
+		coolDowns:                c.coolDowns,
+		urlClusterer:             c.urlClusterer,
+		differ:                   c.differ,
+		xmlNamespaces:            c.xmlNamespaces,
+		correlation:              c.correlation,
+		tidyHTML:                 c.tidyHTML,
+		tidyDomains:              c.tidyDomains,
+		bodyPredicate:            c.bodyPredicate,
+		hashAlgorithm:            c.hashAlgorithm,
+		hashSalt:                 c.hashSalt,
+		streamPredicate:          c.streamPredicate,
+		requestStates:            &sync.Map{},
+		spillThreshold:           c.spillThreshold,
+		TempDir:                  c.TempDir,
+		diskQuota:                c.diskQuota,
+		cacheHMACKey:             c.cacheHMACKey,
+		cacheRevalidate:          c.cacheRevalidate,
+		cacheMaxAge:              c.cacheMaxAge,
+		cacheDomainMaxAges:       c.cacheDomainMaxAges,
+		cacheHonorHeaders:        c.cacheHonorHeaders,
+		cacheIndex:               c.cacheIndex,
+		cacheStorage:             c.cacheStorage,
+		cacheCompress:            c.cacheCompress,
+		offline:                  c.offline,
+		cassette:                 c.cassette,
+		har:                      c.har,
+		audit:                    c.audit,
+		contentHashes:            c.contentHashes,
+		disableCompression:       c.disableCompression,
+		compressRequests:         c.compressRequests,
+		compressMinSize:          c.compressMinSize,
+		identification:           c.identification,
+		headerOrder:              c.headerOrder,
+		domainHeaders:            c.cloneDomainHeaders(),
+		signers:                  c.signers,
+		authenticators:           c.authenticators,
+		digest:                   c.digest,
+		clientCerts:              c.clientCerts,
+		certificatePins:          c.certificatePins,
+		optionErr:                c.optionErr,
+		proxySwitcher:            c.proxySwitcher,
+		proxySession:             c.proxySession,
+		proxyFunc:                c.proxyFunc,
+		domainProxies:            c.domainProxies,
+		proxyRouted:              c.proxyRouted,
+		profiles:                 c.profiles,
+		wg:                       &sync.WaitGroup{},
 	}
 }
 
 func (c *Collector) checkRedirectFunc() func(req *http.Request, via []*http.Request) error {
+	check := c.redirectCheck()
+	return func(req *http.Request, via []*http.Request) error {
+		if err := check(req, via); err != nil {
+			return err
+		}
+		if state := c.requestState(req); state != nil && req.Response != nil {
+			state.addRedirect(RedirectHop{
+				URL:        via[len(via)-1].URL,
+				StatusCode: req.Response.StatusCode,
+				Headers:    req.Response.Header,
+				proto:      req.Response.Proto,
+			})
+		}
+		return nil
+	}
+}
+
+func (c *Collector) redirectCheck() func(req *http.Request, via []*http.Request) error {
 	return func(req *http.Request, via []*http.Request) error {
+		if c.redirectPolicy != nil {
+			if err := c.redirectPolicy.check(req, via); err != nil {
+				return err
+			}
+		}
 		if err := c.checkFilters(req.URL.String(), req.URL.Hostname()); err != nil {
 			return fmt.Errorf("Not following redirect to %q: %w", req.URL, err)
 		}
+		if c.redirectHandler == nil && len(via) >= c.MaxRedirects {
+			if c.failOnRedirectLimit {
+				return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, c.MaxRedirects)
+			}
+			return http.ErrUseLastResponse
+		}
+		if len(c.redirectCallbacks) > 0 {
+			destination := req.URL
+			if err := c.handleOnRedirect(req, via); err != nil {
+				return err
+			}
+			if req.URL != destination {
+				if err := c.checkFilters(req.URL.String(), req.URL.Hostname()); err != nil {
+					return fmt.Errorf("Not following redirect to %q: %w", req.URL, err)
+				}
+			}
+		}
 
-		samePageRedirect := normalizeURL(req.URL.String()) == normalizeURL(via[0].URL.String())
+		samePageRedirect := normalizeURL(c.foldURLString(req.URL.String())) == normalizeURL(c.foldURLString(via[0].URL.String()))
 
 		if !c.AllowURLRevisit && !samePageRedirect {
 			var body io.ReadCloser
@@ -1134,15 +2742,15 @@ func (c *Collector) checkRedirectFunc() func(req *http.Request, via []*http.Requ
 				}
 				defer body.Close()
 			}
-			uHash := requestHash(req.URL.String(), body)
-			visited, err := c.store.IsVisited(uHash)
+			key := c.visitKey(req.URL.String(), body)
+			visited, err := c.isVisited(key)
 			if err != nil {
 				return err
 			}
 			if visited {
 				return &AlreadyVisitedError{req.URL}
 			}
-			err = c.store.Visited(uHash)
+			err = c.setVisited(key)
 			if err != nil {
 				return err
 			}
@@ -1152,10 +2760,6 @@ func (c *Collector) checkRedirectFunc() func(req *http.Request, via []*http.Requ
 			return c.redirectHandler(req, via)
 		}
 
-		if len(via) >= 10 {
-			return http.ErrUseLastResponse
-		}
-
 		lastRequest := via[len(via)-1]
 
 		if req.URL.Host != lastRequest.URL.Host {
@@ -1181,8 +2785,7 @@ func (c *Collector) parseSettingsFromEnv() {
 }
 
 func (c *Collector) checkHasVisited(URL string, requestData map[string]string) (bool, error) {
-	hash := requestHash(URL, createFormReader(requestData))
-	return c.store.IsVisited(hash)
+	return c.isVisited(c.visitKey(URL, createFormReader(requestData)))
 }
 
 func SanitizeFileName(fileName string) string {
@@ -1286,6 +2889,19 @@ func isMatchingFilter(fs []*regexp.Regexp, d []byte) bool {
 	return false
 }
 
+func matchesMediaType(patterns []string, mediatype string) bool {
+	for _, p := range patterns {
+		p = strings.TrimSpace(strings.ToLower(p))
+		if p == mediatype || p == "*/*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "/*"); ok && strings.HasPrefix(mediatype, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 func normalizeURL(u string) string {
 	parsed, err := urlParser.Parse(u)
 	if err != nil {
@@ -1294,11 +2910,81 @@ func normalizeURL(u string) string {
 	return parsed.String()
 }
 
-func requestHash(url string, body io.Reader) uint64 {
-	h := fnv.New64a()
-	io.WriteString(h, normalizeURL(url))
-	if body != nil {
-		io.Copy(h, body)
+func (c *Collector) requestState(req *http.Request) *requestState {
+	r, ok := req.Context().Value(requestContextKey).(*Request)
+	if !ok {
+		return nil
+	}
+	v, ok := c.requestStates.Load(r)
+	if !ok {
+		return nil
+	}
+	return v.(*requestState)
+}
+
+type requestState struct {
+	hops       *traceHops
+	spill      *spilledBody
+	proxyURL   string
+	redirects  []RedirectHop
+	previous   *cacheEntry
+	refetch    bool
+	streamed   bool
+	cached     bool
+	cacheWrite func() error
+	cacheMode  CacheMode
+	lock       sync.Mutex
+}
+
+func (s *requestState) addRedirect(hop RedirectHop) {
+	s.lock.Lock()
+	s.redirects = append(s.redirects, hop)
+	s.lock.Unlock()
+}
+
+func (s *requestState) resetRedirects() {
+	s.lock.Lock()
+	s.redirects = nil
+	s.lock.Unlock()
+}
+
+func (s *requestState) redirectHops() []RedirectHop {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]RedirectHop(nil), s.redirects...)
+}
+
+func (s *requestState) restoreRedirects(redirects []cacheRedirect) {
+	hops := make([]RedirectHop, 0, len(redirects))
+	for _, r := range redirects {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			continue
+		}
+		hops = append(hops, RedirectHop{URL: u, StatusCode: r.StatusCode, Headers: r.Headers})
+	}
+	s.lock.Lock()
+	s.redirects = hops
+	s.lock.Unlock()
+}
+
+func (s *requestState) close() {
+	if s.spill != nil {
+		s.spill.close()
+	}
+}
+
+func rewindRequestBody(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	if req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
 	}
-	return h.Sum64()
+	req.Body = body
+	return true
 }
@@ -0,0 +1,523 @@
+package colly
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxAttempts      int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	Jitter           float64
+	StatusCodes      []int
+	Retryable        func(error) bool
+	IgnoreRetryAfter bool
+	MaxRetryAfter    time.Duration
+	ThrottleHost     bool
+	Statuses         map[int]RetryRule
+	Errors           map[ErrorClass]RetryRule
+	Decide           func(*Response, error) RetryDecision
+	Methods          []string
+}
+
+type RetryRule struct {
+	Never        bool
+	MaxAttempts  int
+	ThrottleHost bool
+}
+
+type RetryDecision int
+
+const (
+	RetryDefault RetryDecision = iota
+	RetryNever
+	RetryAlways
+	RetryWithHostBackoff
+)
+
+type RetryAttempt struct {
+	Request  *Request
+	Response *Response
+	Err      error
+	Attempt  int
+	Delay    time.Duration
+}
+
+var defaultRetryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooEarly,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+var defaultRetryMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodOptions,
+	http.MethodPut,
+	http.MethodDelete,
+}
+
+func (p *RetryPolicy) Init() {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = 3
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = 30 * time.Second
+	}
+	if p.Jitter == 0 {
+		p.Jitter = 0.5
+	}
+	if p.StatusCodes == nil {
+		p.StatusCodes = defaultRetryStatusCodes
+	}
+	if p.Methods == nil {
+		p.Methods = defaultRetryMethods
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryableError
+	}
+	if p.MaxRetryAfter == 0 {
+		p.MaxRetryAfter = 5 * time.Minute
+	}
+}
+
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		jitter := float64(delay) * math.Min(p.Jitter, 1)
+		delay = time.Duration(float64(delay) - jitter + mathrand.Float64()*2*jitter)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return delay
+}
+
+func (p *RetryPolicy) retriesMethod(method string) bool {
+	if method == "" {
+		method = http.MethodGet
+	}
+	for _, m := range p.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryable(r *Response, err error) bool {
+	if err != nil {
+		var blocked *BlockedError
+		if errors.As(err, &blocked) {
+			err = nil
+		} else {
+			return p.Retryable(err)
+		}
+	}
+	if r == nil {
+		return false
+	}
+	for _, code := range p.StatusCodes {
+		if r.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Collector) retryableResponse(r *Response) bool {
+	p := c.retryPolicy
+	if p == nil || r == nil {
+		return false
+	}
+	if rule, ok := p.Statuses[r.StatusCode]; ok {
+		return !rule.Never
+	}
+	return p.retryable(r, nil)
+}
+
+func (c *Collector) SetRetryPolicy(policy *RetryPolicy) {
+	if policy != nil {
+		policy.Init()
+	}
+	c.retryPolicy = policy
+}
+
+func (c *Collector) retryDelay(request *Request, r *Response, err error, attempt int) (time.Duration, bool) {
+	p := c.retryPolicy
+	if p == nil || !p.retriesMethod(request.Method) {
+		return 0, false
+	}
+	host := request.URL.Hostname()
+	retryAfter, hasRetryAfter := p.retryAfter(r)
+	if hasRetryAfter && p.ThrottleHost {
+		c.CoolDown(host, retryAfter)
+	}
+	rule, hasRule := p.rule(r, err)
+	maxAttempts := p.MaxAttempts
+	if hasRule && rule.MaxAttempts > 0 {
+		maxAttempts = rule.MaxAttempts
+	}
+	if rule.Never || attempt >= maxAttempts || (!hasRule && !p.retryable(r, err)) {
+		return 0, false
+	}
+	delay := p.Backoff(attempt)
+	if hasRetryAfter {
+		delay = retryAfter
+	}
+	if rule.ThrottleHost {
+		c.CoolDown(host, delay)
+	}
+	return delay, true
+}
+
+func (p *RetryPolicy) rule(r *Response, err error) (RetryRule, bool) {
+	if p.Decide != nil {
+		switch p.Decide(r, err) {
+		case RetryNever:
+			return RetryRule{Never: true}, true
+		case RetryAlways:
+			return RetryRule{}, true
+		case RetryWithHostBackoff:
+			return RetryRule{ThrottleHost: true}, true
+		}
+	}
+	var blocked *BlockedError
+	if err != nil && !errors.As(err, &blocked) {
+		rule, ok := p.Errors[ClassifyError(err)]
+		return rule, ok
+	}
+	if r == nil {
+		return RetryRule{}, false
+	}
+	rule, ok := p.Statuses[r.StatusCode]
+	return rule, ok
+}
+
+func (p *RetryPolicy) retryAfter(r *Response) (time.Duration, bool) {
+	if p.IgnoreRetryAfter || r == nil || r.Headers == nil {
+		return 0, false
+	}
+	if r.StatusCode != http.StatusTooManyRequests && r.StatusCode != http.StatusServiceUnavailable {
+		return 0, false
+	}
+	d, ok := ParseRetryAfter(r.Headers.Get("Retry-After"), time.Now())
+	if !ok {
+		return 0, false
+	}
+	if p.MaxRetryAfter > 0 && d > p.MaxRetryAfter {
+		d = p.MaxRetryAfter
+	}
+	return d, true
+}
+
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	d := t.Sub(now)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+type ErrorClass int
+
+const (
+	ErrorUnknown ErrorClass = iota
+	ErrorCanceled
+	ErrorDNS
+	ErrorTLS
+	ErrorTimeout
+	ErrorConnection
+)
+
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorUnknown
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorCanceled
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorDNS
+	}
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) || errors.Is(err, ErrCertificatePin) {
+		return ErrorTLS
+	}
+	var netErr net.Error
+	if (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return ErrorTimeout
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return ErrorConnection
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrorConnection
+	}
+	return ErrorUnknown
+}
+
+func IsRetryableError(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorTimeout, ErrorConnection:
+		return true
+	case ErrorDNS:
+		var dnsErr *net.DNSError
+		errors.As(err, &dnsErr)
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	return false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type HedgePolicy struct {
+	Percentile float64
+	Delay      time.Duration
+	MinDelay   time.Duration
+	MinSamples int
+	Window     int
+	Domains    []string
+	latencies  map[string]*latencyWindow
+	lock       *sync.Mutex
+}
+
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+type hedgeResult struct {
+	id       int
+	res      *http.Response
+	proxyURL string
+	err      error
+	cancel   context.CancelFunc
+	latency  time.Duration
+}
+
+type hedgeBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *hedgeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func (h *HedgePolicy) Init() {
+	if h.Percentile == 0 {
+		h.Percentile = 0.95
+	}
+	if h.Delay == 0 {
+		h.Delay = time.Second
+	}
+	if h.MinDelay == 0 {
+		h.MinDelay = 50 * time.Millisecond
+	}
+	if h.MinSamples == 0 {
+		h.MinSamples = 20
+	}
+	if h.Window == 0 {
+		h.Window = 200
+	}
+	for i, d := range h.Domains {
+		h.Domains[i] = strings.ToLower(d)
+	}
+	h.latencies = make(map[string]*latencyWindow)
+	h.lock = &sync.Mutex{}
+}
+
+func (c *Collector) SetHedgePolicy(policy *HedgePolicy) {
+	if policy != nil {
+		policy.Init()
+	}
+	c.hedging = policy
+}
+
+func (h *HedgePolicy) applies(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		return false
+	}
+	return hostMatches(h.Domains, strings.ToLower(req.URL.Hostname()))
+}
+
+func (h *HedgePolicy) threshold(host string) time.Duration {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	w, ok := h.latencies[host]
+	if !ok || len(w.samples) < h.MinSamples {
+		return h.Delay
+	}
+	sorted := append([]time.Duration(nil), w.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(math.Ceil(h.Percentile*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	if sorted[i] < h.MinDelay {
+		return h.MinDelay
+	}
+	return sorted[i]
+}
+
+func (h *HedgePolicy) observe(host string, latency time.Duration) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	w, ok := h.latencies[host]
+	if !ok {
+		w = &latencyWindow{}
+		h.latencies[host] = w
+	}
+	if len(w.samples) < h.Window {
+		w.samples = append(w.samples, latency)
+		return
+	}
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % h.Window
+}
+
+func (c *Collector) hedgedRoundTrip(next http.RoundTripper, req *http.Request) (*http.Response, string, error) {
+	h := c.hedging
+	if h == nil || !h.applies(req) {
+		return c.proxiedRoundTrip(next, req)
+	}
+	host := strings.ToLower(req.URL.Host)
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	launch := func() {
+		ctx, cancel := context.WithCancel(req.Context())
+		id := len(cancels)
+		cancels = append(cancels, cancel)
+		start := time.Now()
+		go func() {
+			res, proxyURL, err := c.proxiedRoundTrip(next, req.Clone(ctx))
+			results <- hedgeResult{id: id, res: res, proxyURL: proxyURL, err: err, cancel: cancel, latency: time.Since(start)}
+		}()
+	}
+	launch()
+	pending := 1
+	timer := time.NewTimer(h.threshold(host))
+	defer timer.Stop()
+	hedged := false
+	var failed hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedged = true
+				pending++
+				launch()
+				if c.debugger != nil {
+					c.debugger.Event(createEvent("hedge", 0, c.ID, map[string]string{
+						"url": req.URL.String(),
+					}))
+				}
+			}
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				r.cancel()
+				failed = r
+				if pending > 0 {
+					continue
+				}
+				if !hedged {
+					timer.Stop()
+				}
+				return nil, failed.proxyURL, failed.err
+			}
+			h.observe(host, r.latency)
+			for id, cancel := range cancels {
+				if id != r.id {
+					cancel()
+				}
+			}
+			if pending > 0 {
+				go drainHedge(results, pending)
+			}
+			r.res.Body = &hedgeBody{ReadCloser: r.res.Body, cancel: r.cancel}
+			return r.res, r.proxyURL, nil
+		}
+	}
+}
+
+func drainHedge(results chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		r := <-results
+		if r.res != nil {
+			r.res.Body.Close()
+		}
+		r.cancel()
+	}
+}
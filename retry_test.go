@@ -0,0 +1,243 @@
+package colly
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: -1}
+	p.Init()
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		9: time.Second,
+	} {
+		if got := p.Backoff(attempt); got != want {
+			t.Errorf("attempt %d: expected %v, got %v", attempt, want, got)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.Backoff(2); d < 100*time.Millisecond || d > 300*time.Millisecond {
+			t.Fatalf("expected jittered delay within 50%% of 200ms, got %v", d)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 0 ", 0, true},
+		{"-1", 0, false},
+		{"", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Hour).Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		delay, ok := ParseRetryAfter(tt.value, now)
+		if delay != tt.delay || ok != tt.ok {
+			t.Errorf("%q: expected %v %v, got %v %v", tt.value, tt.delay, tt.ok, delay, ok)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		class     ErrorClass
+		retryable bool
+	}{
+		{"canceled", fmt.Errorf("get: %w", context.Canceled), ErrorCanceled, false},
+		{"dns not found", &net.DNSError{Err: "no such host", IsNotFound: true}, ErrorDNS, false},
+		{"dns timeout", &net.DNSError{Err: "timeout", IsTimeout: true}, ErrorDNS, true},
+		{"tls", x509.UnknownAuthorityError{}, ErrorTLS, false},
+		{"pin", fmt.Errorf("handshake: %w", ErrCertificatePin), ErrorTLS, false},
+		{"deadline", context.DeadlineExceeded, ErrorTimeout, true},
+		{"net timeout", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, ErrorTimeout, true},
+		{"reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, ErrorConnection, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, ErrorConnection, true},
+		{"unknown", errors.New("boom"), ErrorUnknown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.class {
+				t.Fatalf("expected class %d, got %d", tt.class, got)
+			}
+			if got := IsRetryableError(tt.err); got != tt.retryable {
+				t.Fatalf("expected retryable=%v", tt.retryable)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		statuses []int
+		policy   RetryPolicy
+		requests int32
+		retries  int
+		ok       bool
+	}{
+		{"retries a transient status", "GET", []int{503, 200}, RetryPolicy{}, 2, 1, true},
+		{"stops at MaxAttempts", "GET", []int{500}, RetryPolicy{MaxAttempts: 3}, 3, 2, false},
+		{"ignores other statuses", "GET", []int{404, 200}, RetryPolicy{}, 1, 0, false},
+		{"skips non-idempotent methods", "POST", []int{503, 200}, RetryPolicy{}, 1, 0, false},
+		{"retries opted-in methods", "POST", []int{503, 200}, RetryPolicy{Methods: []string{"POST"}}, 2, 1, true},
+		{"status rule adds a status", "GET", []int{404, 200}, RetryPolicy{Statuses: map[int]RetryRule{404: {}}}, 2, 1, true},
+		{"status rule caps attempts", "GET", []int{500}, RetryPolicy{MaxAttempts: 5, Statuses: map[int]RetryRule{500: {MaxAttempts: 2}}}, 2, 1, false},
+		{"status rule disables retries", "GET", []int{503, 200}, RetryPolicy{Statuses: map[int]RetryRule{503: {Never: true}}}, 1, 0, false},
+		{"decide overrides", "GET", []int{503, 200}, RetryPolicy{Decide: func(*Response, error) RetryDecision { return RetryNever }}, 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&requests, 1))
+				if n > len(tt.statuses) {
+					n = len(tt.statuses)
+				}
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer ts.Close()
+
+			policy := tt.policy
+			policy.BaseDelay = time.Millisecond
+			policy.Jitter = -1
+			c := NewCollector(RetryWith(&policy))
+			var attempts []int
+			c.OnRetry(func(a *RetryAttempt) {
+				attempts = append(attempts, a.Attempt)
+			})
+			var err error
+			if tt.method == "POST" {
+				err = c.Post(ts.URL, map[string]string{"a": "b"})
+			} else {
+				err = c.Visit(ts.URL)
+			}
+			if (err == nil) != tt.ok {
+				t.Fatalf("expected success=%v, got %v", tt.ok, err)
+			}
+			if requests != tt.requests || len(attempts) != tt.retries {
+				t.Fatalf("expected %d requests and %d retries, got %d and %v", tt.requests, tt.retries, requests, attempts)
+			}
+		})
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewCollector(RetryWith(&RetryPolicy{BaseDelay: time.Millisecond, MaxRetryAfter: 30 * time.Millisecond}))
+	var delay time.Duration
+	c.OnRetry(func(a *RetryAttempt) {
+		delay = a.Delay
+	})
+	if err := c.Visit(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if delay != 30*time.Millisecond {
+		t.Fatalf("expected Retry-After clamped to MaxRetryAfter, got %v", delay)
+	}
+}
+
+func TestHedgePolicy(t *testing.T) {
+	h := &HedgePolicy{Percentile: 0.5, Delay: time.Second, MinDelay: time.Millisecond, MinSamples: 4, Window: 4, Domains: []string{"Example.com"}}
+	h.Init()
+	tests := []struct {
+		method  string
+		url     string
+		body    io.Reader
+		applies bool
+	}{
+		{"GET", "http://example.com/", nil, true},
+		{"HEAD", "http://www.example.com/", nil, true},
+		{"GET", "http://example.org/", nil, false},
+		{"POST", "http://example.com/", nil, false},
+		{"GET", "http://example.com/", bytes.NewReader([]byte("x")), false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.url, tt.body)
+		if got := h.applies(req); got != tt.applies {
+			t.Errorf("%s %s: expected applies=%v", tt.method, tt.url, tt.applies)
+		}
+	}
+
+	for _, ms := range []int{40, 10, 30} {
+		h.observe("example.com", time.Duration(ms)*time.Millisecond)
+	}
+	if got := h.threshold("example.com"); got != time.Second {
+		t.Fatalf("expected the fixed delay below MinSamples, got %v", got)
+	}
+	h.observe("example.com", 20*time.Millisecond)
+	if got := h.threshold("example.com"); got != 20*time.Millisecond {
+		t.Fatalf("expected the median latency, got %v", got)
+	}
+	h.observe("example.com", 100*time.Millisecond)
+	h.observe("example.com", 100*time.Millisecond)
+	if got := h.threshold("example.com"); got != 30*time.Millisecond {
+		t.Fatalf("expected the window to drop the oldest samples, got %v", got)
+	}
+}
+
+func TestHedgedRequestUsesFasterResponse(t *testing.T) {
+	var requests int32
+	canceled := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			<-r.Context().Done()
+			close(canceled)
+			return
+		}
+		w.Write([]byte("hedged"))
+	}))
+	defer ts.Close()
+
+	c := NewCollector(HedgeRequests(&HedgePolicy{Delay: 20 * time.Millisecond}))
+	var body string
+	c.OnResponse(func(r *Response) {
+		body = string(r.Body)
+	})
+	if err := c.Visit(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if body != "hedged" || requests != 2 {
+		t.Fatalf("expected the hedged response after 2 requests, got %q after %d", body, requests)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the slower request to be canceled")
+	}
+}
@@ -0,0 +1,253 @@
+package colly
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+func (c *Collector) SeedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		result := &seedResult{Rejected: make([]seedRejection, 0)}
+		scanner := bufio.NewScanner(r.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		var lines []int
+		var pending []<-chan error
+		line := 0
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			seed := &SeedRequest{}
+			if err := json.Unmarshal(data, seed); err != nil {
+				result.Rejected = append(result.Rejected, seedRejection{Line: line, Error: err.Error()})
+				continue
+			}
+			done, err := c.enqueueSeed(r.Context(), seed)
+			if err != nil {
+				result.Rejected = append(result.Rejected, seedRejection{Line: line, Error: err.Error()})
+				continue
+			}
+			lines = append(lines, line)
+			pending = append(pending, done)
+		}
+		for i, done := range pending {
+			if err := <-done; err != nil {
+				result.Rejected = append(result.Rejected, seedRejection{Line: lines[i], Error: err.Error()})
+				continue
+			}
+			result.Accepted++
+		}
+		sort.Slice(result.Rejected, func(i, j int) bool {
+			return result.Rejected[i].Line < result.Rejected[j].Line
+		})
+		status := http.StatusOK
+		if err := scanner.Err(); err != nil {
+			result.Error = err.Error()
+			status = http.StatusBadRequest
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result)
+	})
+}
+
+func (c *Collector) ServeSeeds(addr string) error {
+	return c.ServeSeedsContext(context.Background(), addr)
+}
+
+func (c *Collector) ServeSeedsContext(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:        addr,
+		Handler:     c.SeedHandler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			srv.Shutdown(context.Background())
+		case <-done:
+		}
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (c *Collector) EnqueueSeed(seed *SeedRequest) error {
+	_, err := c.enqueueSeed(context.Background(), seed)
+	return err
+}
+
+func (c *Collector) enqueueSeed(ctx context.Context, seed *SeedRequest) (<-chan error, error) {
+	if seed.Method == "" {
+		seed.Method = http.MethodGet
+	}
+	seed.Method = strings.ToUpper(seed.Method)
+	switch seed.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return nil, fmt.Errorf("%w: unsupported method %q", ErrInvalidSeed, seed.Method)
+	}
+	u, err := url.Parse(seed.URL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSeed, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %q is not an absolute http(s) URL", ErrInvalidSeed, seed.URL)
+	}
+	if seed.Depth < 1 {
+		seed.Depth = 1
+	}
+	c.lock.Lock()
+	if c.seeds == nil {
+		c.seeds = newSeedQueue()
+	}
+	q := c.seeds
+	c.lock.Unlock()
+	c.wg.Add(1)
+	done := make(chan error, 1)
+	if q.push(queuedSeed{seed: seed, ctx: ctx, done: done}) {
+		go c.dispatchSeeds(q)
+	}
+	return done, nil
+}
+
+func (c *Collector) dispatchSeeds(q *seedQueue) {
+	for {
+		item, ok := q.pop()
+		if !ok {
+			return
+		}
+		item.done <- c.dispatchSeed(item)
+		c.wg.Done()
+	}
+}
+
+func (c *Collector) dispatchSeed(item queuedSeed) error {
+	if err := item.ctx.Err(); err != nil {
+		return err
+	}
+	seed := item.seed
+	ctx := NewContext()
+	for k, v := range seed.Ctx {
+		ctx.Put(k, v)
+	}
+	var hdr http.Header
+	if len(seed.Headers) > 0 {
+		hdr = http.Header{}
+		for k, v := range seed.Headers {
+			hdr.Set(k, v)
+		}
+	}
+	var body io.Reader
+	if seed.Body != "" {
+		body = strings.NewReader(seed.Body)
+	}
+	return c.scrape(seed.URL, seed.Method, seed.Depth, body, ctx, hdr, true)
+}
+
+type SeedRequest struct {
+	URL      string            `json:"url"`
+	Method   string            `json:"method,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Ctx      map[string]string `json:"ctx,omitempty"`
+	Depth    int               `json:"depth,omitempty"`
+	Priority int               `json:"priority,omitempty"`
+}
+
+type seedResult struct {
+	Accepted int             `json:"accepted"`
+	Rejected []seedRejection `json:"rejected"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type seedRejection struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type queuedSeed struct {
+	seed *SeedRequest
+	seq  uint64
+	ctx  context.Context
+	done chan<- error
+}
+
+type seedHeap []queuedSeed
+
+func (h seedHeap) Len() int { return len(h) }
+
+func (h seedHeap) Less(i, j int) bool {
+	if h[i].seed.Priority != h[j].seed.Priority {
+		return h[i].seed.Priority > h[j].seed.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h seedHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *seedHeap) Push(x interface{}) { *h = append(*h, x.(queuedSeed)) }
+
+func (h *seedHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+type seedQueue struct {
+	items   seedHeap
+	seq     uint64
+	running bool
+	lock    *sync.Mutex
+}
+
+func newSeedQueue() *seedQueue {
+	return &seedQueue{lock: &sync.Mutex{}}
+}
+
+func (q *seedQueue) push(item queuedSeed) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.seq++
+	item.seq = q.seq
+	heap.Push(&q.items, item)
+	if q.running {
+		return false
+	}
+	q.running = true
+	return true
+}
+
+func (q *seedQueue) pop() (queuedSeed, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.items.Len() == 0 {
+		q.running = false
+		return queuedSeed{}, false
+	}
+	return heap.Pop(&q.items).(queuedSeed), true
+}
@@ -0,0 +1,113 @@
+package colly
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+type spilledBody struct {
+	prefix   []byte
+	file     *os.File
+	size     int64
+	quota    *diskQuota
+	reserved int64
+}
+
+func (s *spilledBody) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	if off < int64(len(s.prefix)) {
+		n = copy(p, s.prefix[off:])
+		if n == len(p) {
+			return n, nil
+		}
+	}
+	if s.file == nil {
+		return n, io.EOF
+	}
+	m, err := s.file.ReadAt(p[n:], off+int64(n)-int64(len(s.prefix)))
+	return n + m, err
+}
+
+func (s *spilledBody) close() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+		s.file = nil
+	}
+	if s.quota != nil {
+		s.quota.release(s.reserved)
+		s.reserved = 0
+	}
+}
+
+type spillBody struct {
+	body      io.ReadCloser
+	spill     *spilledBody
+	threshold int64
+	limit     int64
+	collector *Collector
+	done      bool
+}
+
+func (b *spillBody) Read(p []byte) (int, error) {
+	remaining := b.threshold - int64(len(b.spill.prefix))
+	if remaining <= 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+		b.done = true
+		f, err := os.CreateTemp(b.collector.tempDir(), "colly-body-*")
+		if err != nil {
+			return 0, err
+		}
+		b.spill.file = f
+		var w io.Writer = f
+		if q := b.collector.diskQuota; q != nil {
+			b.spill.quota = q
+			w = &quotaWriter{w: f, quota: q, collector: b.collector, reserved: &b.spill.reserved}
+		}
+		var src io.Reader = b.body
+		if b.limit > 0 {
+			src = io.LimitReader(b.body, b.limit-int64(len(b.spill.prefix)))
+		}
+		n, err := copyBuffered(w, src)
+		if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			b.spill.close()
+		}
+		b.spill.size = n
+		return 0, io.EOF
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.body.Read(p)
+	b.spill.prefix = append(b.spill.prefix, p[:n]...)
+	return n, err
+}
+
+func (b *spillBody) Close() error {
+	return b.body.Close()
+}
+
+func (r *Response) BodyReader() io.ReadSeeker {
+	if r.Request != nil && r.Request.collector != nil {
+		if v, ok := r.Request.collector.requestStates.Load(r.Request); ok {
+			if spill := v.(*requestState).spill; spill != nil && spill.file != nil {
+				return io.NewSectionReader(spill, 0, int64(len(spill.prefix))+spill.size)
+			}
+		}
+	}
+	return bytes.NewReader(r.Body)
+}
+
+func (r *Response) Spilled() bool {
+	if r.Request == nil || r.Request.collector == nil {
+		return false
+	}
+	v, ok := r.Request.collector.requestStates.Load(r.Request)
+	return ok && v.(*requestState).spill != nil && v.(*requestState).spill.file != nil
+}
@@ -0,0 +1,135 @@
+package colly
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+type StreamedResponse struct {
+	StatusCode int
+	Headers    *http.Header
+	Body       io.Reader
+	Request    *Request
+	Ctx        *Context
+}
+
+type streamingBody struct {
+	body      io.ReadCloser
+	resp      *StreamedResponse
+	callbacks []ResponseStreamCallback
+	done      bool
+}
+
+func (c *Collector) shouldStream(req *http.Request, res *http.Response) bool {
+	if len(c.streamCallbacks) == 0 || req.Method == http.MethodHead {
+		return false
+	}
+	if _, ok := req.Context().Value(requestContextKey).(*Request); !ok {
+		return false
+	}
+	if strings.HasSuffix(strings.ToLower(req.URL.Path), ".xml.gz") {
+		return false
+	}
+	return c.streamPredicate == nil || c.streamPredicate(res.StatusCode, res.Header)
+}
+
+func (c *Collector) streamResponse(req *http.Request, res *http.Response) (*http.Response, error) {
+	request := req.Context().Value(requestContextKey).(*Request)
+	body, err := decodedBody(res)
+	if err != nil {
+		return nil, err
+	}
+	if state := c.requestState(req); state != nil {
+		state.streamed = true
+	}
+	c.lock.RLock()
+	callbacks := c.streamCallbacks
+	c.lock.RUnlock()
+	res.Body = &streamingBody{
+		body: body,
+		resp: &StreamedResponse{
+			StatusCode: res.StatusCode,
+			Headers:    &res.Header,
+			Request:    request,
+			Ctx:        request.Ctx,
+		},
+		callbacks: callbacks,
+	}
+	res.ContentLength = -1
+	return res, nil
+}
+
+func (b *streamingBody) Read(p []byte) (int, error) {
+	if b.done {
+		return 0, io.EOF
+	}
+	b.done = true
+	if len(b.callbacks) == 1 {
+		b.resp.Body = b.body
+		if err := b.callbacks[0](b.resp); err != nil {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	writers := make([]io.Writer, len(b.callbacks))
+	pipes := make([]*io.PipeWriter, len(b.callbacks))
+	errs := make(chan error, len(b.callbacks))
+	for i, f := range b.callbacks {
+		pr, pw := io.Pipe()
+		pipes[i] = pw
+		writers[i] = &ignoreClosedWriter{w: pw}
+		resp := *b.resp
+		resp.Body = pr
+		go func(f ResponseStreamCallback, resp *StreamedResponse, pr *io.PipeReader) {
+			err := f(resp)
+			pr.Close()
+			errs <- err
+		}(f, &resp, pr)
+	}
+	_, copyErr := copyBuffered(io.MultiWriter(writers...), b.body)
+	for _, pw := range pipes {
+		pw.CloseWithError(copyErr)
+	}
+	var err error
+	for range b.callbacks {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+	if err == nil {
+		err = copyErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, io.EOF
+}
+
+func (b *streamingBody) Close() error {
+	return b.body.Close()
+}
+
+type ignoreClosedWriter struct {
+	w      io.Writer
+	closed bool
+}
+
+func (w *ignoreClosedWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return len(p), nil
+	}
+	if _, err := w.w.Write(p); err != nil {
+		w.closed = true
+	}
+	return len(p), nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func limitReadCloser(rc io.ReadCloser, n int64) io.ReadCloser {
+	return &readCloser{Reader: io.LimitReader(rc, n), Closer: rc}
+}
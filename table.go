@@ -0,0 +1,195 @@
+package colly
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+type tableSpan struct {
+	text string
+	rows int
+}
+
+func TableRecords(table *goquery.Selection) []map[string]string {
+	grid, headerRows := tableGrid(table.First())
+	headers := tableHeaders(grid[:headerRows])
+	records := make([]map[string]string, 0, len(grid)-headerRows)
+	for _, row := range grid[headerRows:] {
+		record := make(map[string]string, len(row))
+		for i, text := range row {
+			if i < len(headers) {
+				record[headers[i]] = text
+			} else {
+				record[strconv.Itoa(i)] = text
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func UnmarshalTable(v interface{}, table *goquery.Selection) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.New("Invalid type: table records require a pointer to a slice")
+	}
+	sv := rv.Elem()
+	et := sv.Type().Elem()
+	isPtr := et.Kind() == reflect.Ptr
+	if isPtr {
+		et = et.Elem()
+	}
+	if et.Kind() != reflect.Struct {
+		return errors.New("Invalid slice type: " + et.String())
+	}
+	for _, record := range TableRecords(table) {
+		item := reflect.New(et)
+		for i := 0; i < et.NumField(); i++ {
+			f := et.Field(i)
+			name := f.Tag.Get("table")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			val, ok := record[name]
+			if !ok {
+				continue
+			}
+			if err := setTableField(item.Elem().Field(i), val); err != nil {
+				return fmt.Errorf("column %q: %w", name, err)
+			}
+		}
+		if isPtr {
+			sv.Set(reflect.Append(sv, item))
+		} else {
+			sv.Set(reflect.Append(sv, item.Elem()))
+		}
+	}
+	return nil
+}
+
+func (h *HTMLElement) TableRecords() []map[string]string {
+	return TableRecords(h.DOM)
+}
+
+func (h *HTMLElement) UnmarshalTable(v interface{}) error {
+	return UnmarshalTable(v, h.DOM)
+}
+
+func tableGrid(table *goquery.Selection) ([][]string, int) {
+	var grid [][]string
+	headerRows := 0
+	inHeader := true
+	pending := make(map[int]*tableSpan)
+	table.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+		if !tr.Closest("table").IsSelection(table) {
+			return
+		}
+		var row []string
+		col := 0
+		fillPending := func() {
+			for span, ok := pending[col]; ok; span, ok = pending[col] {
+				row = append(row, span.text)
+				if span.rows--; span.rows == 0 {
+					delete(pending, col)
+				}
+				col++
+			}
+		}
+		allTH := true
+		tr.ChildrenFiltered("td, th").Each(func(_ int, cell *goquery.Selection) {
+			fillPending()
+			if goquery.NodeName(cell) != "th" {
+				allTH = false
+			}
+			text := strings.Join(strings.Fields(cell.Text()), " ")
+			colspan := tableSpanAttr(cell, "colspan")
+			rowspan := tableSpanAttr(cell, "rowspan")
+			for i := 0; i < colspan; i++ {
+				row = append(row, text)
+				if rowspan > 1 {
+					pending[col] = &tableSpan{text: text, rows: rowspan - 1}
+				}
+				col++
+			}
+		})
+		fillPending()
+		if inHeader && (tr.ParentsFiltered("thead").Length() > 0 || (allTH && len(row) > 0)) {
+			headerRows++
+		} else {
+			inHeader = false
+		}
+		grid = append(grid, row)
+	})
+	return grid, headerRows
+}
+
+func tableSpanAttr(cell *goquery.Selection, name string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(cell.AttrOr(name, "1")))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+func tableHeaders(rows [][]string) []string {
+	var headers []string
+	for _, row := range rows {
+		for i, text := range row {
+			if i >= len(headers) {
+				headers = append(headers, "")
+			}
+			if text != "" && !strings.HasSuffix(headers[i], text) {
+				headers[i] = strings.TrimSpace(headers[i] + " " + text)
+			}
+		}
+	}
+	seen := make(map[string]int, len(headers))
+	for i, h := range headers {
+		if h == "" {
+			h = strconv.Itoa(i)
+		}
+		if seen[h]++; seen[h] > 1 {
+			h = fmt.Sprintf("%s_%d", h, seen[h])
+		}
+		headers[i] = h
+	}
+	return headers
+}
+
+func setTableField(f reflect.Value, val string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(val)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.ReplaceAll(val, ",", ""), 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.ReplaceAll(val, ",", ""), 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.ReplaceAll(val, ",", ""), 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	case reflect.Bool:
+		f.SetBool(isYesString(val))
+	default:
+		return errors.New("Invalid type: " + f.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package colly
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"strings"
+	"sync"
+	"time"
+)
+
+type AutoThrottleConfig struct {
+	StartDelay        time.Duration
+	MinDelay          time.Duration
+	MaxDelay          time.Duration
+	TargetConcurrency float64
+	ErrorBackoff      float64
+	hosts             map[string]*throttleState
+	lock              *sync.Mutex
+}
+
+type throttleState struct {
+	delay time.Duration
+	next  time.Time
+}
+
+func (a *AutoThrottleConfig) Init() {
+	if a.StartDelay == 0 {
+		a.StartDelay = time.Second
+	}
+	if a.MaxDelay == 0 {
+		a.MaxDelay = time.Minute
+	}
+	if a.TargetConcurrency <= 0 {
+		a.TargetConcurrency = 1
+	}
+	if a.ErrorBackoff <= 1 {
+		a.ErrorBackoff = 2
+	}
+	a.hosts = make(map[string]*throttleState)
+	a.lock = &sync.Mutex{}
+}
+
+func (c *Collector) EnableAutoThrottle(config *AutoThrottleConfig) {
+	if config == nil {
+		config = &AutoThrottleConfig{}
+	}
+	config.Init()
+	c.autoThrottle = config
+}
+
+func (c *Collector) ThrottleDelay(host string) time.Duration {
+	if c.autoThrottle == nil {
+		return 0
+	}
+	c.autoThrottle.lock.Lock()
+	defer c.autoThrottle.lock.Unlock()
+	return c.autoThrottle.host(host).delay
+}
+
+func (a *AutoThrottleConfig) host(host string) *throttleState {
+	host = strings.ToLower(host)
+	st, ok := a.hosts[host]
+	if !ok {
+		st = &throttleState{delay: a.clamp(a.StartDelay)}
+		a.hosts[host] = st
+	}
+	return st
+}
+
+func (a *AutoThrottleConfig) clamp(d time.Duration) time.Duration {
+	if d < a.MinDelay {
+		return a.MinDelay
+	}
+	if d > a.MaxDelay {
+		return a.MaxDelay
+	}
+	return d
+}
+
+func (a *AutoThrottleConfig) wait(ctx context.Context, host string) error {
+	a.lock.Lock()
+	st := a.host(host)
+	now := time.Now()
+	start := st.next
+	if start.Before(now) {
+		start = now
+	}
+	st.next = start.Add(st.delay)
+	a.lock.Unlock()
+	return sleepContext(ctx, time.Until(start))
+}
+
+func (a *AutoThrottleConfig) observe(host string, latency time.Duration, r *Response, err error) {
+	if err != nil && !IsRetryableError(err) {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	st := a.host(host)
+	target := time.Duration(float64(latency) / a.TargetConcurrency)
+	if err != nil || (r != nil && (r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500)) {
+		backoff := time.Duration(float64(st.delay) * a.ErrorBackoff)
+		if backoff < target {
+			backoff = target
+		}
+		st.delay = a.clamp(backoff)
+		return
+	}
+	delay := (st.delay + target) / 2
+	if r != nil && r.StatusCode >= 400 && delay < st.delay {
+		delay = st.delay
+	}
+	st.delay = a.clamp(delay)
+}
+
+type bandwidthLimiter struct {
+	global *byteBucket
+	rules  []hostBandwidthRule
+	hosts  map[string]*byteBucket
+	lock   *sync.Mutex
+}
+
+type hostBandwidthRule struct {
+	glob string
+	rate int64
+}
+
+type byteBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	lock   sync.Mutex
+}
+
+type throttledBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	buckets []*byteBucket
+}
+
+func (c *Collector) SetBandwidthLimit(bytesPerSecond int64) {
+	l := c.bandwidthLimiter()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if bytesPerSecond <= 0 {
+		l.global = nil
+		return
+	}
+	l.global = newByteBucket(bytesPerSecond)
+}
+
+func (c *Collector) SetHostBandwidthLimit(glob string, bytesPerSecond int64) {
+	l := c.bandwidthLimiter()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	glob = strings.ToLower(glob)
+	for i, r := range l.rules {
+		if r.glob == glob {
+			l.rules[i].rate = bytesPerSecond
+			l.hosts = make(map[string]*byteBucket)
+			return
+		}
+	}
+	l.rules = append(l.rules, hostBandwidthRule{glob: glob, rate: bytesPerSecond})
+}
+
+func (c *Collector) bandwidthLimiter() *bandwidthLimiter {
+	if c.bandwidth == nil {
+		c.bandwidth = &bandwidthLimiter{
+			hosts: make(map[string]*byteBucket),
+			lock:  &sync.Mutex{},
+		}
+	}
+	return c.bandwidth
+}
+
+func (l *bandwidthLimiter) wrap(ctx context.Context, host string, body io.ReadCloser) io.ReadCloser {
+	host = strings.ToLower(host)
+	l.lock.Lock()
+	var buckets []*byteBucket
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	if b, ok := l.hosts[host]; ok {
+		buckets = append(buckets, b)
+	} else {
+		for _, r := range l.rules {
+			if matchHostGlob(r.glob, host) && r.rate > 0 {
+				b := newByteBucket(r.rate)
+				l.hosts[host] = b
+				buckets = append(buckets, b)
+				break
+			}
+		}
+	}
+	l.lock.Unlock()
+	if len(buckets) == 0 {
+		return body
+	}
+	return &throttledBody{ReadCloser: body, ctx: ctx, buckets: buckets}
+}
+
+func newByteBucket(bytesPerSecond int64) *byteBucket {
+	burst := float64(bytesPerSecond)
+	if burst < 1024 {
+		burst = 1024
+	}
+	return &byteBucket{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *byteBucket) reserve(n int) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (t *throttledBody) Read(p []byte) (int, error) {
+	for _, b := range t.buckets {
+		if len(p) > int(b.burst) {
+			p = p[:int(b.burst)]
+		}
+	}
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		var wait time.Duration
+		for _, b := range t.buckets {
+			if d := b.reserve(n); d > wait {
+				wait = d
+			}
+		}
+		if sleepErr := sleepContext(t.ctx, wait); sleepErr != nil && err == nil {
+			err = sleepErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,75 @@
+package colly
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+func (c *Collector) shouldTidy(resp *Response) bool {
+	if !c.tidyHTML {
+		return false
+	}
+	mediatype, _, _ := strings.Cut(strings.ToLower(resp.Headers.Get("Content-Type")), ";")
+	if mediatype = strings.TrimSpace(mediatype); mediatype != "text/html" && mediatype != "application/xhtml+xml" {
+		return false
+	}
+	if len(c.tidyDomains) == 0 {
+		return true
+	}
+	return hostMatches(c.tidyDomains, strings.ToLower(resp.Request.URL.Hostname()))
+}
+
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+func RepairHTML(body []byte) []byte {
+	z := html.NewTokenizer(bytes.NewReader(body))
+	out := getBuffer()
+	defer putBuffer(out)
+	out.Grow(len(body) + len(body)/16)
+	var open []string
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				out.WriteString("</" + open[i] + ">")
+			}
+			return bytes.Clone(out.Bytes())
+		case html.StartTagToken:
+			t := z.Token()
+			out.WriteString(t.String())
+			if !voidElements[t.Data] {
+				open = append(open, t.Data)
+			}
+		case html.EndTagToken:
+			t := z.Token()
+			idx := -1
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == t.Data {
+					idx = i
+					break
+				}
+			}
+			if idx == -1 {
+				continue
+			}
+			for i := len(open) - 1; i >= idx; i-- {
+				out.WriteString("</" + open[i] + ">")
+			}
+			open = open[:idx]
+		case html.TextToken:
+			if n := len(open); n > 0 && (open[n-1] == "script" || open[n-1] == "style") {
+				out.Write(z.Raw())
+				continue
+			}
+			out.WriteString(z.Token().String())
+		default:
+			out.WriteString(z.Token().String())
+		}
+	}
+}
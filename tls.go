@@ -0,0 +1,579 @@
+package colly
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2/profiles"
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+func (c *Collector) SetClientCertificate(certFile, keyFile string, domains ...string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	c.AddClientCertificate(cert, domains...)
+	return nil
+}
+
+func (c *Collector) AddClientCertificate(cert tls.Certificate, domains ...string) {
+	config := c.tlsConfig()
+	if config == nil {
+		return
+	}
+	c.lock.Lock()
+	c.clientCerts = append(c.clientCerts, &clientCertificate{cert: &cert, domains: domains})
+	c.lock.Unlock()
+	config.GetClientCertificate = c.clientCertificate
+	c.socksLock.Lock()
+	c.socksTransports = nil
+	c.socksLock.Unlock()
+}
+
+func (c *Collector) clientCertificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	var host string
+	if ctx := info.Context(); ctx != nil {
+		host, _ = ctx.Value(tlsHostContextKey).(string)
+	}
+	return c.clientCertificateFor(host)
+}
+
+func (c *Collector) clientCertificateFor(host string) (*tls.Certificate, error) {
+	host = strings.ToLower(host)
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, cc := range c.clientCerts {
+		if len(cc.domains) > 0 && host != "" && hostMatches(cc.domains, host) {
+			return cc.cert, nil
+		}
+	}
+	for _, cc := range c.clientCerts {
+		if len(cc.domains) == 0 {
+			return cc.cert, nil
+		}
+	}
+	return &tls.Certificate{}, nil
+}
+
+func (c *Collector) SetRootCAs(pool *x509.CertPool) {
+	if config := c.tlsConfig(); config != nil {
+		config.RootCAs = pool
+	}
+}
+
+func (c *Collector) LoadCABundle(pemFile string) error {
+	data, err := os.ReadFile(pemFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return ErrInvalidCABundle
+	}
+	c.SetRootCAs(pool)
+	return nil
+}
+
+func (c *Collector) PinCertificates(domain string, pins ...string) {
+	config := c.tlsConfig()
+	if config == nil {
+		return
+	}
+	c.lock.Lock()
+	if c.certificatePins == nil {
+		c.certificatePins = &pinSet{pins: make(map[string][]string), lock: &sync.RWMutex{}}
+	}
+	set := c.certificatePins
+	c.lock.Unlock()
+	set.add(strings.ToLower(domain), pins)
+	config.VerifyConnection = set.verify
+	c.socksLock.Lock()
+	c.socksTransports = nil
+	c.socksLock.Unlock()
+}
+
+func (c *Collector) verifyCertificatePins(cs tls.ConnectionState) error {
+	c.lock.RLock()
+	set := c.certificatePins
+	c.lock.RUnlock()
+	if set == nil {
+		return nil
+	}
+	return set.verify(cs)
+}
+
+type pinSet struct {
+	pins map[string][]string
+	lock *sync.RWMutex
+}
+
+func (s *pinSet) add(domain string, pins []string) {
+	s.lock.Lock()
+	s.pins[domain] = append(s.pins[domain], pins...)
+	s.lock.Unlock()
+}
+
+func (s *pinSet) verify(cs tls.ConnectionState) error {
+	host := strings.ToLower(cs.ServerName)
+	s.lock.RLock()
+	var pins []string
+	for domain, p := range s.pins {
+		if domain == "" || hostMatches([]string{domain}, host) || host == "" && net.ParseIP(domain) != nil {
+			pins = append(pins, p...)
+		}
+	}
+	s.lock.RUnlock()
+	if len(pins) == 0 {
+		return nil
+	}
+	for _, cert := range cs.PeerCertificates {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		pin := base64.StdEncoding.EncodeToString(sum[:])
+		for _, p := range pins {
+			if strings.TrimPrefix(p, "sha256/") == pin {
+				return nil
+			}
+		}
+	}
+	return ErrCertificatePin
+}
+
+func (c *Collector) SetMinTLSVersion(version uint16) {
+	if config := c.tlsConfig(); config != nil {
+		config.MinVersion = version
+	}
+}
+
+func (c *Collector) InsecureSkipVerify(skip bool) {
+	if config := c.tlsConfig(); config != nil {
+		config.InsecureSkipVerify = skip
+	}
+}
+
+func (c *Collector) tlsConfig() *tls.Config {
+	t := c.httpTransport()
+	if t == nil {
+		return nil
+	}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	return t.TLSClientConfig
+}
+
+func (c *Collector) SetTLSFingerprint(hello utls.ClientHelloID) {
+	c.httpTransport()
+	var parent chainedTransport
+	base := c.baseTransport()
+	for {
+		w, ok := base.(chainedTransport)
+		if !ok {
+			break
+		}
+		parent, base = w, w.inner()
+	}
+	if t, ok := base.(*utlsTransport); ok {
+		t.lock.Lock()
+		t.hello = hello
+		t.lock.Unlock()
+		t.CloseIdleConnections()
+		return
+	}
+	inner, ok := base.(*http.Transport)
+	if !ok {
+		c.setOptionErr(fmt.Errorf("%w: %T", ErrFingerprintUnsupported, base))
+		return
+	}
+	h1 := inner.Clone()
+	t := &utlsTransport{
+		hello:      hello,
+		h1:         h1,
+		h2:         &http2.Transport{IdleConnTimeout: h1.IdleConnTimeout},
+		conns:      make(map[string]*http2.ClientConn),
+		protocols:  make(map[string]string),
+		dialing:    make(map[string]*utlsDial),
+		tunnels:    make(map[string]*http.Transport),
+		clientCert: c.clientCertificateFor,
+		lookup:     c.guardedLookup,
+		lock:       &sync.Mutex{},
+	}
+	h1.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return t.dial(ctx, nil, network, addr, true)
+	}
+	if parent == nil {
+		c.setBaseTransport(t)
+		return
+	}
+	parent.setInner(t)
+	if c.customDial() {
+		c.installDialer()
+	}
+}
+
+type utlsTransport struct {
+	hello      utls.ClientHelloID
+	h1         *http.Transport
+	h2         *http2.Transport
+	conns      map[string]*http2.ClientConn
+	protocols  map[string]string
+	dialing    map[string]*utlsDial
+	tunnels    map[string]*http.Transport
+	clientCert func(host string) (*tls.Certificate, error)
+	lookup     func(ctx context.Context, host string) ([]net.IP, error)
+	lock       *sync.Mutex
+}
+
+func (t *utlsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var proxyURL *url.URL
+	if t.h1.Proxy != nil {
+		u, err := t.h1.Proxy(req)
+		if err != nil {
+			return nil, err
+		}
+		if usage, ok := req.Context().Value(proxyUsageContextKey).(*proxyUsage); ok {
+			usage.resolved = true
+			usage.proxy = u
+		}
+		proxyURL = u
+	}
+	if req.URL.Scheme != "https" {
+		if isSOCKSProxy(proxyURL) {
+			return t.transportFor(proxyURL, "").RoundTrip(req)
+		}
+		return t.h1.RoundTrip(req)
+	}
+	if proxyURL != nil && proxyURL.Scheme != "http" && proxyURL.Scheme != "https" && !isSOCKSProxy(proxyURL) {
+		return t.h1.RoundTrip(req)
+	}
+	profile := ""
+	if p, ok := req.Context().Value(profileContextKey).(*profiles.Profile); ok && p.TLS.Client != "" {
+		profile = p.TLS.Str()
+	}
+	h1 := t.transportFor(proxyURL, profile)
+	if h1.Protocols != nil && !h1.Protocols.HTTP2() {
+		return h1.RoundTrip(req)
+	}
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), "443")
+	}
+	connKey := addr
+	if proxyURL != nil {
+		connKey += "|" + proxyURL.String()
+	}
+	if profile != "" {
+		connKey += "|" + profile
+	}
+	for {
+		t.lock.Lock()
+		protocol := t.protocols[connKey]
+		cc := t.conns[connKey]
+		if protocol == "http/1.1" {
+			t.lock.Unlock()
+			return h1.RoundTrip(req)
+		}
+		if cc != nil && cc.CanTakeNewRequest() {
+			t.lock.Unlock()
+			return cc.RoundTrip(req)
+		}
+		if pending, ok := t.dialing[connKey]; ok {
+			t.lock.Unlock()
+			select {
+			case <-pending.done:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+			if pending.err != nil {
+				return nil, pending.err
+			}
+			continue
+		}
+		pending := &utlsDial{done: make(chan struct{})}
+		t.dialing[connKey] = pending
+		t.lock.Unlock()
+		cc, pending.err = t.connect(req.Context(), proxyURL, addr, connKey)
+		t.lock.Lock()
+		delete(t.dialing, connKey)
+		t.lock.Unlock()
+		close(pending.done)
+		if pending.err != nil {
+			return nil, pending.err
+		}
+		if cc == nil {
+			return h1.RoundTrip(req)
+		}
+		return cc.RoundTrip(req)
+	}
+}
+
+type utlsDial struct {
+	done chan struct{}
+	err  error
+}
+
+func (t *utlsTransport) connect(ctx context.Context, proxyURL *url.URL, addr, connKey string) (*http2.ClientConn, error) {
+	conn, err := t.dial(ctx, proxyURL, "tcp", addr, false)
+	if err != nil {
+		return nil, err
+	}
+	if conn.ConnectionState().NegotiatedProtocol != "h2" {
+		conn.Close()
+		t.lock.Lock()
+		t.protocols[connKey] = "http/1.1"
+		t.lock.Unlock()
+		return nil, nil
+	}
+	cc, err := t.h2.NewClientConn(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	t.lock.Lock()
+	t.protocols[connKey] = "h2"
+	t.conns[connKey] = cc
+	t.lock.Unlock()
+	return cc, nil
+}
+
+func (t *utlsTransport) transportFor(proxyURL *url.URL, profile string) *http.Transport {
+	if proxyURL == nil && profile == "" {
+		return t.h1
+	}
+	key := profile
+	if proxyURL != nil {
+		key = proxyURL.String() + "|" + profile
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if h1, ok := t.tunnels[key]; ok {
+		return h1
+	}
+	h1 := t.h1.Clone()
+	h1.Proxy = nil
+	if isSOCKSProxy(proxyURL) {
+		h1.DialContext = socksDialContext(proxyURL, t.dialRaw, t.lookup)
+	}
+	h1.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return t.dial(ctx, proxyURL, network, addr, true)
+	}
+	t.tunnels[key] = h1
+	return h1
+}
+
+func (t *utlsTransport) dialRaw(ctx context.Context, network, addr string) (net.Conn, error) {
+	return dialTransport(ctx, t.h1, network, addr)
+}
+
+func dialTransport(ctx context.Context, t *http.Transport, network, addr string) (net.Conn, error) {
+	if t.DialContext != nil {
+		return t.DialContext(ctx, network, addr)
+	}
+	return (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext(ctx, network, addr)
+}
+
+func dialProxy(ctx context.Context, t *http.Transport, proxyURL *url.URL, network string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := dialTransport(ctx, t, network, proxyAddr)
+	if err != nil || proxyURL.Scheme != "https" {
+		return conn, err
+	}
+	config := &tls.Config{ServerName: proxyURL.Hostname()}
+	if tc := t.TLSClientConfig; tc != nil {
+		config.RootCAs = tc.RootCAs
+		config.InsecureSkipVerify = tc.InsecureSkipVerify
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func proxyAuthorization(proxyURL *url.URL) string {
+	u := proxyURL.User
+	if u == nil {
+		return ""
+	}
+	password, _ := u.Password()
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password))
+}
+
+func dialTunnel(ctx context.Context, t *http.Transport, proxyURL *url.URL, network, addr string) (net.Conn, error) {
+	conn, err := dialProxy(ctx, t, proxyURL, network)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: t.ProxyConnectHeader.Clone(),
+	}
+	if connectReq.Header == nil {
+		connectReq.Header = http.Header{}
+	}
+	if auth := proxyAuthorization(proxyURL); auth != "" {
+		connectReq.Header.Set("Proxy-Authorization", auth)
+	}
+	if err := connectReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, connectReq)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s failed: %s", addr, res.Status)
+	}
+	if br.Buffered() > 0 {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s sent unexpected data", addr)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (t *utlsTransport) dial(ctx context.Context, proxyURL *url.URL, network, addr string, http1Only bool) (*utls.UConn, error) {
+	var raw net.Conn
+	var err error
+	if isSOCKSProxy(proxyURL) {
+		raw, err = socksDialContext(proxyURL, t.dialRaw, t.lookup)(ctx, network, addr)
+	} else if proxyURL != nil {
+		raw, err = dialTunnel(ctx, t.h1, proxyURL, network, addr)
+	} else {
+		raw, err = t.dialRaw(ctx, network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	config := &utls.Config{ServerName: host}
+	tc := t.h1.TLSClientConfig
+	if tc != nil {
+		config.InsecureSkipVerify = tc.InsecureSkipVerify
+		config.RootCAs = tc.RootCAs
+		config.MinVersion = tc.MinVersion
+		if tc.ServerName != "" {
+			config.ServerName = tc.ServerName
+		}
+	}
+	if t.clientCert != nil {
+		config.GetClientCertificate = func(*utls.CertificateRequestInfo) (*utls.Certificate, error) {
+			cert, err := t.clientCert(host)
+			if err != nil {
+				return nil, err
+			}
+			return &utls.Certificate{
+				Certificate:                 cert.Certificate,
+				PrivateKey:                  cert.PrivateKey,
+				OCSPStaple:                  cert.OCSPStaple,
+				SignedCertificateTimestamps: cert.SignedCertificateTimestamps,
+				Leaf:                        cert.Leaf,
+			}, nil
+		}
+	}
+	t.lock.Lock()
+	hello := t.hello
+	t.lock.Unlock()
+	if p, ok := ctx.Value(profileContextKey).(*profiles.Profile); ok && p.TLS.Client != "" {
+		hello = p.TLS
+	}
+	var conn *utls.UConn
+	if spec, err := utls.UTLSIdToSpec(hello); err == nil {
+		if http1Only {
+			for _, ext := range spec.Extensions {
+				if alpn, ok := ext.(*utls.ALPNExtension); ok {
+					alpn.AlpnProtocols = []string{"http/1.1"}
+				}
+			}
+		}
+		conn = utls.UClient(raw, config, utls.HelloCustom)
+		if err := conn.ApplyPreset(&spec); err != nil {
+			raw.Close()
+			return nil, err
+		}
+	} else {
+		if http1Only {
+			config.NextProtos = []string{"http/1.1"}
+		}
+		conn = utls.UClient(raw, config, hello)
+	}
+	if err := conn.HandshakeContext(ctx); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	if tc != nil && tc.VerifyConnection != nil {
+		cs := conn.ConnectionState()
+		err := tc.VerifyConnection(tls.ConnectionState{
+			Version:            cs.Version,
+			HandshakeComplete:  cs.HandshakeComplete,
+			CipherSuite:        cs.CipherSuite,
+			NegotiatedProtocol: cs.NegotiatedProtocol,
+			ServerName:         config.ServerName,
+			PeerCertificates:   cs.PeerCertificates,
+			VerifiedChains:     cs.VerifiedChains,
+		})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (t *utlsTransport) httpTransport() *http.Transport {
+	return t.h1
+}
+
+func (t *utlsTransport) CloseIdleConnections() {
+	t.h1.CloseIdleConnections()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, h1 := range t.tunnels {
+		h1.CloseIdleConnections()
+	}
+	for addr, cc := range t.conns {
+		if cc.State().StreamsActive == 0 {
+			cc.Close()
+			delete(t.conns, addr)
+		}
+	}
+}
+
+type clientCertificate struct {
+	cert    *tls.Certificate
+	domains []string
+}
@@ -0,0 +1,90 @@
+package colly
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTLSVerificationOptions(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	sum := sha256.Sum256(ts.Certificate().RawSubjectPublicKeyInfo)
+	pin := "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		setup  func(c *Collector)
+		ok     bool
+		target error
+	}{
+		{"unknown authority", func(c *Collector) {}, false, nil},
+		{"insecure skip verify", func(c *Collector) { c.InsecureSkipVerify(true) }, true, nil},
+		{"root CAs", func(c *Collector) { c.SetRootCAs(roots) }, true, nil},
+		{"CA bundle", func(c *Collector) {
+			if err := c.LoadCABundle(bundle); err != nil {
+				t.Fatal(err)
+			}
+		}, true, nil},
+		{"matching pin", func(c *Collector) {
+			c.SetRootCAs(roots)
+			c.PinCertificates("127.0.0.1", pin)
+		}, true, nil},
+		{"mismatched pin", func(c *Collector) {
+			c.SetRootCAs(roots)
+			c.PinCertificates("127.0.0.1", "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+		}, false, ErrCertificatePin},
+		{"mismatched pin is enforced with insecure skip verify", func(c *Collector) {
+			c.InsecureSkipVerify(true)
+			c.PinCertificates("", "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+		}, false, ErrCertificatePin},
+		{"pin for another domain", func(c *Collector) {
+			c.SetRootCAs(roots)
+			c.PinCertificates("other.example", "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+		}, true, nil},
+		{"minimum version above the server", func(c *Collector) {
+			c.SetRootCAs(roots)
+			c.SetMinTLSVersion(tls.VersionTLS13)
+		}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector()
+			tt.setup(c)
+			err := c.Visit(ts.URL)
+			if tt.ok != (err == nil) {
+				t.Fatalf("expected success=%v, got %v", tt.ok, err)
+			}
+			if tt.target != nil && !errors.Is(err, tt.target) {
+				t.Fatalf("expected %v, got %v", tt.target, err)
+			}
+		})
+	}
+}
+
+func TestLoadCABundleRejectsInvalidPEM(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewCollector().LoadCABundle(bundle); !errors.Is(err, ErrInvalidCABundle) {
+		t.Fatalf("expected ErrInvalidCABundle, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package colly
+
+import "sync"
+
+type TraceHop struct {
+	URL        string
+	StatusCode int
+	Trace      *HTTPTrace
+}
+
+type traceHops struct {
+	hops []*TraceHop
+	lock sync.Mutex
+}
+
+func (h *traceHops) add(hop *TraceHop) {
+	h.lock.Lock()
+	h.hops = append(h.hops, hop)
+	h.lock.Unlock()
+}
+
+func (s *requestState) traceHops() []*TraceHop {
+	if s.hops == nil {
+		return nil
+	}
+	s.hops.lock.Lock()
+	defer s.hops.lock.Unlock()
+	return append([]*TraceHop(nil), s.hops.hops...)
+}
+
+func (r *Response) TraceHops() []*TraceHop {
+	if r.traceHops != nil {
+		return append([]*TraceHop(nil), r.traceHops...)
+	}
+	if r.Request == nil || r.Request.collector == nil {
+		return nil
+	}
+	v, ok := r.Request.collector.requestStates.Load(r.Request)
+	if !ok {
+		return nil
+	}
+	return v.(*requestState).traceHops()
+}
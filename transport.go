@@ -0,0 +1,688 @@
+package colly
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"golang.org/x/net/http2"
+)
+
+func (c *Collector) keepAlivesDisabled(disabled bool) bool {
+	if c.keepAlives != nil {
+		return !*c.keepAlives
+	}
+	return disabled
+}
+
+func (c *Collector) ForceHTTP1() {
+	t := c.httpTransport()
+	if t == nil {
+		return
+	}
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	t.Protocols = protocols
+	t.ForceAttemptHTTP2 = false
+	if t.TLSClientConfig != nil {
+		t.TLSClientConfig.NextProtos = withoutH2(t.TLSClientConfig.NextProtos)
+	}
+}
+
+func withoutH2(protos []string) []string {
+	var out []string
+	for _, p := range protos {
+		if p != "h2" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (c *Collector) EnableH2C() {
+	if c.httpTransport() == nil {
+		c.setOptionErr(fmt.Errorf("%w: %T", ErrH2CUnsupported, c.baseTransport()))
+		return
+	}
+	if _, ok := c.baseTransport().(*h2cTransport); ok {
+		return
+	}
+	t := &h2cTransport{
+		next:      c.baseTransport(),
+		collector: c,
+		failed:    make(map[string]time.Time),
+		lock:      &sync.RWMutex{},
+	}
+	t.h2c = &http2.Transport{AllowHTTP: true, DialTLSContext: t.dial}
+	c.setBaseTransport(t)
+}
+
+func (c *Collector) SetHTTP2Config(config *http.HTTP2Config) {
+	t := c.httpTransport()
+	if t == nil {
+		return
+	}
+	t.HTTP2 = config
+	t.ForceAttemptHTTP2 = true
+}
+
+func (c *Collector) EnableHTTP3(domains ...string) {
+	if t, ok := c.baseTransport().(*http3Transport); ok {
+		t.lock.Lock()
+		t.domains = append(t.domains, domains...)
+		t.lock.Unlock()
+		return
+	}
+	h3 := &http3.Transport{}
+	t := &http3Transport{
+		h3:        h3,
+		next:      c.baseTransport(),
+		collector: c,
+		domains:   domains,
+		failed:    make(map[string]time.Time),
+		lock:      &sync.RWMutex{},
+	}
+	h3.Dial = t.dialer(c)
+	c.setBaseTransport(t)
+}
+
+func (c *Collector) SetMaxIdleConnsPerHost(n int) {
+	if t := c.httpTransport(); t != nil {
+		t.MaxIdleConnsPerHost = n
+		if t.MaxIdleConns > 0 && t.MaxIdleConns < n {
+			t.MaxIdleConns = n
+		}
+	}
+}
+
+func (c *Collector) SetIdleConnTimeout(d time.Duration) {
+	if t := c.httpTransport(); t != nil {
+		t.IdleConnTimeout = d
+	}
+}
+
+func (c *Collector) SetTLSHandshakeTimeout(d time.Duration) {
+	if t := c.httpTransport(); t != nil {
+		t.TLSHandshakeTimeout = d
+	}
+}
+
+func (c *Collector) SetResponseHeaderTimeout(d time.Duration) {
+	if t := c.httpTransport(); t != nil {
+		t.ResponseHeaderTimeout = d
+	}
+}
+
+func (c *Collector) SetKeepAlives(enabled bool) {
+	c.keepAlives = &enabled
+	if t := c.httpTransport(); t != nil {
+		t.DisableKeepAlives = !enabled
+	}
+}
+
+func (c *Collector) httpTransport() *http.Transport {
+	switch t := c.baseTransport().(type) {
+	case *http.Transport:
+		return t
+	case transportWrapper:
+		return t.httpTransport()
+	case nil:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		c.setBaseTransport(transport)
+		return transport
+	}
+	return nil
+}
+
+func (c *Collector) baseTransport() http.RoundTripper {
+	if t, ok := c.backend.Client.Transport.(*collectorTransport); ok {
+		return t.next
+	}
+	return c.backend.Client.Transport
+}
+
+func (c *Collector) setBaseTransport(transport http.RoundTripper) {
+	if t, ok := c.backend.Client.Transport.(*collectorTransport); ok {
+		t.next = transport
+	} else {
+		c.backend.Client.Transport = transport
+	}
+	c.socksLock.Lock()
+	c.socksTransports = nil
+	c.socksLock.Unlock()
+	if c.customDial() {
+		c.installDialer()
+	}
+}
+
+func (c *Collector) installTransport() *collectorTransport {
+	if t, ok := c.backend.Client.Transport.(*collectorTransport); ok {
+		return t
+	}
+	t := &collectorTransport{collector: c, next: c.backend.Client.Transport}
+	c.backend.Client.Transport = t
+	return t
+}
+
+type collectorTransport struct {
+	collector *Collector
+	next      http.RoundTripper
+}
+
+func (t *collectorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c := t.collectorFor(req)
+	state := c.requestState(req)
+	if state != nil && req.Response == nil {
+		state.resetRedirects()
+	}
+	var hop *TraceHop
+	if state != nil && state.hops != nil {
+		hop = &TraceHop{URL: req.URL.String(), Trace: &HTTPTrace{}}
+		req = hop.Trace.WithTrace(req)
+		state.hops.add(hop)
+	}
+	if len(c.clientCerts) > 0 {
+		req = req.WithContext(context.WithValue(req.Context(), tlsHostContextKey, req.URL.Hostname()))
+	}
+	if c.ssrf != nil && !c.cassette.replaying() {
+		if c.httpTransport() == nil {
+			return nil, fmt.Errorf("%w: %T", ErrUnguardedTransport, c.baseTransport())
+		}
+		ips, err := c.lookupIP(req.Context(), req.URL.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		if err := c.ssrf.check(req.URL.Hostname(), ips); err != nil {
+			return nil, err
+		}
+		req = req.WithContext(context.WithValue(req.Context(), ssrfTargetContextKey, req.URL.Hostname()))
+	}
+	req = c.applyDomainHeaders(req)
+	res, proxyURL, err := c.cassetteRoundTrip(next, req)
+	if proxyURL != "" && state != nil {
+		state.proxyURL = proxyURL
+	}
+	if err != nil {
+		return res, err
+	}
+	if hop != nil {
+		hop.StatusCode = res.StatusCode
+	}
+	if c.bandwidth != nil {
+		res.Body = c.bandwidth.wrap(req.Context(), req.URL.Hostname(), res.Body)
+	}
+	if !c.disableCompression && req.Method != http.MethodHead {
+		if res.Body, err = decodedBody(res); err != nil {
+			return nil, err
+		}
+	}
+	if c.shouldStream(req, res) {
+		return c.streamResponse(req, res)
+	}
+	if state != nil && c.spillThreshold > 0 && req.Method != http.MethodHead && !strings.HasSuffix(strings.ToLower(req.URL.Path), ".xml.gz") {
+		body, err := decodedBody(res)
+		if err != nil {
+			return nil, err
+		}
+		limit := int64(c.bodySizeFor(res.Header.Get("Content-Type")))
+		state.spill = &spilledBody{}
+		res.Body = &spillBody{body: body, spill: state.spill, threshold: c.spillThreshold, limit: limit, collector: c}
+		res.ContentLength = -1
+	}
+	if len(c.maxBodySizes()) > 0 {
+		if size := c.bodySizeFor(res.Header.Get("Content-Type")); size > 0 {
+			res.Body = limitReadCloser(res.Body, int64(size))
+		}
+	}
+	return res, nil
+}
+
+func (c *Collector) proxiedRoundTrip(next http.RoundTripper, req *http.Request) (*http.Response, string, error) {
+	usage := &proxyUsage{}
+	req = req.WithContext(context.WithValue(req.Context(), proxyUsageContextKey, usage))
+	start := time.Now()
+	res, err := c.roundTrip(next, req)
+	if usage.url != "" && !errors.Is(err, context.Canceled) {
+		reportErr := err
+		if err == nil && res.StatusCode == http.StatusProxyAuthRequired {
+			reportErr = errors.New(res.Status)
+		}
+		c.reportProxy(usage.url, time.Since(start), reportErr)
+	}
+	return res, usage.url, err
+}
+
+func (t *collectorTransport) collectorFor(req *http.Request) *Collector {
+	if r, ok := req.Context().Value(requestContextKey).(*Request); ok && r.collector != nil {
+		return r.collector
+	}
+	return t.collector
+}
+
+func decodedBody(res *http.Response) (io.ReadCloser, error) {
+	header := strings.ToLower(res.Header.Get("Content-Encoding"))
+	if res.Uncompressed || header == "" || res.ContentLength == 0 || res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified {
+		return res.Body, nil
+	}
+	encodings := strings.Split(header, ",")
+	for _, e := range encodings {
+		switch strings.TrimSpace(e) {
+		case "gzip", "x-gzip", "deflate", "br", "zstd", "identity":
+		default:
+			return res.Body, nil
+		}
+	}
+	var body io.Reader = res.Body
+	closers := decoderClosers{res.Body}
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch strings.TrimSpace(encodings[i]) {
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(body)
+		case "deflate":
+			body, err = deflateReader(body)
+		case "br":
+			body = brotli.NewReader(body)
+		case "zstd":
+			var d *zstd.Decoder
+			d, err = zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+			if err == nil {
+				rc := d.IOReadCloser()
+				closers = append(closers, rc)
+				body = rc
+			}
+		}
+		if err != nil {
+			closers.Close()
+			return nil, err
+		}
+	}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return &readCloser{Reader: body, Closer: closers}, nil
+}
+
+func deflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && len(header) < 2 {
+		return flate.NewReader(br), nil
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+type decoderClosers []io.Closer
+
+func (c decoderClosers) Close() error {
+	var err error
+	for i := len(c) - 1; i >= 0; i-- {
+		if e := c[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (c *Collector) shouldCompressRequest(method string, requestData io.Reader, hdr http.Header) bool {
+	if !c.compressRequests || requestData == nil || hdr.Get("Content-Encoding") != "" {
+		return false
+	}
+	switch method {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+	return false
+}
+
+func (c *Collector) compressRequestBody(requestData io.Reader, hdr http.Header) (io.Reader, error) {
+	data, err := io.ReadAll(requestData)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.compressMinSize {
+		return bytes.NewReader(data), nil
+	}
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	hdr.Set("Content-Encoding", "gzip")
+	hdr.Del("Content-Length")
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+const http3RetryAfter = 10 * time.Minute
+
+type http3Transport struct {
+	h3        *http3.Transport
+	next      http.RoundTripper
+	collector *Collector
+	domains   []string
+	failed    map[string]time.Time
+	udp       *quic.Transport
+	lock      *sync.RWMutex
+}
+
+func (t *http3Transport) dialer(c *Collector) func(context.Context, string, *tls.Config, *quic.Config) (*quic.Conn, error) {
+	return func(ctx context.Context, addr string, tlsConfig *tls.Config, config *quic.Config) (*quic.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := c.guardedLookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		portNum, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, err
+		}
+		udp, err := t.transport()
+		if err != nil {
+			return nil, err
+		}
+		if base := t.httpTransport(); base != nil && base.TLSClientConfig != nil {
+			current := base.TLSClientConfig.Clone()
+			current.ServerName = tlsConfig.ServerName
+			current.NextProtos = tlsConfig.NextProtos
+			tlsConfig = current
+		}
+		tlsConfig.VerifyConnection = c.verifyCertificatePins
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return c.clientCertificateFor(host)
+		}
+		return udp.DialEarly(ctx, &net.UDPAddr{IP: ips[0], Port: portNum}, tlsConfig, config)
+	}
+}
+
+func (t *http3Transport) transport() (*quic.Transport, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.udp != nil {
+		return t.udp, nil
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	t.udp = &quic.Transport{Conn: conn}
+	return t.udp, nil
+}
+
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	host := req.URL.Hostname()
+	if !t.useHTTP3(req) {
+		return next.RoundTrip(req)
+	}
+	res, err := t.h3.RoundTrip(req)
+	if err == nil {
+		return res, nil
+	}
+	if req.Context().Err() != nil {
+		return nil, err
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, err
+		}
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	t.lock.Lock()
+	t.failed[host] = time.Now()
+	t.lock.Unlock()
+	return next.RoundTrip(req)
+}
+
+func (t *http3Transport) useHTTP3(req *http.Request) bool {
+	if req.URL.Scheme != "https" || t.collector.proxyRouted {
+		return false
+	}
+	if proxyURL, err := http.ProxyFromEnvironment(req); err != nil || proxyURL != nil {
+		return false
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	if failedAt, ok := t.failed[host]; ok && time.Since(failedAt) < http3RetryAfter {
+		return false
+	}
+	return hostMatches(t.domains, host)
+}
+
+func (t *http3Transport) httpTransport() *http.Transport {
+	switch next := t.next.(type) {
+	case *http.Transport:
+		return next
+	case transportWrapper:
+		return next.httpTransport()
+	case nil:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		t.next = transport
+		return transport
+	}
+	return nil
+}
+
+func (t *http3Transport) CloseIdleConnections() {
+	t.h3.CloseIdleConnections()
+	if ci, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+const h2cRetryAfter = 10 * time.Minute
+
+type h2cTransport struct {
+	h2c       *http2.Transport
+	next      http.RoundTripper
+	collector *Collector
+	failed    map[string]time.Time
+	lock      *sync.RWMutex
+}
+
+func (t *h2cTransport) dial(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+	if base := t.httpTransport(); base != nil && base.DialContext != nil {
+		return base.DialContext(ctx, network, addr)
+	}
+	return (&net.Dialer{}).DialContext(ctx, network, addr)
+}
+
+func (t *h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if !t.useH2C(req) {
+		return next.RoundTrip(req)
+	}
+	res, err := t.h2c.RoundTrip(req)
+	if err == nil {
+		return res, nil
+	}
+	if req.Context().Err() != nil {
+		return nil, err
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, err
+		}
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+	t.lock.Lock()
+	t.failed[req.URL.Host] = time.Now()
+	t.lock.Unlock()
+	return next.RoundTrip(req)
+}
+
+func (t *h2cTransport) useH2C(req *http.Request) bool {
+	if req.URL.Scheme != "http" {
+		return false
+	}
+	if t.collector.proxyRouted {
+		return false
+	}
+	if proxyURL, err := http.ProxyFromEnvironment(req); err != nil || proxyURL != nil {
+		return false
+	}
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	failedAt, ok := t.failed[req.URL.Host]
+	return !ok || time.Since(failedAt) >= h2cRetryAfter
+}
+
+func (t *h2cTransport) httpTransport() *http.Transport {
+	switch next := t.next.(type) {
+	case *http.Transport:
+		return next
+	case transportWrapper:
+		return next.httpTransport()
+	case nil:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		t.next = transport
+		return transport
+	}
+	return nil
+}
+
+func (t *h2cTransport) CloseIdleConnections() {
+	t.h2c.CloseIdleConnections()
+	if ci, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+func (t *h2cTransport) inner() http.RoundTripper {
+	return t.next
+}
+
+func (t *h2cTransport) setInner(next http.RoundTripper) {
+	t.next = next
+}
+
+func (t *h2cTransport) withInner(next http.RoundTripper) http.RoundTripper {
+	copied := *t
+	copied.next = next
+	return &copied
+}
+
+type transportWrapper interface {
+	httpTransport() *http.Transport
+}
+
+type chainedTransport interface {
+	inner() http.RoundTripper
+	setInner(http.RoundTripper)
+	withInner(http.RoundTripper) http.RoundTripper
+}
+
+func (t *http3Transport) inner() http.RoundTripper {
+	return t.next
+}
+
+func (t *http3Transport) setInner(next http.RoundTripper) {
+	t.next = next
+}
+
+func (t *http3Transport) withInner(next http.RoundTripper) http.RoundTripper {
+	copied := *t
+	copied.next = next
+	return &copied
+}
+
+func (c *Collector) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	order := c.headerOrder
+	if values, ok := req.Header[HeaderOrderKey]; ok {
+		order = nil
+		for _, v := range values {
+			for _, name := range strings.Split(v, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					order = append(order, name)
+				}
+			}
+		}
+		req = req.Clone(req.Context())
+		delete(req.Header, HeaderOrderKey)
+	}
+	return c.authenticate(req, func(req *http.Request) (*http.Response, error) {
+		return c.send(next, req, order)
+	})
+}
+
+func (c *Collector) send(next http.RoundTripper, req *http.Request, order []string) (*http.Response, error) {
+	req, err := c.signRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := c.resolveProxy(req)
+	if err != nil {
+		return nil, err
+	}
+	if isSOCKSProxy(proxyURL) {
+		return c.socksChain(next, proxyURL).RoundTrip(req)
+	}
+	if len(order) == 0 || (req.URL.Scheme != "http" && req.URL.Scheme != "https") {
+		return next.RoundTrip(req)
+	}
+	switch next.(type) {
+	case *http.Transport, *utlsTransport:
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrHeaderOrderUnsupported, next)
+	}
+	t := c.httpTransport()
+	if proxyURL == nil && t.Proxy != nil {
+		if proxyURL, err = t.Proxy(req); err != nil {
+			return nil, err
+		}
+		if isSOCKSProxy(proxyURL) {
+			return next.RoundTrip(req)
+		}
+	}
+	if proxyURL != nil && proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %s proxy", ErrHeaderOrderUnsupported, proxyURL.Scheme)
+	}
+	return c.orderedConns.roundTrip(next, t, req, proxyURL, order)
+}
@@ -0,0 +1,140 @@
+package colly
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+func (c *Collector) DetectTraps(rule *TrapRule) {
+	rule.Init()
+	c.trapRule = rule
+}
+
+type TrapRule struct {
+	MaxSegmentRepeats    int
+	MaxPathDepth         int
+	MaxPatternURLs       int
+	MaxFacetCombinations int
+	patterns             map[string]int
+	capped               map[string]bool
+	facets               map[string]map[string]bool
+	lock                 *sync.Mutex
+}
+
+type Trap struct {
+	URL     *url.URL
+	Pattern string
+	Reason  string
+}
+
+var (
+	trapDateSegment   = regexp.MustCompile(`^\d{4}[-_.]?\d{1,2}([-_.]?\d{1,2})?$`)
+	trapNumberSegment = regexp.MustCompile(`^\d+$`)
+	trapIDSegment     = regexp.MustCompile(`^[0-9a-fA-F-]{16,}$`)
+)
+
+func (r *TrapRule) Init() {
+	if r.MaxSegmentRepeats == 0 {
+		r.MaxSegmentRepeats = 3
+	}
+	if r.MaxPatternURLs == 0 {
+		r.MaxPatternURLs = 1000
+	}
+	if r.MaxFacetCombinations == 0 {
+		r.MaxFacetCombinations = 100
+	}
+	r.patterns = make(map[string]int)
+	r.capped = make(map[string]bool)
+	r.facets = make(map[string]map[string]bool)
+	r.lock = &sync.Mutex{}
+}
+
+func (r *TrapRule) check(u *url.URL) *Trap {
+	segments := strings.FieldsFunc(u.Path, func(c rune) bool { return c == '/' })
+	pattern := urlPattern(u, segments)
+	if r.MaxPathDepth > 0 && len(segments) > r.MaxPathDepth {
+		return &Trap{URL: u, Pattern: pattern, Reason: "path depth"}
+	}
+	if r.MaxSegmentRepeats > 0 {
+		seen := make(map[string]int, len(segments))
+		for _, s := range segments {
+			seen[s]++
+			if seen[s] > r.MaxSegmentRepeats {
+				return &Trap{URL: u, Pattern: pattern, Reason: "path repetition"}
+			}
+		}
+	}
+	if r.MaxPatternURLs <= 0 && r.MaxFacetCombinations <= 0 {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.MaxFacetCombinations > 0 && u.RawQuery != "" {
+		base, keys, _ := strings.Cut(pattern, "?")
+		combinations := r.facets[base]
+		if combinations == nil {
+			combinations = make(map[string]bool)
+			r.facets[base] = combinations
+		}
+		if !combinations[keys] && len(combinations) >= r.MaxFacetCombinations {
+			return &Trap{URL: u, Pattern: base, Reason: "facet explosion"}
+		}
+		combinations[keys] = true
+	}
+	if r.MaxPatternURLs <= 0 {
+		return nil
+	}
+	if r.capped[pattern] {
+		return &Trap{URL: u, Pattern: pattern, Reason: "pattern volume"}
+	}
+	r.patterns[pattern]++
+	if r.patterns[pattern] > r.MaxPatternURLs {
+		r.capped[pattern] = true
+		return &Trap{URL: u, Pattern: pattern, Reason: "pattern volume"}
+	}
+	return nil
+}
+
+func urlPattern(u *url.URL, segments []string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(u.Hostname()))
+	for _, s := range segments {
+		b.WriteByte('/')
+		switch {
+		case trapDateSegment.MatchString(s):
+			b.WriteString("{date}")
+		case trapNumberSegment.MatchString(s):
+			b.WriteString("{n}")
+		case trapIDSegment.MatchString(s):
+			b.WriteString("{id}")
+		default:
+			b.WriteString(s)
+		}
+	}
+	if u.RawQuery != "" {
+		keys := make([]string, 0)
+		for k := range u.Query() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('?')
+		b.WriteString(strings.Join(keys, "&"))
+	}
+	return b.String()
+}
+
+type TrapError struct {
+	Trap *Trap
+}
+
+func (e *TrapError) Error() string {
+	return fmt.Sprintf("%s: %s (%s %s)", ErrTrapDetected, e.Trap.URL, e.Trap.Reason, e.Trap.Pattern)
+}
+
+func (e *TrapError) Unwrap() error {
+	return ErrTrapDetected
+}
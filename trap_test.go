@@ -0,0 +1,31 @@
+package colly
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrapDetectionReturnsTypedError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	c := NewCollector(DetectTraps(&TrapRule{MaxPatternURLs: 2}))
+	var detected []*Trap
+	c.OnTrapDetected(func(trap *Trap) {
+		detected = append(detected, trap)
+	})
+	var err error
+	for i := 0; i < 3 && err == nil; i++ {
+		err = c.Visit(fmt.Sprintf("%s/page/%d", ts.URL, i))
+	}
+	var trapErr *TrapError
+	if !errors.As(err, &trapErr) || !errors.Is(err, ErrTrapDetected) {
+		t.Fatalf("expected a TrapError wrapping ErrTrapDetected, got %v", err)
+	}
+	if len(detected) != 1 || detected[0] != trapErr.Trap || trapErr.Trap.Reason != "pattern volume" {
+		t.Fatalf("expected the callback and the error to report the same trap, got %+v and %+v", detected, trapErr.Trap)
+	}
+}
@@ -0,0 +1,203 @@
+package colly
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func (h *HTMLElement) Unmarshal(v interface{}) error {
+	return UnmarshalHTML(v, h.DOM, nil)
+}
+
+func (h *HTMLElement) UnmarshalWithMap(v interface{}, structMap map[string]string) error {
+	return UnmarshalHTML(v, h.DOM, structMap)
+}
+
+func UnmarshalHTML(v interface{}, s *goquery.Selection, structMap map[string]string) error {
+	rv := reflect.ValueOf(v)
+
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("Invalid type or nil-pointer")
+	}
+
+	sv := rv.Elem()
+	st := reflect.TypeOf(v).Elem()
+	if structMap != nil {
+		for k, v := range structMap {
+			attrV := sv.FieldByName(k)
+			if !attrV.CanAddr() || !attrV.CanSet() {
+				continue
+			}
+			if err := unmarshalSelector(s, attrV, v, ""); err != nil {
+				return err
+			}
+		}
+	} else {
+		for i := 0; i < sv.NumField(); i++ {
+			attrV := sv.Field(i)
+			if !attrV.CanAddr() || !attrV.CanSet() {
+				continue
+			}
+			if err := unmarshalAttr(s, attrV, st.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func unmarshalAttr(s *goquery.Selection, attrV reflect.Value, attrT reflect.StructField) error {
+	selector, ok := attrT.Tag.Lookup("selector")
+	if !ok {
+		selector = attrT.Tag.Get("colly")
+	}
+	htmlAttr := attrT.Tag.Get("attr")
+	return unmarshalSelector(s, attrV, selector, htmlAttr)
+}
+
+func unmarshalSelector(s *goquery.Selection, attrV reflect.Value, selector, htmlAttr string) error {
+	if selector == "-" {
+		return nil
+	}
+	switch attrV.Kind() {
+	case reflect.Slice:
+		if err := unmarshalSlice(s, selector, htmlAttr, attrV); err != nil {
+			return err
+		}
+	case reflect.String:
+		val := getDOMValue(s.Find(selector), htmlAttr)
+		attrV.Set(reflect.Indirect(reflect.ValueOf(val)))
+	case reflect.Struct:
+		if err := unmarshalStruct(s, selector, attrV); err != nil {
+			return err
+		}
+	case reflect.Ptr:
+		if err := unmarshalPtr(s, selector, attrV); err != nil {
+			return err
+		}
+	default:
+		if err := unmarshalScalar(s.Find(selector), htmlAttr, attrV); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalScalar(s *goquery.Selection, htmlAttr string, attrV reflect.Value) error {
+	if s.Length() == 0 {
+		return nil
+	}
+	val := getDOMValue(s, htmlAttr)
+	if val == "" {
+		return nil
+	}
+	return setTableField(attrV, val)
+}
+
+func unmarshalStruct(s *goquery.Selection, selector string, attrV reflect.Value) error {
+	newS := s
+	if selector != "" {
+		newS = newS.Find(selector)
+	}
+	if newS.Nodes == nil {
+		return nil
+	}
+	v := reflect.New(attrV.Type())
+	err := UnmarshalHTML(v.Interface(), newS, nil)
+	if err != nil {
+		return err
+	}
+	attrV.Set(reflect.Indirect(v))
+	return nil
+}
+
+func unmarshalPtr(s *goquery.Selection, selector string, attrV reflect.Value) error {
+	newS := s
+	if selector != "" {
+		newS = newS.Find(selector)
+	}
+	if newS.Nodes == nil {
+		return nil
+	}
+	e := attrV.Type().Elem()
+	if e.Kind() != reflect.Struct {
+		return errors.New("Invalid slice type")
+	}
+	v := reflect.New(e)
+	err := UnmarshalHTML(v.Interface(), newS, nil)
+	if err != nil {
+		return err
+	}
+	attrV.Set(v)
+	return nil
+}
+
+func unmarshalSlice(s *goquery.Selection, selector, htmlAttr string, attrV reflect.Value) error {
+	if attrV.Pointer() == 0 {
+		v := reflect.MakeSlice(attrV.Type(), 0, 0)
+		attrV.Set(v)
+	}
+	switch attrV.Type().Elem().Kind() {
+	case reflect.String:
+		s.Find(selector).Each(func(_ int, s *goquery.Selection) {
+			val := getDOMValue(s, htmlAttr)
+			attrV.Set(reflect.Append(attrV, reflect.Indirect(reflect.ValueOf(val))))
+		})
+	case reflect.Ptr:
+		var err error
+		s.Find(selector).EachWithBreak(func(_ int, innerSel *goquery.Selection) bool {
+			someVal := reflect.New(attrV.Type().Elem().Elem())
+			if err = UnmarshalHTML(someVal.Interface(), innerSel, nil); err != nil {
+				return false
+			}
+			attrV.Set(reflect.Append(attrV, someVal))
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	case reflect.Struct:
+		var err error
+		s.Find(selector).EachWithBreak(func(_ int, innerSel *goquery.Selection) bool {
+			someVal := reflect.New(attrV.Type().Elem())
+			if err = UnmarshalHTML(someVal.Interface(), innerSel, nil); err != nil {
+				return false
+			}
+			attrV.Set(reflect.Append(attrV, reflect.Indirect(someVal)))
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		var err error
+		s.Find(selector).EachWithBreak(func(_ int, innerSel *goquery.Selection) bool {
+			someVal := reflect.New(attrV.Type().Elem()).Elem()
+			if err = setTableField(someVal, getDOMValue(innerSel, htmlAttr)); err != nil {
+				return false
+			}
+			attrV.Set(reflect.Append(attrV, someVal))
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("Invalid slice type")
+	}
+	return nil
+}
+
+func getDOMValue(s *goquery.Selection, attr string) string {
+	if attr == "" {
+		return strings.TrimSpace(s.First().Text())
+	}
+	attrV, _ := s.Attr(attr)
+	return attrV
+}
@@ -0,0 +1,68 @@
+package colly
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestUnmarshalSlicePropagatesElementErrors(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<ul>
+		<li><span class="n">1</span></li>
+		<li><span class="n">two</span></li>
+	</ul>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type item struct {
+		N int `selector:"span.n"`
+	}
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"struct", &struct {
+			Items []item `selector:"li"`
+		}{}},
+		{"pointer", &struct {
+			Items []*item `selector:"li"`
+		}{}},
+		{"scalar", &struct {
+			Items []int `selector:"span.n"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := UnmarshalHTML(tt.v, doc.Selection, nil); err == nil {
+				t.Fatal("expected the invalid element to fail the unmarshal")
+			}
+		})
+	}
+}
+
+func TestUnmarshalSlice(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<ul>
+		<li><span class="n">1</span><a href="/a">a</a></li>
+		<li><span class="n">2</span><a href="/b">b</a></li>
+	</ul>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Items []*struct {
+			N    int    `selector:"span.n"`
+			Link string `selector:"a" attr:"href"`
+		} `selector:"li"`
+		Texts []string `selector:"a"`
+	}
+	if err := UnmarshalHTML(&v, doc.Selection, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Items) != 2 || v.Items[1].N != 2 || v.Items[1].Link != "/b" {
+		t.Fatalf("unexpected items: %+v", v.Items)
+	}
+	if strings.Join(v.Texts, ",") != "a,b" {
+		t.Fatalf("unexpected texts: %v", v.Texts)
+	}
+}
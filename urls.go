@@ -0,0 +1,204 @@
+package colly
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "dclid", "msclkid", "yclid", "igshid", "mc_cid", "mc_eid", "_ga", "_hsenc", "_hsmi"}
+	DefaultSessionParams  = []string{"jsessionid", "phpsessid", "aspsessionid*", "sid", "sessionid", "session_id"}
+)
+
+type QueryRule struct {
+	Strip         []string
+	SessionParams []string
+	Sort          bool
+}
+
+type queryRoute struct {
+	glob string
+	rule *QueryRule
+}
+
+func (r *QueryRule) Init() {
+	if r.Strip == nil {
+		r.Strip = DefaultTrackingParams
+	}
+	if r.SessionParams == nil {
+		r.SessionParams = DefaultSessionParams
+	}
+}
+
+func (r *QueryRule) Apply(u *url.URL) {
+	if len(r.SessionParams) > 0 && strings.Contains(u.Path, ";") {
+		segments := strings.Split(u.Path, "/")
+		for i, segment := range segments {
+			name, params, ok := strings.Cut(segment, ";")
+			if !ok {
+				continue
+			}
+			kept := []string{name}
+			for _, param := range strings.Split(params, ";") {
+				key, _, _ := strings.Cut(param, "=")
+				if !matchParam(r.SessionParams, key) {
+					kept = append(kept, param)
+				}
+			}
+			segments[i] = strings.Join(kept, ";")
+		}
+		u.Path = strings.Join(segments, "/")
+		u.RawPath = ""
+	}
+	if u.RawQuery == "" {
+		return
+	}
+	pairs := strings.Split(u.RawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		if pair == "" {
+			continue
+		}
+		key, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if matchParam(r.Strip, key) || matchParam(r.SessionParams, key) {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	if r.Sort {
+		sortQueryPairs(kept)
+	}
+	u.RawQuery = strings.Join(kept, "&")
+	u.ForceQuery = false
+}
+
+func sortQueryPairs(pairs []string) {
+	sort.SliceStable(pairs, func(i, j int) bool {
+		ki, _, _ := strings.Cut(pairs[i], "=")
+		kj, _, _ := strings.Cut(pairs[j], "=")
+		return ki < kj
+	})
+}
+
+func matchParam(patterns []string, key string) bool {
+	key = strings.ToLower(key)
+	for _, p := range patterns {
+		if matched, _ := path.Match(strings.ToLower(p), key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Collector) SetQueryRule(glob string, rule *QueryRule) {
+	glob = strings.ToLower(glob)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	routes := make([]*queryRoute, 0, len(c.queryRules)+1)
+	for _, r := range c.queryRules {
+		if r.glob != glob {
+			routes = append(routes, r)
+		}
+	}
+	if rule != nil {
+		rule.Init()
+		routes = append(routes, &queryRoute{glob: glob, rule: rule})
+	}
+	c.queryRules = routes
+}
+
+func (c *Collector) normalizeQuery(u *url.URL) {
+	c.lock.RLock()
+	routes := c.queryRules
+	c.lock.RUnlock()
+	host := strings.ToLower(u.Hostname())
+	for _, r := range routes {
+		if matchHostGlob(r.glob, host) {
+			r.rule.Apply(u)
+			return
+		}
+	}
+}
+
+type CanonicalizeFunc func(u *url.URL)
+
+var DefaultCanonicalization = []CanonicalizeFunc{LowercaseHost, StripDefaultPort, ResolveDotSegments, StripFragment}
+
+func LowercaseHost(u *url.URL) {
+	u.Host = strings.ToLower(u.Host)
+}
+
+func StripDefaultPort(u *url.URL) {
+	port := u.Port()
+	if (port == "80" && u.Scheme == "http") || (port == "443" && u.Scheme == "https") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+}
+
+func ResolveDotSegments(u *url.URL) {
+	if !strings.HasPrefix(u.Path, "/") || !strings.Contains(u.Path, ".") {
+		return
+	}
+	resolved := u.ResolveReference(&url.URL{Path: u.Path, RawPath: u.RawPath})
+	u.Path = resolved.Path
+	u.RawPath = resolved.RawPath
+}
+
+func StripFragment(u *url.URL) {
+	u.Fragment = ""
+	u.RawFragment = ""
+}
+
+func StripTrailingSlash(u *url.URL) {
+	if len(u.Path) > 1 {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	}
+}
+
+func StripWWW(u *url.URL) {
+	if port := u.Port(); port != "" {
+		u.Host = strings.TrimPrefix(u.Hostname(), "www.") + ":" + port
+		return
+	}
+	u.Host = strings.TrimPrefix(u.Host, "www.")
+}
+
+func SortQuery(u *url.URL) {
+	if u.RawQuery == "" {
+		return
+	}
+	pairs := strings.Split(u.RawQuery, "&")
+	sortQueryPairs(pairs)
+	u.RawQuery = strings.Join(pairs, "&")
+}
+
+func StripQueryParams(patterns ...string) CanonicalizeFunc {
+	rule := &QueryRule{Strip: patterns, SessionParams: []string{}}
+	return rule.Apply
+}
+
+func (c *Collector) SetCanonicalization(steps ...CanonicalizeFunc) {
+	c.canonicalizers = steps
+}
+
+func (c *Collector) CanonicalURL(u string) string {
+	if len(c.canonicalizers) == 0 {
+		return c.foldURLString(u)
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return u
+	}
+	parsed = c.foldURL(parsed)
+	canonical := *parsed
+	for _, step := range c.canonicalizers {
+		step(&canonical)
+	}
+	return canonical.String()
+}
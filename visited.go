@@ -0,0 +1,93 @@
+package colly
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"io"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/gocolly/colly/v2/storage"
+)
+
+type visitKey struct {
+	hash   uint64
+	digest []byte
+}
+
+type DigestStorage interface {
+	VisitedDigest(digest []byte) error
+	IsVisitedDigest(digest []byte) (bool, error)
+}
+
+type memoryStorage struct {
+	*storage.InMemoryStorage
+	digests map[string]struct{}
+	lock    sync.RWMutex
+}
+
+func (s *memoryStorage) VisitedDigest(digest []byte) error {
+	s.lock.Lock()
+	if s.digests == nil {
+		s.digests = make(map[string]struct{})
+	}
+	s.digests[string(digest)] = struct{}{}
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) IsVisitedDigest(digest []byte) (bool, error) {
+	s.lock.RLock()
+	_, ok := s.digests[string(digest)]
+	s.lock.RUnlock()
+	return ok, nil
+}
+
+func (c *Collector) visitKey(u string, body io.Reader) visitKey {
+	h := requestHasher(c.hashAlgorithm, c.hashSalt, c.CanonicalURL(u), body)
+	if h64, ok := h.(hash.Hash64); ok {
+		return visitKey{hash: h64.Sum64()}
+	}
+	digest := h.Sum(nil)
+	return visitKey{hash: binary.BigEndian.Uint64(digest), digest: digest}
+}
+
+func (c *Collector) isVisited(key visitKey) (bool, error) {
+	if s, ok := c.store.(DigestStorage); ok && key.digest != nil {
+		return s.IsVisitedDigest(key.digest)
+	}
+	return c.store.IsVisited(key.hash)
+}
+
+func (c *Collector) setVisited(key visitKey) error {
+	if s, ok := c.store.(DigestStorage); ok && key.digest != nil {
+		return s.VisitedDigest(key.digest)
+	}
+	return c.store.Visited(key.hash)
+}
+
+func requestHasher(algorithm HashAlgorithm, salt, url string, body io.Reader) hash.Hash {
+	var h hash.Hash
+	switch algorithm {
+	case HashXXHash:
+		h = xxhash.New()
+	case HashSHA1:
+		h = sha1.New()
+	case HashSHA256:
+		h = sha256.New()
+	default:
+		h = fnv.New64a()
+	}
+	if salt != "" {
+		io.WriteString(h, salt)
+		h.Write([]byte{0})
+	}
+	io.WriteString(h, normalizeURL(url))
+	if body != nil {
+		copyBuffered(h, body)
+	}
+	return h
+}
@@ -0,0 +1,82 @@
+package colly
+
+import (
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+func (c *Collector) findXMLNodes(doc *xmlquery.Node, query string) ([]*xmlquery.Node, error) {
+	if len(c.xmlNamespaces) == 0 {
+		return xmlquery.QueryAll(doc, query)
+	}
+	expr, err := xpath.CompileWithNS(query, c.xmlNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	return xmlquery.QuerySelectorAll(doc, expr), nil
+}
+
+func (c *Collector) RegisterXMLNamespace(prefix, uri string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.xmlNamespaces == nil {
+		c.xmlNamespaces = make(map[string]string)
+	}
+	c.xmlNamespaces[prefix] = uri
+}
+
+func (h *XMLElement) AttrNS(namespace, name string) string {
+	attrs, ok := h.attributes.([]xmlquery.Attr)
+	if !ok {
+		return h.Attr(namespace + ":" + name)
+	}
+	for _, a := range attrs {
+		if a.Name.Local == name && (a.Name.Space == namespace || a.NamespaceURI == namespace) {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (h *XMLElement) ChildTextNS(xpathQuery string) string {
+	texts := h.ChildTextsNS(xpathQuery)
+	if len(texts) == 0 {
+		return ""
+	}
+	return texts[0]
+}
+
+func (h *XMLElement) ChildTextsNS(xpathQuery string) []string {
+	n, ok := h.DOM.(*xmlquery.Node)
+	if !ok || h.Request == nil {
+		return h.ChildTexts(xpathQuery)
+	}
+	nodes, err := h.Request.collector.findXMLNodes(n, xpathQuery)
+	if err != nil {
+		return nil
+	}
+	texts := make([]string, 0, len(nodes))
+	for _, child := range nodes {
+		texts = append(texts, strings.TrimSpace(child.InnerText()))
+	}
+	return texts
+}
+
+func (h *XMLElement) ChildAttrNS(xpathQuery, namespace, name string) string {
+	n, ok := h.DOM.(*xmlquery.Node)
+	if !ok || h.Request == nil {
+		return h.ChildAttr(xpathQuery, namespace+":"+name)
+	}
+	nodes, err := h.Request.collector.findXMLNodes(n, xpathQuery)
+	if err != nil || len(nodes) == 0 {
+		return ""
+	}
+	for _, a := range nodes[0].Attr {
+		if a.Name.Local == name && (namespace == "" || a.Name.Space == namespace || a.NamespaceURI == namespace) {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}